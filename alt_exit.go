@@ -32,7 +32,7 @@ var handlers = []func(){}
 func runHandler(handler func()) {
 	defer func() {
 		if err := recover(); err != nil {
-			fmt.Fprintln(os.Stderr, "Error: Logrus exit handler error:", err)
+			fmt.Fprintln(Stderr(), "Error: Logrus exit handler error:", err)
 		}
 	}()
 
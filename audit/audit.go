@@ -0,0 +1,377 @@
+// Package audit provides a tamper-evident audit logger. Every entry it
+// writes gets a sequence number and an HMAC-SHA256 hash chaining it to the
+// previous entry, so that [Verify] can detect entries that were removed,
+// reordered or modified:
+//
+//	auditLog, err := audit.Open("/var/log/app/audit.log", &audit.Options{Key: key})
+//	if err != nil {
+//		return err
+//	}
+//	defer auditLog.Close()
+//	auditLog.WithField("user", "alice").Info("password changed")
+//
+// Entries are formatted as JSON, one per line, with the fields "seq" and
+// "hash" appended:
+//
+//	{"level":"info","msg":"password changed","time":"...","user":"alice","seq":42,"hash":"9f86d0..."}
+//
+// Fields named "seq" or "hash" are renamed to "fields.seq" and
+// "fields.hash", like JSONFormatter does for fields clashing with its own
+// keys.
+//
+// The hash of an entry is the HMAC of the hash of the previous entry, a
+// newline and the line up to and including the sequence number. Entries are
+// written synchronously and synced to stable storage before the logging
+// call returns. The logger logs all levels and has no hooks, so nothing is
+// sampled or dropped.
+//
+// The chain cannot detect entries removed from the end of the log. Compare
+// the last sequence number and hash reported by [Verify] with a copy kept
+// elsewhere, for example by periodically logging them to another system
+// using [Logger.Last].
+//
+// A crash while an entry is written may leave an incomplete last line,
+// which makes [Open] fail with [ErrIncomplete]. Since the logging call of
+// that entry never returned, the entry was never acknowledged; after
+// inspecting the file, set Options.RemoveIncomplete to remove the line
+// and continue the chain from the entry before it.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrIncomplete is returned by [Open] if the last line of the file is not
+// terminated by a newline and is not a complete entry.
+var ErrIncomplete = errors.New("audit: incomplete last entry")
+
+// File is the output of a [Logger], usually an [*os.File].
+type File interface {
+	io.Writer
+
+	// Sync commits the written data to stable storage.
+	Sync() error
+}
+
+// Options are options for a [Logger].
+type Options struct {
+	// Key is the HMAC key of the hash chain. It is required.
+	Key []byte
+
+	// Formatter formats the entries. PrettyPrint must not be set, since
+	// every entry must be a single line. If nil, a default JSONFormatter is
+	// used.
+	Formatter *logrus.JSONFormatter
+
+	// ErrorHandler is called when an entry cannot be written or synced.
+	// If nil, the logger panics with the error, so that an audit event is
+	// never lost silently.
+	ErrorHandler func(error)
+
+	// RemoveIncomplete makes [Open] remove an incomplete last line left
+	// by a crash instead of failing with [ErrIncomplete].
+	RemoveIncomplete bool
+}
+
+// Logger writes a hash-chained audit log. It provides the logging methods
+// of a [logrus.Logger], but not those changing its configuration, such as
+// SetLevel, SetOutput or AddHook, which would break the chain or drop
+// entries.
+type Logger struct {
+	logger *logrus.Logger
+
+	w    *chainWriter
+	file io.Closer // set by Open
+}
+
+// New returns a Logger writing a new hash chain to out, starting with the
+// sequence number 1.
+func New(out File, opts *Options) (*Logger, error) {
+	return newLogger(out, opts, 0, "")
+}
+
+// Open returns a Logger appending to the file at path, creating it if
+// needed. If the file already contains entries, the hash chain is
+// continued from its last entry.
+func Open(path string, opts *Options) (*Logger, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	seq, prev, err := lastEntry(f, opts != nil && opts.RemoveIncomplete)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("audit: reading %s: %w", path, err)
+	}
+	l, err := newLogger(f, opts, seq, prev)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	l.file = f
+	return l, nil
+}
+
+func newLogger(out File, opts *Options, seq uint64, prev string) (*Logger, error) {
+	if opts == nil || len(opts.Key) == 0 {
+		return nil, errors.New("audit: missing key")
+	}
+	formatter := opts.Formatter
+	if formatter == nil {
+		formatter = &logrus.JSONFormatter{}
+	}
+	if formatter.PrettyPrint {
+		return nil, errors.New("audit: formatter must not pretty-print")
+	}
+	for _, key := range append(slices.Collect(maps.Values(formatter.FieldMap)), formatter.DataKey) {
+		if key == "seq" || key == "hash" {
+			return nil, fmt.Errorf("audit: formatter must not use the key %q", key)
+		}
+	}
+	onError := opts.ErrorHandler
+	if onError == nil {
+		onError = func(err error) { panic(err) }
+	}
+
+	w := &chainWriter{
+		out:     out,
+		mac:     hmac.New(sha256.New, opts.Key),
+		seq:     seq,
+		prev:    []byte(prev),
+		onError: onError,
+	}
+	logger := logrus.New()
+	logger.Out = w
+	logger.Formatter = &chainFormatter{formatter}
+	logger.Level = logrus.TraceLevel
+	return &Logger{logger: logger, w: w}, nil
+}
+
+// lastEntry returns the sequence number and hash of the last entry of f.
+// If the file ends with an incomplete line, it is removed if remove is
+// set, and otherwise ErrIncomplete is returned. A complete entry missing
+// only its newline is terminated.
+func lastEntry(f *os.File, remove bool) (uint64, string, error) {
+	var (
+		last, partial []byte
+		offset, end   int64 // end is the offset after the last complete line
+	)
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadBytes('\n')
+		offset += int64(len(line))
+		if err == io.EOF {
+			partial = line
+			break
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		end = offset
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			last = line
+		}
+	}
+
+	if line := bytes.TrimSpace(partial); len(line) > 0 {
+		if _, ok := parseRecord(line); ok {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				return 0, "", err
+			}
+			last = line
+		} else if !remove {
+			return 0, "", ErrIncomplete
+		} else if err := f.Truncate(end); err != nil {
+			return 0, "", err
+		}
+	}
+	if last == nil {
+		return 0, "", nil
+	}
+	rec, ok := parseRecord(last)
+	if !ok {
+		return 0, "", errors.New("last entry is not an audit entry")
+	}
+	return rec.seq, rec.hash, nil
+}
+
+// Last returns the sequence number and hash of the last entry written, or
+// 0 and "" if there is none.
+func (l *Logger) Last() (uint64, string) {
+	l.w.mu.Lock()
+	defer l.w.mu.Unlock()
+	return l.w.seq, string(l.w.prev)
+}
+
+// Close closes the hooks of the logger and, if the Logger was created by
+// [Open], the file.
+func (l *Logger) Close() error {
+	err := l.logger.Close()
+	if l.file != nil {
+		err = errors.Join(err, l.file.Close())
+	}
+	return err
+}
+
+// WithField creates an entry from the logger and adds a field to it.
+func (l *Logger) WithField(key string, value any) *logrus.Entry {
+	return l.logger.WithField(key, value)
+}
+
+// WithFields creates an entry from the logger and adds multiple fields to
+// it.
+func (l *Logger) WithFields(fields logrus.Fields) *logrus.Entry {
+	return l.logger.WithFields(fields)
+}
+
+// WithError creates an entry from the logger and adds an error to it.
+func (l *Logger) WithError(err error) *logrus.Entry {
+	return l.logger.WithError(err)
+}
+
+// WithContext creates an entry from the logger and adds a context to it.
+func (l *Logger) WithContext(ctx context.Context) *logrus.Entry {
+	return l.logger.WithContext(ctx)
+}
+
+// WithTime creates an entry from the logger and overrides its time.
+func (l *Logger) WithTime(t time.Time) *logrus.Entry {
+	return l.logger.WithTime(t)
+}
+
+// Log logs a message at level.
+func (l *Logger) Log(level logrus.Level, args ...any) {
+	l.logger.Log(level, args...)
+}
+
+// Logf logs a formatted message at level.
+func (l *Logger) Logf(level logrus.Level, format string, args ...any) {
+	l.logger.Logf(level, format, args...)
+}
+
+// Trace logs a message at level Trace.
+func (l *Logger) Trace(args ...any) { l.logger.Trace(args...) }
+
+// Debug logs a message at level Debug.
+func (l *Logger) Debug(args ...any) { l.logger.Debug(args...) }
+
+// Info logs a message at level Info.
+func (l *Logger) Info(args ...any) { l.logger.Info(args...) }
+
+// Warn logs a message at level Warn.
+func (l *Logger) Warn(args ...any) { l.logger.Warn(args...) }
+
+// Error logs a message at level Error.
+func (l *Logger) Error(args ...any) { l.logger.Error(args...) }
+
+// Tracef logs a formatted message at level Trace.
+func (l *Logger) Tracef(format string, args ...any) { l.logger.Tracef(format, args...) }
+
+// Debugf logs a formatted message at level Debug.
+func (l *Logger) Debugf(format string, args ...any) { l.logger.Debugf(format, args...) }
+
+// Infof logs a formatted message at level Info.
+func (l *Logger) Infof(format string, args ...any) { l.logger.Infof(format, args...) }
+
+// Warnf logs a formatted message at level Warn.
+func (l *Logger) Warnf(format string, args ...any) { l.logger.Warnf(format, args...) }
+
+// Errorf logs a formatted message at level Error.
+func (l *Logger) Errorf(format string, args ...any) { l.logger.Errorf(format, args...) }
+
+// chainFormatter renames the fields clashing with the keys of the chain
+// before formatting an entry.
+type chainFormatter struct {
+	*logrus.JSONFormatter
+}
+
+func (f *chainFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	_, seq := entry.Data["seq"]
+	_, hash := entry.Data["hash"]
+	if f.DataKey == "" && (seq || hash) {
+		renamed := *entry
+		renamed.Data = maps.Clone(entry.Data)
+		for _, key := range []string{"seq", "hash"} {
+			if v, ok := renamed.Data[key]; ok {
+				delete(renamed.Data, key)
+				renamed.Data["fields."+key] = v
+			}
+		}
+		entry = &renamed
+	}
+	return f.JSONFormatter.Format(entry)
+}
+
+// chainWriter appends the sequence number and hash to the formatted
+// entries.
+type chainWriter struct {
+	mu      sync.Mutex
+	out     File
+	mac     hash.Hash
+	seq     uint64
+	prev    []byte // hex-encoded hash of the previous entry
+	buf     []byte
+	onError func(error)
+}
+
+func (w *chainWriter) Write(p []byte) (int, error) {
+	line := bytes.TrimRight(p, "\n")
+	if len(line) < 2 || line[0] != '{' || line[len(line)-1] != '}' {
+		err := errors.New("audit: entry is not a JSON object")
+		w.onError(err)
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seq := w.seq + 1
+	buf := append(w.buf[:0], line[:len(line)-1]...)
+	if len(line) > 2 {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, `"seq":`...)
+	buf = strconv.AppendUint(buf, seq, 10)
+	sum := chainHash(w.mac, w.prev, buf)
+	buf = append(buf, `,"hash":"`...)
+	buf = append(buf, sum...)
+	buf = append(buf, "\"}\n"...)
+	w.buf = buf
+
+	if _, err := w.out.Write(buf); err != nil {
+		w.onError(err)
+		return 0, err
+	}
+	if err := w.out.Sync(); err != nil {
+		w.onError(err)
+		return 0, err
+	}
+	w.seq = seq
+	w.prev = append(w.prev[:0], sum...)
+	return len(p), nil
+}
+
+// chainHash returns the hex-encoded hash of an entry.
+func chainHash(mac hash.Hash, prev, body []byte) []byte {
+	mac.Reset()
+	mac.Write(prev)
+	mac.Write([]byte{'\n'})
+	mac.Write(body)
+	return hex.AppendEncode(nil, mac.Sum(nil))
+}
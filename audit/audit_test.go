@@ -0,0 +1,185 @@
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/audit"
+)
+
+var key = []byte("secret")
+
+// syncBuffer is a File recording the syncs.
+type syncBuffer struct {
+	bytes.Buffer
+	syncs   int
+	syncErr error
+}
+
+func (b *syncBuffer) Sync() error {
+	b.syncs++
+	return b.syncErr
+}
+
+func TestLogger(t *testing.T) {
+	var out syncBuffer
+	logger, err := audit.New(&out, &audit.Options{
+		Key:       key,
+		Formatter: &logrus.JSONFormatter{DisableTimestamp: true},
+	})
+	require.NoError(t, err)
+
+	logger.WithField("user", "alice").Info("login")
+	logger.Trace("logged at every level")
+	logger.Info("logout")
+	assert.Equal(t, 3, out.syncs)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &m))
+	assert.Equal(t, "alice", m["user"])
+	assert.Equal(t, "login", m["msg"])
+	assert.EqualValues(t, 1, m["seq"])
+	assert.Len(t, m["hash"], 64)
+	assert.Contains(t, lines[2], `"seq":3,"hash":"`)
+
+	report, err := audit.Verify(strings.NewReader(out.String()), key)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.Equal(t, 3, report.Entries)
+	seq, hash := logger.Last()
+	assert.Equal(t, uint64(3), report.LastSeq)
+	assert.Equal(t, seq, report.LastSeq)
+	assert.Equal(t, hash, report.LastHash)
+
+	report, err = audit.Verify(strings.NewReader(out.String()), []byte("wrong key"))
+	require.NoError(t, err)
+	assert.Len(t, report.Problems, 3)
+}
+
+func TestOpenContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for _, msg := range []string{"first", "second"} {
+		logger, err := audit.Open(path, &audit.Options{Key: key})
+		require.NoError(t, err)
+		logger.Info(msg)
+		require.NoError(t, logger.Close())
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	report, err := audit.Verify(f, key)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.Equal(t, uint64(2), report.LastSeq)
+}
+
+func TestChainFieldsRenamed(t *testing.T) {
+	var out syncBuffer
+	logger, err := audit.New(&out, &audit.Options{
+		Key:       key,
+		Formatter: &logrus.JSONFormatter{DisableTimestamp: true},
+	})
+	require.NoError(t, err)
+	logger.WithFields(logrus.Fields{"seq": 7, "hash": "forged"}).Info("event")
+
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &m))
+	assert.EqualValues(t, 1, m["seq"])
+	assert.Len(t, m["hash"], 64)
+	assert.EqualValues(t, 7, m["fields.seq"])
+	assert.Equal(t, "forged", m["fields.hash"])
+	report, err := audit.Verify(bytes.NewReader(out.Bytes()), key)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+}
+
+func TestOpenIncompleteLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := audit.Open(path, &audit.Options{Key: key})
+	require.NoError(t, err)
+	logger.Info("first")
+	require.NoError(t, logger.Close())
+
+	// A crash while writing the second entry.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"level":"info","msg":"sec`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = audit.Open(path, &audit.Options{Key: key})
+	assert.ErrorIs(t, err, audit.ErrIncomplete)
+
+	logger, err = audit.Open(path, &audit.Options{Key: key, RemoveIncomplete: true})
+	require.NoError(t, err)
+	logger.Info("second")
+	require.NoError(t, logger.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(b), "\n"), "the incomplete line is removed")
+	report, err := audit.Verify(bytes.NewReader(b), key)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.Equal(t, 2, report.Entries)
+}
+
+func TestOpenTerminatesCompleteLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := audit.Open(path, &audit.Options{Key: key})
+	require.NoError(t, err)
+	logger.Info("first")
+	require.NoError(t, logger.Close())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bytes.TrimSuffix(b, []byte("\n")), 0o600))
+
+	logger, err = audit.Open(path, &audit.Options{Key: key})
+	require.NoError(t, err)
+	logger.Info("second")
+	require.NoError(t, logger.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	report, err := audit.Verify(f, key)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.Equal(t, uint64(2), report.LastSeq)
+}
+
+func TestWriteError(t *testing.T) {
+	out := syncBuffer{syncErr: errors.New("disk failure")}
+	var got error
+	logger, err := audit.New(&out, &audit.Options{Key: key, ErrorHandler: func(err error) { got = err }})
+	require.NoError(t, err)
+	logger.Info("event")
+	assert.EqualError(t, got, "disk failure")
+
+	logger, err = audit.New(&out, &audit.Options{Key: key})
+	require.NoError(t, err)
+	assert.Panics(t, func() { logger.Info("event") }, "events are never dropped silently")
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	_, err := audit.New(&syncBuffer{}, nil)
+	assert.Error(t, err)
+	_, err = audit.New(&syncBuffer{}, &audit.Options{Key: key, Formatter: &logrus.JSONFormatter{PrettyPrint: true}})
+	assert.Error(t, err)
+	_, err = audit.New(&syncBuffer{}, &audit.Options{Key: key, Formatter: &logrus.JSONFormatter{
+		FieldMap: logrus.FieldMap{logrus.FieldKeyMsg: "hash"},
+	}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+// Command auditverify verifies audit logs written by package
+// github.com/sirupsen/logrus/audit.
+//
+// Usage:
+//
+//	auditverify -key-file key [file ...]
+//
+// The HMAC key is read from the file given by -key-file, or from the
+// environment variable AUDIT_KEY. The logs are read from the named files,
+// or from the standard input if there are none. Every problem found is
+// printed, followed by a summary of each log. The exit status is 1 if a
+// problem was found and 2 if a log could not be read.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus/audit"
+)
+
+func main() {
+	keyFile := flag.String("key-file", "", "read the HMAC key from `file` instead of $AUDIT_KEY")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: auditverify [-key-file file] [file ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	key, err := readKey(*keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "auditverify:", err)
+		os.Exit(2)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	for _, name := range files {
+		report, err := verify(name, key)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "auditverify:", err)
+			status = 2
+			continue
+		}
+		for _, p := range report.Problems {
+			fmt.Printf("%s: %s\n", name, p)
+		}
+		fmt.Printf("%s: %d entries, %d problems, last entry %d %s\n",
+			name, report.Entries, len(report.Problems), report.LastSeq, report.LastHash)
+		if !report.OK() && status == 0 {
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func readKey(file string) ([]byte, error) {
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	if key := os.Getenv("AUDIT_KEY"); key != "" {
+		return []byte(key), nil
+	}
+	return nil, errors.New("no key: use -key-file or set AUDIT_KEY")
+}
+
+func verify(name string, key []byte) (*audit.Report, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return audit.Verify(r, key)
+}
@@ -0,0 +1,230 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+)
+
+// ProblemKind is the kind of a [Problem] found by [Verify].
+type ProblemKind int
+
+const (
+	// Malformed is a line that is not an audit entry.
+	Malformed ProblemKind = iota + 1
+	// Modified is an entry whose hash does not match its content or the
+	// hash of the previous entry.
+	Modified
+	// Gap is a range of sequence numbers missing from the log.
+	Gap
+	// Reordered is an entry following an entry with a higher sequence
+	// number.
+	Reordered
+	// Duplicate is an entry with the sequence number of an earlier entry.
+	Duplicate
+)
+
+// String returns the name of k.
+func (k ProblemKind) String() string {
+	switch k {
+	case Malformed:
+		return "malformed"
+	case Modified:
+		return "modified"
+	case Gap:
+		return "gap"
+	case Reordered:
+		return "reordered"
+	case Duplicate:
+		return "duplicate"
+	default:
+		return "unknown"
+	}
+}
+
+// Problem is an inconsistency found by [Verify].
+type Problem struct {
+	Kind ProblemKind
+
+	// Line is the line number of the entry, starting at 1. It is 0 for
+	// gaps.
+	Line int
+
+	// Seq is the sequence number of the entry, or the first missing
+	// sequence number of a gap.
+	Seq uint64
+
+	// LastSeq is the last missing sequence number of a gap.
+	LastSeq uint64
+}
+
+// String describes p.
+func (p Problem) String() string {
+	switch {
+	case p.Kind == Gap && p.Seq == p.LastSeq:
+		return fmt.Sprintf("gap: entry %d is missing", p.Seq)
+	case p.Kind == Gap:
+		return fmt.Sprintf("gap: entries %d to %d are missing", p.Seq, p.LastSeq)
+	case p.Kind == Malformed:
+		return fmt.Sprintf("line %d: malformed entry", p.Line)
+	default:
+		return fmt.Sprintf("line %d: %s entry %d", p.Line, p.Kind, p.Seq)
+	}
+}
+
+// Report is the result of [Verify].
+type Report struct {
+	// Entries is the number of entries read.
+	Entries int
+
+	// LastSeq and LastHash are the highest sequence number and its hash.
+	// Compare them with a copy kept elsewhere to detect entries removed
+	// from the end of the log.
+	LastSeq  uint64
+	LastHash string
+
+	// Problems are the inconsistencies found, in the order they were
+	// found, followed by the gaps.
+	Problems []Problem
+}
+
+// OK reports whether no problems were found.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// record is an entry of an audit log.
+type record struct {
+	seq  uint64
+	hash string
+	body []byte // the line up to and including the sequence number
+}
+
+// hashSuffixLen is the length of `,"hash":"<hex>"}`.
+const hashSuffixLen = len(`,"hash":""}`) + 2*sha256.Size
+
+func parseRecord(line []byte) (record, bool) {
+	if len(line) < hashSuffixLen || !bytes.HasPrefix(line[len(line)-hashSuffixLen:], []byte(`,"hash":"`)) {
+		return record{}, false
+	}
+	var fields struct {
+		Seq  *uint64 `json:"seq"`
+		Hash string  `json:"hash"`
+	}
+	if err := json.Unmarshal(line, &fields); err != nil || fields.Seq == nil || *fields.Seq == 0 || len(fields.Hash) != 2*sha256.Size {
+		return record{}, false
+	}
+	body := line[:len(line)-hashSuffixLen]
+	if !bytes.HasSuffix(body, fmt.Appendf(nil, `"seq":%d`, *fields.Seq)) {
+		return record{}, false
+	}
+	return record{seq: *fields.Seq, hash: fields.Hash, body: body}, true
+}
+
+// Verify reads an audit log written by a [Logger] with key from r and
+// reports entries that are malformed, modified, reordered, duplicated or
+// missing. The returned error is only non-nil if r cannot be read.
+//
+// Each entry is verified against the hash of the entry with the previous
+// sequence number, wherever it is in the log, so that a reordered entry is
+// not also reported as modified. An entry whose predecessor is missing
+// cannot be verified; the missing entries are reported as a gap. Verify
+// keeps the hashes of all entries in memory.
+func Verify(r io.Reader, key []byte) (*Report, error) {
+	v := &verifier{
+		mac:     hmac.New(sha256.New, key),
+		hashes:  make(map[uint64]string),
+		pending: make(map[uint64][]pendingRecord),
+	}
+	br := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			v.add(lineNo, line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v.finish(), nil
+}
+
+type pendingRecord struct {
+	line int
+	rec  record
+}
+
+type verifier struct {
+	report  Report
+	mac     hash.Hash
+	hashes  map[uint64]string
+	pending map[uint64][]pendingRecord // records waiting for the hash of their predecessor
+}
+
+func (v *verifier) add(line int, b []byte) {
+	rec, ok := parseRecord(b)
+	if !ok {
+		v.problem(Malformed, line, 0)
+		return
+	}
+	v.report.Entries++
+	if _, ok := v.hashes[rec.seq]; ok {
+		v.problem(Duplicate, line, rec.seq)
+		return
+	}
+	if rec.seq < v.report.LastSeq {
+		v.problem(Reordered, line, rec.seq)
+	} else {
+		v.report.LastSeq, v.report.LastHash = rec.seq, rec.hash
+	}
+	v.hashes[rec.seq] = rec.hash
+
+	switch prev, ok := v.hashes[rec.seq-1]; {
+	case rec.seq == 1:
+		v.verify(line, rec, "")
+	case ok:
+		v.verify(line, rec, prev)
+	default:
+		v.pending[rec.seq-1] = append(v.pending[rec.seq-1], pendingRecord{line, rec})
+	}
+	// Verify the records that were waiting for this one.
+	for _, p := range v.pending[rec.seq] {
+		v.verify(p.line, p.rec, rec.hash)
+	}
+	delete(v.pending, rec.seq)
+}
+
+func (v *verifier) verify(line int, rec record, prev string) {
+	if !hmac.Equal([]byte(rec.hash), chainHash(v.mac, []byte(prev), rec.body)) {
+		v.problem(Modified, line, rec.seq)
+	}
+}
+
+func (v *verifier) problem(kind ProblemKind, line int, seq uint64) {
+	v.report.Problems = append(v.report.Problems, Problem{Kind: kind, Line: line, Seq: seq})
+}
+
+func (v *verifier) finish() *Report {
+	seqs := make([]uint64, 0, len(v.hashes))
+	for seq := range v.hashes {
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	next := uint64(1)
+	for _, seq := range seqs {
+		if seq > next {
+			v.report.Problems = append(v.report.Problems, Problem{Kind: Gap, Seq: next, LastSeq: seq - 1})
+		}
+		next = seq + 1
+	}
+	return &v.report
+}
@@ -0,0 +1,78 @@
+package audit_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/audit"
+)
+
+// auditLines returns the lines of an audit log of n entries.
+func auditLines(t *testing.T, n int) []string {
+	t.Helper()
+	var out syncBuffer
+	logger, err := audit.New(&out, &audit.Options{Key: key, Formatter: &logrus.JSONFormatter{DisableTimestamp: true}})
+	require.NoError(t, err)
+	for i := range n {
+		logger.WithField("i", i).Info("event")
+	}
+	lines := strings.SplitAfter(out.String(), "\n")
+	return lines[:len(lines)-1]
+}
+
+func verify(t *testing.T, lines []string) []audit.Problem {
+	t.Helper()
+	report, err := audit.Verify(strings.NewReader(strings.Join(lines, "")), key)
+	require.NoError(t, err)
+	return report.Problems
+}
+
+func TestVerifyModified(t *testing.T) {
+	lines := auditLines(t, 4)
+	lines[1] = strings.Replace(lines[1], `"i":1`, `"i":7`, 1)
+	assert.Equal(t, []audit.Problem{{Kind: audit.Modified, Line: 2, Seq: 2}}, verify(t, lines))
+}
+
+func TestVerifyGap(t *testing.T) {
+	lines := auditLines(t, 6)
+	lines = append(lines[:1], lines[3:]...)
+	problems := verify(t, lines)
+	assert.Equal(t, []audit.Problem{{Kind: audit.Gap, Seq: 2, LastSeq: 3}}, problems)
+	assert.Equal(t, "gap: entries 2 to 3 are missing", problems[0].String())
+}
+
+func TestVerifyReordered(t *testing.T) {
+	lines := auditLines(t, 4)
+	lines[1], lines[2] = lines[2], lines[1]
+	problems := verify(t, lines)
+	assert.Equal(t, []audit.Problem{{Kind: audit.Reordered, Line: 3, Seq: 2}}, problems)
+	assert.Equal(t, "line 3: reordered entry 2", problems[0].String())
+}
+
+func TestVerifyDuplicateAndMalformed(t *testing.T) {
+	lines := auditLines(t, 3)
+	lines = append(lines, "\n", lines[1], "not json\n", `{"level":"info"}`+"\n")
+	assert.Equal(t, []audit.Problem{
+		{Kind: audit.Duplicate, Line: 5, Seq: 2},
+		{Kind: audit.Malformed, Line: 6},
+		{Kind: audit.Malformed, Line: 7},
+	}, verify(t, lines))
+}
+
+func TestVerifyForgedHash(t *testing.T) {
+	// Replacing an entry and its hash without the key breaks the chain.
+	forged := auditLines(t, 3)
+	var other syncBuffer
+	logger, err := audit.New(&other, &audit.Options{Key: []byte("guess"), Formatter: &logrus.JSONFormatter{DisableTimestamp: true}})
+	require.NoError(t, err)
+	logger.WithField("i", 0).Info("event")
+	forged[0] = other.String()
+	assert.Equal(t, []audit.Problem{
+		{Kind: audit.Modified, Line: 1, Seq: 1},
+		{Kind: audit.Modified, Line: 2, Seq: 2},
+	}, verify(t, forged))
+}
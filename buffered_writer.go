@@ -0,0 +1,252 @@
+package logrus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LevelWriter is an [io.Writer] that is told the level of the entry being
+// written. A [Logger] calls WriteLevel instead of Write for its Out and
+// sinks if they implement LevelWriter.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level Level, p []byte) (n int, err error)
+}
+
+// writeLevel writes p to w, passing the level if w is a LevelWriter.
+func writeLevel(w io.Writer, level Level, p []byte) (int, error) {
+	if lw, ok := w.(LevelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return w.Write(p)
+}
+
+const (
+	// DefaultBufferedWriterSize is the buffer size of a [BufferedWriter] if
+	// BufferedWriterOptions.Size is 0.
+	DefaultBufferedWriterSize = 64 * 1024
+	// DefaultBufferedWriterFlushInterval is the flush interval of a
+	// [BufferedWriter] if BufferedWriterOptions.FlushInterval is 0.
+	DefaultBufferedWriterFlushInterval = time.Second
+)
+
+// BufferedWriterOptions are options for a [BufferedWriter].
+// A zero BufferedWriterOptions consists entirely of default values.
+type BufferedWriterOptions struct {
+	// Size is the size of the buffer. The buffer is flushed when it is
+	// full; larger writes bypass it. If 0, DefaultBufferedWriterSize is
+	// used.
+	Size int
+
+	// FlushInterval is the maximum time data stays in the buffer. If 0,
+	// DefaultBufferedWriterFlushInterval is used. If negative, the buffer
+	// is only flushed when it is full, by entries of FlushLevel, or by
+	// Flush.
+	FlushInterval time.Duration
+
+	// FlushLevel is the least severe level whose entries are flushed
+	// immediately. The zero value, PanicLevel, selects ErrorLevel.
+	FlushLevel Level
+
+	// Sync calls the Sync method of the underlying writer, if it has one
+	// such as *os.File, after every flush, so that flushed entries survive
+	// a crash of the operating system.
+	Sync bool
+}
+
+// BufferedWriter is a [LevelWriter] that coalesces entries into larger
+// writes to an underlying writer, such as a file. Entries are flushed when
+// the buffer is full, when the flush interval elapses, and immediately for
+// entries of the flush level or more severe:
+//
+//	w := logrus.NewBufferedWriter(file, &logrus.BufferedWriterOptions{Sync: true})
+//	defer w.Close()
+//	logger.SetOutput(w)
+//
+// Since entries are only written when the buffer is flushed, the buffers
+// of all open writers are flushed by an exit handler registered with
+// [RegisterExitHandler] before the program exits through [Exit] or a Fatal
+// entry. Call Close before returning from main.
+//
+// The exit handler keeps a reference to every writer until it is closed, so
+// Close must be called for every BufferedWriter that is no longer used;
+// otherwise the writer, its buffer and the underlying writer are never
+// garbage collected.
+type BufferedWriter struct {
+	w    io.Writer
+	opts BufferedWriterOptions
+
+	mu     sync.Mutex
+	buf    []byte
+	timer  *time.Timer
+	closed bool
+}
+
+var _ LevelWriter = (*BufferedWriter)(nil)
+
+var (
+	// bufferedWriters are the open buffered writers, flushed by the exit
+	// handler.
+	bufferedWritersMu      sync.Mutex
+	bufferedWriters        = make(map[*BufferedWriter]struct{})
+	registerBufferedWriter sync.Once
+)
+
+// flushBufferedWriters flushes the open buffered writers before the program
+// exits.
+func flushBufferedWriters() {
+	bufferedWritersMu.Lock()
+	open := make([]*BufferedWriter, 0, len(bufferedWriters))
+	for b := range bufferedWriters {
+		open = append(open, b)
+	}
+	bufferedWritersMu.Unlock()
+
+	for _, b := range open {
+		_ = b.Flush()
+	}
+}
+
+// NewBufferedWriter returns a BufferedWriter writing to w. If opts is nil,
+// the default options are used. The writer must be closed when it is no
+// longer used.
+//
+// NewBufferedWriter panics if w is nil.
+func NewBufferedWriter(w io.Writer, opts *BufferedWriterOptions) *BufferedWriter {
+	if w == nil {
+		panic("cannot create buffered writer from nil writer")
+	}
+	if opts == nil {
+		opts = &BufferedWriterOptions{}
+	}
+	b := &BufferedWriter{w: w, opts: *opts}
+	if b.opts.Size <= 0 {
+		b.opts.Size = DefaultBufferedWriterSize
+	}
+	if b.opts.FlushInterval == 0 {
+		b.opts.FlushInterval = DefaultBufferedWriterFlushInterval
+	}
+	if b.opts.FlushLevel == PanicLevel {
+		b.opts.FlushLevel = ErrorLevel
+	}
+	registerBufferedWriter.Do(func() { RegisterExitHandler(flushBufferedWriters) })
+	bufferedWritersMu.Lock()
+	bufferedWriters[b] = struct{}{}
+	bufferedWritersMu.Unlock()
+	return b
+}
+
+// Write buffers p.
+func (b *BufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.write(p, false)
+}
+
+// WriteLevel buffers p and flushes the buffer if level is at least as
+// severe as the flush level.
+func (b *BufferedWriter) WriteLevel(level Level, p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.write(p, level.AtLeast(b.opts.FlushLevel))
+}
+
+// write buffers p, or writes it directly if the writer is closed or p does
+// not fit into the buffer. It must be called with b.mu held.
+func (b *BufferedWriter) write(p []byte, flush bool) (int, error) {
+	if b.closed {
+		n, err := b.w.Write(p)
+		if err == nil {
+			err = b.sync()
+		}
+		return n, err
+	}
+
+	// The buffer is empty after flushing even if writing it failed, so p is
+	// still written or buffered. A failed flush is reported along with the
+	// result for p.
+	var flushErr error
+	if len(b.buf)+len(p) > b.opts.Size {
+		flushErr = b.flush()
+	}
+	if len(p) >= b.opts.Size {
+		n, err := b.w.Write(p)
+		if err == nil {
+			err = b.sync()
+		}
+		return n, errors.Join(flushErr, err)
+	}
+
+	if b.buf == nil {
+		b.buf = make([]byte, 0, b.opts.Size)
+	}
+	b.buf = append(b.buf, p...)
+	if flush {
+		return len(p), errors.Join(flushErr, b.flush())
+	}
+	if b.timer == nil && b.opts.FlushInterval > 0 {
+		b.timer = time.AfterFunc(b.opts.FlushInterval, b.flushTimer)
+	}
+	return len(p), flushErr
+}
+
+func (b *BufferedWriter) flushTimer() {
+	if err := b.Flush(); err != nil {
+		_, _ = fmt.Fprintln(Stderr(), "Failed to flush log:", err)
+	}
+}
+
+// Flush writes the buffered data to the underlying writer.
+func (b *BufferedWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flush()
+}
+
+// flush must be called with b.mu held. Buffered data is discarded even if
+// writing it fails, so that a failing writer cannot make the buffer grow.
+func (b *BufferedWriter) flush() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.buf) == 0 {
+		return nil
+	}
+	_, err := b.w.Write(b.buf)
+	b.buf = b.buf[:0]
+	if err != nil {
+		return err
+	}
+	return b.sync()
+}
+
+// sync syncs the underlying writer if enabled. It must be called with b.mu
+// held.
+func (b *BufferedWriter) sync() error {
+	if !b.opts.Sync {
+		return nil
+	}
+	if s, ok := b.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Close flushes the buffer and stops buffering; later writes go directly
+// to the underlying writer. It does not close the underlying writer.
+func (b *BufferedWriter) Close() error {
+	bufferedWritersMu.Lock()
+	delete(bufferedWriters, b)
+	bufferedWritersMu.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.flush()
+	b.closed = true
+	b.buf = nil
+	return err
+}
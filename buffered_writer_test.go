@@ -0,0 +1,152 @@
+package logrus_test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/sirupsen/logrus"
+)
+
+// syncBuffer is a concurrency-safe buffer counting Write and Sync calls.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+	syncs  int
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writes++
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.syncs++
+	return nil
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) counts() (writes, syncs int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.writes, b.syncs
+}
+
+func newBufferedLogger(out *syncBuffer, opts *BufferedWriterOptions) (*Logger, *BufferedWriter) {
+	w := NewBufferedWriter(out, opts)
+	logger := New()
+	logger.SetOutput(w)
+	logger.SetFormatter(&TextFormatter{DisableColors: true, DisableTimestamp: true})
+	return logger, w
+}
+
+func TestBufferedWriter(t *testing.T) {
+	out := &syncBuffer{}
+	logger, w := newBufferedLogger(out, &BufferedWriterOptions{FlushInterval: -1, Sync: true})
+
+	logger.Info("one")
+	logger.Warn("two")
+	assert.Empty(t, out.String())
+
+	// Errors are flushed immediately, together with the buffered entries.
+	logger.Error("three")
+	assert.Equal(t, "level=info msg=one\nlevel=warning msg=two\nlevel=error msg=three\n", out.String())
+	writes, syncs := out.counts()
+	assert.Equal(t, 1, writes)
+	assert.Equal(t, 1, syncs)
+
+	logger.Info("four")
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "level=info msg=four\n", out.String()[len("level=info msg=one\nlevel=warning msg=two\nlevel=error msg=three\n"):])
+
+	require.NoError(t, w.Close())
+	logger.Info("after close")
+	assert.Contains(t, out.String(), "msg=\"after close\"")
+}
+
+func TestBufferedWriterSize(t *testing.T) {
+	out := &syncBuffer{}
+	w := NewBufferedWriter(out, &BufferedWriterOptions{Size: 8, FlushInterval: -1})
+
+	_, err := w.Write([]byte("12345"))
+	require.NoError(t, err)
+	assert.Empty(t, out.String())
+
+	_, err = w.Write([]byte("6789"))
+	require.NoError(t, err)
+	assert.Equal(t, "12345", out.String())
+
+	// Writes larger than the buffer bypass it.
+	_, err = w.Write([]byte("a long write"))
+	require.NoError(t, err)
+	assert.Equal(t, "123456789a long write", out.String())
+	writes, syncs := out.counts()
+	assert.Equal(t, 3, writes)
+	assert.Zero(t, syncs)
+}
+
+func TestBufferedWriterFlushInterval(t *testing.T) {
+	out := &syncBuffer{}
+	logger, w := newBufferedLogger(out, &BufferedWriterOptions{FlushInterval: 10 * time.Millisecond})
+	defer w.Close()
+
+	logger.Info("eventually")
+	assert.Eventually(t, func() bool { return out.String() != "" }, time.Second, time.Millisecond)
+}
+
+func TestBufferedWriterFlushedOnFatal(t *testing.T) {
+	out := &syncBuffer{}
+	logger, w := newBufferedLogger(out, &BufferedWriterOptions{FlushInterval: -1, FlushLevel: PanicLevel})
+	defer w.Close()
+	logger.ExitFunc = func(int) {}
+
+	logger.Info("before fatal")
+	assert.Empty(t, out.String())
+	logger.Fatal("fatal")
+	assert.Equal(t, "level=info msg=\"before fatal\"\nlevel=fatal msg=fatal\n", out.String())
+}
+
+// failOnceWriter fails its first write.
+type failOnceWriter struct {
+	bytes.Buffer
+	failed bool
+}
+
+func (w *failOnceWriter) Write(p []byte) (int, error) {
+	if !w.failed {
+		w.failed = true
+		return 0, errors.New("disk full")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestBufferedWriterFlushError(t *testing.T) {
+	out := &failOnceWriter{}
+	w := NewBufferedWriter(out, &BufferedWriterOptions{Size: 8, FlushInterval: -1})
+	defer w.Close()
+
+	_, err := w.Write([]byte("12345"))
+	require.NoError(t, err)
+
+	// The failed flush loses the buffered data, but not the new write.
+	n, err := w.Write([]byte("6789"))
+	require.Error(t, err)
+	assert.Equal(t, 4, n)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "6789", out.String())
+}
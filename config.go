@@ -0,0 +1,517 @@
+package logrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Config is a declarative configuration of a [Logger], for example read
+// from a file and overridden by environment variables and flags:
+//
+//	var cfg logrus.Config
+//	if err := cfg.LoadJSON(data); err != nil {
+//		return err
+//	}
+//	if err := cfg.LoadEnv(); err != nil {
+//		return err
+//	}
+//	cfg.RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//	if err := cfg.Apply(logger); err != nil {
+//		return err
+//	}
+//
+// In JSON, a configuration looks like this:
+//
+//	{
+//		"level": "debug",
+//		"format": "json",
+//		"format_options": {"TimestampFormat": "2006-01-02", "FieldMap": {"msg": "message"}},
+//		"outputs": ["stderr", "/var/log/app.log"],
+//		"report_caller": true,
+//		"sampling": {"rate": 0.1},
+//		"hooks": [{"type": "syslog", "options": {"tag": "app"}}]
+//	}
+//
+// Hook types are registered by the packages providing the hooks, such as
+// "syslog" by hooks/syslog and "fluent" by hooks/fluent, which must be
+// imported for their types to be available.
+//
+// Package yamlconfig loads configurations from YAML. Fields that are not
+// set leave the corresponding setting of the logger unchanged.
+type Config struct {
+	// Level is the level of the logger.
+	Level *Level `json:"level,omitempty" yaml:"level,omitempty"`
+
+	// Format is the name of the formatter, "text", "logfmt" (a
+	// [TextFormatter] without colors and with full timestamps), "json" or a
+	// name registered with [RegisterFormatterFactory].
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+
+	// FormatOptions are the options of the formatter. For "text", "logfmt"
+	// and "json", they are the fields of [TextFormatter] and
+	// [JSONFormatter], such as TimestampFormat, FieldMap or PrettyPrint.
+	// FormatOptions require Format.
+	FormatOptions map[string]any `json:"format_options,omitempty" yaml:"format_options,omitempty"`
+
+	// Outputs are the outputs of the logger: "stdout", "stderr", "discard"
+	// or the path of a file, which is created if needed and appended to.
+	Outputs []string `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+
+	// ReportCaller sets whether the caller is logged.
+	ReportCaller *bool `json:"report_caller,omitempty" yaml:"report_caller,omitempty"`
+
+	// Sampling samples the entries written to Outputs. It requires Outputs.
+	Sampling *SamplingConfig `json:"sampling,omitempty" yaml:"sampling,omitempty"`
+
+	// Hooks are the hooks added to the logger. They replace the hooks added
+	// by an earlier Apply.
+	Hooks []HookConfig `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+}
+
+// SamplingConfig configures the sampling of entries.
+type SamplingConfig struct {
+	// Rate is the fraction of the entries written, from 0 to 1.
+	Rate float64 `json:"rate" yaml:"rate"`
+
+	// Level is the least severe level that is never sampled. The zero
+	// value, PanicLevel, means WarnLevel.
+	Level Level `json:"level,omitempty" yaml:"level,omitempty"`
+}
+
+// HookConfig configures a hook.
+type HookConfig struct {
+	// Type is the name the hook was registered with using
+	// [RegisterHookFactory].
+	Type string `json:"type" yaml:"type"`
+
+	// Options are passed to the factory of the hook.
+	Options map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// A FormatterFactory creates a formatter from the options of a [Config].
+type FormatterFactory func(options map[string]any) (Formatter, error)
+
+// A HookFactory creates a hook from the options of a [HookConfig].
+type HookFactory func(options map[string]any) (Hook, error)
+
+var (
+	factoriesMu        sync.RWMutex
+	formatterFactories = map[string]FormatterFactory{
+		"text": newTextFormatter,
+		"logfmt": func(options map[string]any) (Formatter, error) {
+			f := &TextFormatter{DisableColors: true, FullTimestamp: true}
+			return f, DecodeOptions(options, f)
+		},
+		"json": func(options map[string]any) (Formatter, error) {
+			f := &JSONFormatter{}
+			return f, DecodeOptions(options, f)
+		},
+	}
+	hookFactories = map[string]HookFactory{}
+)
+
+func newTextFormatter(options map[string]any) (Formatter, error) {
+	f := &TextFormatter{}
+	return f, DecodeOptions(options, f)
+}
+
+// RegisterFormatterFactory makes a formatter available to [Config] by name.
+// It panics if factory is nil or a formatter with name is already
+// registered.
+func RegisterFormatterFactory(name string, factory FormatterFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("logrus: RegisterFormatterFactory factory is nil")
+	}
+	if _, dup := formatterFactories[name]; dup {
+		panic("logrus: RegisterFormatterFactory called twice for " + name)
+	}
+	formatterFactories[name] = factory
+}
+
+// RegisterHookFactory makes a hook available to [Config] by name. Packages
+// providing hooks usually register them in an init function, so that they
+// are available after importing the package. It panics if factory is nil or
+// a hook with name is already registered.
+func RegisterHookFactory(name string, factory HookFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("logrus: RegisterHookFactory factory is nil")
+	}
+	if _, dup := hookFactories[name]; dup {
+		panic("logrus: RegisterHookFactory called twice for " + name)
+	}
+	hookFactories[name] = factory
+}
+
+// DecodeOptions decodes the options of a formatter or hook into the struct
+// v as if they were a JSON object, matching the keys to the field names or
+// json tags of v. Unknown options are an error.
+func DecodeOptions(options map[string]any, v any) error {
+	if len(options) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// LoadJSON sets the fields of c present in the JSON object data.
+func (c *Config) LoadJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(c)
+}
+
+// LoadEnv sets the fields of c from the environment variables that are
+// set:
+//
+//   - LOGRUS_LEVEL sets Level.
+//   - LOGRUS_FORMAT sets Format.
+//   - LOGRUS_OUTPUT sets Outputs to a comma-separated list.
+//   - LOGRUS_REPORT_CALLER sets ReportCaller.
+//   - LOGRUS_SAMPLE_RATE sets the Rate of Sampling.
+func (c *Config) LoadEnv() error {
+	vars := []struct {
+		name string
+		set  func(string) error
+	}{
+		{"LOGRUS_LEVEL", c.setLevel},
+		{"LOGRUS_FORMAT", c.setFormat},
+		{"LOGRUS_OUTPUT", c.setOutputs},
+		{"LOGRUS_REPORT_CALLER", c.setReportCaller},
+		{"LOGRUS_SAMPLE_RATE", c.setSampleRate},
+	}
+	for _, v := range vars {
+		if s, ok := os.LookupEnv(v.name); ok {
+			if err := v.set(s); err != nil {
+				return fmt.Errorf("%s: %w", v.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RegisterFlags defines flags in fs setting the fields of c when fs is
+// parsed: -log-level, -log-format, -log-output (a comma-separated list),
+// -log-report-caller and -log-sample-rate.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.Func("log-level", levelUsage(), c.setLevel)
+	fs.Func("log-format", formatUsage(), c.setFormat)
+	fs.Func("log-output", "comma-separated log `outputs`: stdout, stderr, discard or file paths", c.setOutputs)
+	fs.BoolFunc("log-report-caller", "log the calling function", c.setReportCaller)
+	fs.Func("log-sample-rate", "`fraction` of the entries less severe than warnings that are logged", c.setSampleRate)
+}
+
+func (c *Config) setLevel(s string) error {
+	level, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	c.Level = &level
+	return nil
+}
+
+func (c *Config) setFormat(s string) error {
+	if _, err := lookupFormatterFactory(s); err != nil {
+		return err
+	}
+	c.Format = s
+	return nil
+}
+
+func (c *Config) setOutputs(s string) error {
+	c.Outputs = nil
+	for _, out := range strings.Split(s, ",") {
+		if out = strings.TrimSpace(out); out != "" {
+			c.Outputs = append(c.Outputs, out)
+		}
+	}
+	return nil
+}
+
+func (c *Config) setReportCaller(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	c.ReportCaller = &b
+	return nil
+}
+
+func (c *Config) setSampleRate(s string) error {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	if c.Sampling == nil {
+		c.Sampling = &SamplingConfig{}
+	}
+	c.Sampling.Rate = rate
+	return nil
+}
+
+// Apply configures logger according to c. Either all settings are applied
+// or, if an error is returned, none.
+//
+// The files of Outputs are owned by the logger and closed by
+// [Logger.Close], or by a later Apply that no longer uses them. The hooks
+// of an earlier Apply are removed and, if they implement [io.Closer],
+// closed.
+func (c *Config) Apply(logger *Logger) error {
+	var formatter Formatter
+	if c.Format != "" {
+		factory, err := lookupFormatterFactory(c.Format)
+		if err != nil {
+			return fmt.Errorf("logrus: %w", err)
+		}
+		if formatter, err = factory(c.FormatOptions); err != nil {
+			return fmt.Errorf("logrus: format %q: %w", c.Format, err)
+		}
+	} else if len(c.FormatOptions) > 0 {
+		return errors.New("logrus: format options without format")
+	}
+	if c.Sampling != nil {
+		if len(c.Outputs) == 0 {
+			return errors.New("logrus: sampling without outputs")
+		}
+		if c.Sampling.Rate < 0 || c.Sampling.Rate > 1 {
+			return fmt.Errorf("logrus: invalid sample rate %v", c.Sampling.Rate)
+		}
+	}
+
+	logger.configMu.Lock()
+	defer logger.configMu.Unlock()
+	logger.mu.Lock()
+	oldOut := logger.configOut
+	logger.mu.Unlock()
+	var oldFiles map[string]*configFile
+	if oldOut != nil {
+		oldFiles = oldOut.files
+	}
+	out, err := c.openOutputs(oldFiles)
+	if err != nil {
+		return err
+	}
+	hooks, err := c.newHooks()
+	if err != nil {
+		if out != nil {
+			_ = out.release()
+		}
+		return err
+	}
+
+	logger.mu.Lock()
+	if c.Level != nil {
+		logger.SetLevel(*c.Level)
+	}
+	if formatter != nil {
+		logger.Formatter = formatter
+	}
+	// The output of an earlier Apply is released even if Out was replaced
+	// since, for example with SetOutput.
+	if out != nil {
+		logger.Out = out
+		logger.configOut = out
+	}
+	if c.ReportCaller != nil {
+		logger.ReportCaller = *c.ReportCaller
+	}
+	oldHooks := logger.configHooks
+	for _, hook := range oldHooks {
+		logger.Hooks.Remove(hook)
+		logger.hookFailures.reset(hook)
+	}
+	if logger.Hooks == nil && len(hooks) > 0 {
+		logger.Hooks = make(LevelHooks)
+	}
+	for _, hook := range hooks {
+		logger.Hooks.Add(hook)
+	}
+	logger.configHooks = hooks
+	logger.updateCaptureLevel()
+	logger.mu.Unlock()
+
+	if out != nil && oldOut != nil {
+		_ = oldOut.release()
+	}
+	for _, hook := range oldHooks {
+		if c, ok := hook.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+	return nil
+}
+
+// openOutputs returns the output for c.Outputs, reusing the already open
+// files. It returns nil if c.Outputs is empty.
+func (c *Config) openOutputs(files map[string]*configFile) (*configOutput, error) {
+	if len(c.Outputs) == 0 {
+		return nil, nil
+	}
+	opened := make(map[string]*configFile)
+	writers := make([]io.Writer, 0, len(c.Outputs))
+	for _, name := range c.Outputs {
+		switch name {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "stderr":
+			writers = append(writers, os.Stderr)
+		case "discard":
+			writers = append(writers, io.Discard)
+		default:
+			f, ok := opened[name]
+			if !ok {
+				if f, ok = files[name]; ok {
+					f.refs.Add(1)
+				} else {
+					file, err := openOutput(name)
+					if err != nil {
+						releaseFiles(opened)
+						return nil, err
+					}
+					f = &configFile{File: file}
+					f.refs.Store(1)
+				}
+				opened[name] = f
+			}
+			writers = append(writers, f.File)
+		}
+	}
+
+	out := &configOutput{Writer: writers[0], files: opened}
+	if len(writers) == 1 {
+		out.terminal = writers[0]
+	} else {
+		out.Writer = io.MultiWriter(writers...)
+	}
+	if c.Sampling != nil {
+		level := c.Sampling.Level
+		if level == PanicLevel {
+			level = WarnLevel
+		}
+		out.Writer = &sampledWriter{out: out.Writer, rate: c.Sampling.Rate, level: level}
+	}
+	out.refs.Store(1)
+	return out, nil
+}
+
+// configOutput is the Out of a logger configured by [Config.Apply]. Log
+// calls that took a snapshot of it hold a reference, so that its files are
+// not closed by a later Apply or Logger.Close before they finished
+// writing.
+type configOutput struct {
+	io.Writer
+	terminal io.Writer // the output checked for a terminal
+	files    map[string]*configFile
+
+	// refs is 1 while the output is the logger's configOut, plus the
+	// number of log calls using it.
+	refs atomic.Int64
+}
+
+// configFile is a file shared by the outputs of successive applies of the
+// same path.
+type configFile struct {
+	*os.File
+	refs atomic.Int64 // the number of outputs using the file
+}
+
+func (o *configOutput) WriteLevel(level Level, p []byte) (int, error) {
+	return writeLevel(o.Writer, level, p)
+}
+
+func (o *configOutput) acquire() {
+	o.refs.Add(1)
+}
+
+// release releases a reference to the output, releasing its files once it
+// is unused. It returns the errors closing them.
+func (o *configOutput) release() error {
+	if o.refs.Add(-1) == 0 {
+		return releaseFiles(o.files)
+	}
+	return nil
+}
+
+func openOutput(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+}
+
+// releaseFiles releases a reference to each of files, closing the files
+// no output uses anymore.
+func releaseFiles(files map[string]*configFile) error {
+	var errs []error
+	for _, f := range files {
+		if f.refs.Add(-1) == 0 {
+			errs = append(errs, f.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) newHooks() ([]Hook, error) {
+	hooks := make([]Hook, 0, len(c.Hooks))
+	closeHooks := func() {
+		for _, hook := range hooks {
+			if c, ok := hook.(io.Closer); ok {
+				_ = c.Close()
+			}
+		}
+	}
+	for _, hc := range c.Hooks {
+		factoriesMu.RLock()
+		factory := hookFactories[hc.Type]
+		factoriesMu.RUnlock()
+		if factory == nil {
+			closeHooks()
+			return nil, fmt.Errorf("logrus: unknown hook type %q", hc.Type)
+		}
+		hook, err := factory(hc.Options)
+		if err != nil {
+			closeHooks()
+			return nil, fmt.Errorf("logrus: hook %q: %w", hc.Type, err)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// sampledWriter writes a fraction of the entries less severe than level.
+type sampledWriter struct {
+	out   io.Writer
+	rate  float64
+	level Level
+}
+
+func (w *sampledWriter) Write(p []byte) (int, error) {
+	return w.out.Write(p)
+}
+
+func (w *sampledWriter) WriteLevel(level Level, p []byte) (int, error) {
+	if !level.AtLeast(w.level) && rand.Float64() >= w.rate {
+		return len(p), nil
+	}
+	return writeLevel(w.out, level, p)
+}
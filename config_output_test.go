@@ -0,0 +1,28 @@
+package logrus
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigApplyReleasesReplacedOutput(t *testing.T) {
+	dir := t.TempDir()
+	logger := New()
+	require.NoError(t, (&Config{Outputs: []string{filepath.Join(dir, "a.log")}}).Apply(logger))
+	first := logger.configOut.files[filepath.Join(dir, "a.log")].File
+
+	// The output of the first Apply is closed by the next one even though
+	// it is no longer Out.
+	logger.SetOutput(io.Discard)
+	require.NoError(t, (&Config{Outputs: []string{filepath.Join(dir, "b.log")}}).Apply(logger))
+	_, err := first.Write([]byte("x"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+
+	require.NoError(t, logger.Close())
+	assert.Nil(t, logger.configOut)
+}
@@ -0,0 +1,220 @@
+package logrus_test
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// closingTestHook is a test.Hook recording whether it was closed.
+type closingTestHook struct {
+	*test.Hook
+	closed bool
+}
+
+func (h *closingTestHook) Close() error {
+	h.closed = true
+	return nil
+}
+
+var configHooks []*closingTestHook
+
+func init() {
+	RegisterHookFactory("config-test", func(options map[string]any) (Hook, error) {
+		var opts struct{ Name string }
+		if err := DecodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		hook := &closingTestHook{Hook: test.NewLocal(New())}
+		configHooks = append(configHooks, hook)
+		return hook, nil
+	})
+}
+
+func TestConfigApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	var cfg Config
+	require.NoError(t, cfg.LoadJSON([]byte(`{
+		"level": "debug",
+		"format": "json",
+		"format_options": {"DisableTimestamp": true, "FieldMap": {"msg": "message"}},
+		"outputs": ["stderr"],
+		"report_caller": true
+	}`)))
+	cfg.Outputs = []string{path}
+
+	logger := New()
+	require.NoError(t, cfg.Apply(logger))
+	assert.Equal(t, DebugLevel, logger.GetLevel())
+	assert.True(t, logger.ReportCaller)
+	logger.Debug("configured")
+	require.NoError(t, logger.Close())
+	assert.Equal(t, os.Stderr, logger.Out, "Close does not leave closed files as Out")
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(b, &m))
+	assert.Equal(t, "configured", m["message"])
+	assert.Contains(t, m, "func")
+}
+
+func TestConfigUnsetFieldsUnchanged(t *testing.T) {
+	logger := New()
+	logger.Out = io.Discard
+	formatter := logger.Formatter
+	require.NoError(t, (&Config{}).Apply(logger))
+	assert.Equal(t, InfoLevel, logger.GetLevel())
+	assert.Same(t, formatter, logger.Formatter)
+	assert.Equal(t, io.Discard, logger.Out)
+}
+
+func TestConfigLoadEnv(t *testing.T) {
+	t.Setenv("LOGRUS_LEVEL", "warn")
+	t.Setenv("LOGRUS_FORMAT", "json")
+	t.Setenv("LOGRUS_OUTPUT", "stdout, discard")
+	t.Setenv("LOGRUS_REPORT_CALLER", "true")
+	t.Setenv("LOGRUS_SAMPLE_RATE", "0.5")
+
+	cfg := Config{Format: "text"}
+	require.NoError(t, cfg.LoadEnv())
+	require.NotNil(t, cfg.Level)
+	assert.Equal(t, WarnLevel, *cfg.Level)
+	assert.Equal(t, "json", cfg.Format)
+	assert.Equal(t, []string{"stdout", "discard"}, cfg.Outputs)
+	assert.True(t, *cfg.ReportCaller)
+	assert.Equal(t, &SamplingConfig{Rate: 0.5}, cfg.Sampling)
+
+	t.Setenv("LOGRUS_LEVEL", "loud")
+	assert.ErrorContains(t, cfg.LoadEnv(), "LOGRUS_LEVEL")
+}
+
+func TestConfigRegisterFlags(t *testing.T) {
+	cfg := Config{Outputs: []string{"stderr"}}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"-log-level", "trace", "-log-format=json", "-log-output=discard", "-log-report-caller"}))
+	assert.Equal(t, TraceLevel, *cfg.Level)
+	assert.Equal(t, "json", cfg.Format)
+	assert.Equal(t, []string{"discard"}, cfg.Outputs)
+	assert.True(t, *cfg.ReportCaller)
+}
+
+func TestConfigSampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg := Config{
+		Format:        "text",
+		FormatOptions: map[string]any{"DisableTimestamp": true},
+		Outputs:       []string{path},
+		Sampling:      &SamplingConfig{Rate: 0},
+	}
+	logger := New()
+	require.NoError(t, cfg.Apply(logger))
+	logger.Info("sampled out")
+	logger.Warn("kept")
+	require.NoError(t, logger.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "level=warning msg=kept\n", string(b))
+}
+
+func TestConfigHooks(t *testing.T) {
+	configHooks = nil
+	logger := New()
+	logger.Out = io.Discard
+	cfg := Config{Hooks: []HookConfig{{Type: "config-test", Options: map[string]any{"name": "first"}}}}
+	require.NoError(t, cfg.Apply(logger))
+	require.NoError(t, cfg.Apply(logger))
+	require.Len(t, configHooks, 2)
+	assert.True(t, configHooks[0].closed, "hooks of an earlier Apply are closed")
+	assert.False(t, configHooks[1].closed)
+
+	logger.Info("hooked")
+	assert.Empty(t, configHooks[0].AllEntries())
+	assert.Len(t, configHooks[1].AllEntries(), 1)
+
+	require.NoError(t, logger.Close())
+	assert.True(t, configHooks[1].closed)
+}
+
+func TestConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"unknown format":   {Format: "xml"},
+		"unknown option":   {Format: "json", FormatOptions: map[string]any{"Indent": 2}},
+		"options only":     {FormatOptions: map[string]any{"DisableTimestamp": true}},
+		"sampling only":    {Sampling: &SamplingConfig{Rate: 0.5}},
+		"invalid rate":     {Outputs: []string{"stderr"}, Sampling: &SamplingConfig{Rate: 2}},
+		"unknown hook":     {Hooks: []HookConfig{{Type: "carrier-pigeon"}}},
+		"bad hook options": {Hooks: []HookConfig{{Type: "config-test", Options: map[string]any{"color": "red"}}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			level := TraceLevel
+			cfg.Level = &level
+			logger := New()
+			assert.Error(t, cfg.Apply(logger))
+			assert.Equal(t, InfoLevel, logger.GetLevel(), "nothing is applied")
+		})
+	}
+
+	var cfg Config
+	assert.Error(t, cfg.LoadJSON([]byte(`{"levle": "debug"}`)))
+}
+
+func TestConfigApplyConcurrent(t *testing.T) {
+	// Every entry is logged with either configuration, never with the
+	// formatter of one and the output of the other.
+	dir := t.TempDir()
+	textPath, jsonPath := filepath.Join(dir, "text.log"), filepath.Join(dir, "json.log")
+	configs := []Config{
+		{Format: "text", Outputs: []string{textPath}},
+		{Format: "json", Outputs: []string{jsonPath}},
+	}
+	logger := New()
+	require.NoError(t, configs[0].Apply(logger))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			assert.NoError(t, configs[i%2].Apply(logger))
+		}
+	}()
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			require.NoError(t, logger.Close())
+			for _, line := range readLines(t, textPath) {
+				assert.True(t, strings.HasPrefix(line, "time="), line)
+			}
+			for _, line := range readLines(t, jsonPath) {
+				assert.True(t, json.Valid([]byte(line)), line)
+			}
+			return
+		default:
+			logger.WithField("i", i).Info("concurrent")
+		}
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	if len(b) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
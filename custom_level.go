@@ -0,0 +1,175 @@
+package logrus
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CustomLevel describes a level registered with [RegisterLevel].
+type CustomLevel struct {
+	// Name is the name of the level, as returned by [Level.String] and
+	// accepted case-insensitively by [ParseLevel], for example "notice". It
+	// is converted to lower case when the level is registered.
+	Name string
+
+	// Severity is the position of the level on the severity scale, on which
+	// the built-in levels are at multiples of ten from [PanicLevel] at 0 to
+	// [TraceLevel] at 60, see [Level.Severity]. For example, a notice level
+	// between warnings and info entries has the severity 35.
+	Severity int
+
+	// Color is the ANSI escape sequence coloring the level in the output of
+	// [TextFormatter], for example "\x1b[35m" for magenta. If empty, the
+	// color of [Level.Builtin] is used.
+	Color string
+
+	// SyslogSeverity is the syslog severity the level is logged with, from
+	// 1 (alert) to 7 (debug) as defined by the priorities of package
+	// log/syslog. If 0, the syslog severity of [Level.Builtin] is used.
+	SyslogSeverity int
+
+	// SlogLevel is the log/slog level the level corresponds to. If nil, the
+	// slog level of [Level.Builtin] is used.
+	SlogLevel slog.Leveler
+}
+
+// levelRegistry holds the registered levels. It is replaced as a whole when
+// a level is registered, so that readers do not need to lock.
+type levelRegistry struct {
+	levels map[Level]CustomLevel
+	names  map[string]Level // lower case names
+	all    []Level          // all levels by severity, see Levels
+}
+
+var (
+	registerLevelMu sync.Mutex
+	customLevels    atomic.Pointer[levelRegistry]
+)
+
+// RegisterLevel registers a level in addition to the built-in levels and
+// returns it, for example:
+//
+//	var NoticeLevel, _ = logrus.RegisterLevel(logrus.CustomLevel{
+//		Name:           "notice",
+//		Severity:       35,
+//		Color:          "\x1b[35m",
+//		SyslogSeverity: int(syslog.LOG_NOTICE),
+//		SlogLevel:      slog.LevelInfo + 2,
+//	})
+//
+//	logger.Log(NoticeLevel, "disk usage at 80%")
+//
+// The level is inserted into the levels returned by [Levels] by severity.
+// RegisterLevel is meant to be called during initialization: hooks and
+// loggers created before a level is registered do not know about it.
+func RegisterLevel(level CustomLevel) (Level, error) {
+	name := strings.ToLower(level.Name)
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' }) {
+		return 0, fmt.Errorf("invalid level name %q", level.Name)
+	}
+	if level.Severity < 0 {
+		return 0, fmt.Errorf("invalid severity %d for level %q", level.Severity, level.Name)
+	}
+	if level.SyslogSeverity < 0 || level.SyslogSeverity > 7 {
+		return 0, fmt.Errorf("invalid syslog severity %d for level %q", level.SyslogSeverity, level.Name)
+	}
+
+	registerLevelMu.Lock()
+	defer registerLevelMu.Unlock()
+	if _, err := ParseLevel(name); err == nil || name == "unknown" {
+		return 0, fmt.Errorf("level %q already exists", level.Name)
+	}
+
+	reg := &levelRegistry{
+		levels: make(map[Level]CustomLevel),
+		names:  make(map[string]Level),
+	}
+	all := AllLevels
+	if old := customLevels.Load(); old != nil {
+		maps.Copy(reg.levels, old.levels)
+		maps.Copy(reg.names, old.names)
+		all = old.all
+	}
+	lvl := TraceLevel + 1 + Level(len(reg.levels))
+	if lvl >= maxStatsLevel {
+		return 0, errors.New("too many levels")
+	}
+	level.Name = name
+	reg.levels[lvl] = level
+	reg.names[name] = lvl
+	i, _ := slices.BinarySearchFunc(all, level.Severity+1, func(l Level, severity int) int {
+		return l.Severity() - severity
+	})
+	reg.all = slices.Insert(slices.Clone(all), i, lvl)
+	customLevels.Store(reg)
+	levelPrefixes.Store(nil)
+	return lvl, nil
+}
+
+// Levels returns all levels, from the most to the least severe, including
+// the levels registered with [RegisterLevel]. It is safe to call concurrently
+// with RegisterLevel. The returned slice must not be modified.
+func Levels() []Level {
+	if reg := customLevels.Load(); reg != nil {
+		return reg.all
+	}
+	return AllLevels
+}
+
+// LookupLevel returns the description of a level registered with
+// [RegisterLevel], and false for built-in and unknown levels.
+func LookupLevel(level Level) (CustomLevel, bool) {
+	if level <= TraceLevel {
+		return CustomLevel{}, false
+	}
+	reg := customLevels.Load()
+	if reg == nil {
+		return CustomLevel{}, false
+	}
+	l, ok := reg.levels[level]
+	return l, ok
+}
+
+func lookupLevelName(name string) (Level, bool) {
+	reg := customLevels.Load()
+	if reg == nil {
+		return 0, false
+	}
+	l, ok := reg.names[strings.ToLower(name)]
+	return l, ok
+}
+
+// Severity returns the position of level on the severity scale, where lower
+// values are more severe. The built-in levels are at multiples of ten, from 0
+// for [PanicLevel] to 60 for [TraceLevel], leaving room for levels registered
+// with [RegisterLevel] in between.
+func (level Level) Severity() int {
+	if l, ok := LookupLevel(level); ok {
+		return l.Severity
+	}
+	return int(level) * 10
+}
+
+// AtLeast reports whether level is at least as severe as threshold, for
+// example whether entries at level are logged by a logger at threshold.
+func (level Level) AtLeast(threshold Level) bool {
+	if level <= TraceLevel && threshold <= TraceLevel {
+		return level <= threshold
+	}
+	return level.Severity() <= threshold.Severity()
+}
+
+// Builtin returns the least severe built-in level that is at least as severe
+// as level. It returns level itself for built-in levels.
+func (level Level) Builtin() Level {
+	if level <= TraceLevel {
+		return level
+	}
+	return Level(min(level.Severity()/10, int(TraceLevel)))
+}
@@ -0,0 +1,123 @@
+package logrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerLevel registers a level for the duration of the test.
+func registerLevel(t *testing.T, level CustomLevel) Level {
+	t.Helper()
+	reg := customLevels.Load()
+	t.Cleanup(func() {
+		customLevels.Store(reg)
+		levelPrefixes.Store(nil)
+	})
+	l, err := RegisterLevel(level)
+	require.NoError(t, err)
+	return l
+}
+
+func TestRegisterLevel(t *testing.T) {
+	notice := registerLevel(t, CustomLevel{Name: "NOTICE", Severity: 35, Color: "\x1b[35m"})
+	critical := registerLevel(t, CustomLevel{Name: "critical", Severity: 5})
+
+	assert.Equal(t, "notice", notice.String())
+	assert.Equal(t, []Level{PanicLevel, critical, FatalLevel, ErrorLevel, WarnLevel, notice, InfoLevel, DebugLevel, TraceLevel}, Levels())
+	assert.Len(t, AllLevels, 7, "AllLevels holds the built-in levels")
+
+	l, err := ParseLevel("Notice")
+	require.NoError(t, err)
+	assert.Equal(t, notice, l)
+	b, err := critical.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "critical", string(b))
+
+	assert.True(t, notice.AtLeast(InfoLevel))
+	assert.True(t, WarnLevel.AtLeast(notice))
+	assert.False(t, InfoLevel.AtLeast(notice))
+	assert.Equal(t, WarnLevel, notice.Builtin())
+	assert.Equal(t, PanicLevel, critical.Builtin())
+
+	_, err = RegisterLevel(CustomLevel{Name: "notice", Severity: 36})
+	require.Error(t, err, "duplicate name")
+	_, err = RegisterLevel(CustomLevel{Name: "warn", Severity: 36})
+	require.Error(t, err, "built-in alias")
+	_, err = RegisterLevel(CustomLevel{Name: "audit", Severity: -1})
+	require.Error(t, err)
+}
+
+func TestLogCustomLevel(t *testing.T) {
+	notice := registerLevel(t, CustomLevel{Name: "notice", Severity: 35, Color: "\x1b[35m"})
+
+	var buf bytes.Buffer
+	logger := New()
+	logger.Out = &buf
+	logger.Formatter = &JSONFormatter{DisableTimestamp: true}
+	logger.SetLevel(notice)
+
+	logger.Info("not logged")
+	assert.False(t, logger.IsLevelEnabled(InfoLevel))
+	logger.WithField("user", "alice").Log(notice, "password changed")
+	logger.Warn("logged")
+
+	var lines []map[string]any
+	for dec := json.NewDecoder(&buf); dec.More(); {
+		var m map[string]any
+		require.NoError(t, dec.Decode(&m))
+		lines = append(lines, m)
+	}
+	require.Len(t, lines, 2)
+	assert.Equal(t, "notice", lines[0]["level"])
+	assert.Equal(t, "password changed", lines[0]["msg"])
+	assert.Equal(t, "warning", lines[1]["level"])
+
+	buf.Reset()
+	logger.Formatter = &TextFormatter{ForceColors: true, DisableTimestamp: true}
+	logger.Log(notice, "colored")
+	assert.True(t, strings.HasPrefix(buf.String(), "\x1b[35mNOTI\x1b[0m colored"), buf.String())
+}
+
+func TestLevelHintCustomLevel(t *testing.T) {
+	notice := registerLevel(t, CustomLevel{Name: "notice", Severity: 35})
+	critical := registerLevel(t, CustomLevel{Name: "critical", Severity: 5})
+
+	l, rest, ok := parseLevelHint("[NOTICE] disk usage at 80%")
+	require.True(t, ok)
+	assert.Equal(t, notice, l)
+	assert.Equal(t, "disk usage at 80%", rest)
+
+	l, ok = jsonLevel("critical")
+	require.True(t, ok)
+	assert.Equal(t, critical, l, "registered levels take precedence")
+}
+
+func TestRegisterLevelConcurrentReaders(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			_ = Levels()
+			_ = newLevelPrefixes()
+		}
+	}()
+	notice := registerLevel(t, CustomLevel{Name: "notice", Severity: 35})
+	<-done
+	assert.Contains(t, Levels(), notice)
+}
+
+func TestLevelPrefixOutdatedCache(t *testing.T) {
+	// Prefixes computed before a level is registered, and stored after
+	// RegisterLevel reset the cache, are not used.
+	outdated := newLevelPrefixes()
+	notice := registerLevel(t, CustomLevel{Name: "notice", Severity: 35})
+	levelPrefixes.Store(outdated)
+
+	assert.Contains(t, levelPrefix(notice, true, false), "NOTICE")
+	assert.NotSame(t, outdated, levelPrefixes.Load())
+}
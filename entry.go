@@ -4,10 +4,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"maps"
-	"os"
 	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -91,6 +92,12 @@ type Entry struct {
 
 	// err contains internal field-formatting errors.
 	err string
+
+	// disabled is set on the entry returned by At for a disabled level,
+	// disabledAt. While the logger's level keeps disabledAt disabled,
+	// adding fields to the entry returns the entry itself.
+	disabled   bool
+	disabledAt Level
 }
 
 // NewEntry creates a new [Entry] associated with the provided Logger.
@@ -156,6 +163,49 @@ func (entry *Entry) String() (string, error) {
 	return str, nil
 }
 
+// At returns the entry if logging at level is enabled, and otherwise a
+// disabled entry. Adding fields to a disabled entry returns the entry
+// itself, so that
+//
+//	entry.At(DebugLevel).WithFields(fields).WithError(err).Debugf("retrying %s", name)
+//
+// neither copies fields nor formats the message when debug logging is
+// disabled. The disabled entry keeps the Data and Context of entry, and
+// logging it at a more severe level, for example with Error, logs the
+// message with them, but without the fields added after At. Whether a
+// level is enabled is checked when logging, so a disabled entry kept
+// around logs as usual once the logger's level enables it.
+//
+// At is the only way to skip copying fields for disabled levels: chains
+// not starting with At copy their fields even if they end up not being
+// logged. The arguments are still evaluated; use the Fn variants, such as
+// [Logger.DebugFn], to defer expensive ones. Disabled entries without Data
+// and Context are shared and must not be modified.
+func (entry *Entry) At(level Level) *Entry {
+	if entry.enabled(level) {
+		return entry
+	}
+	if len(entry.Data) == 0 && entry.Context == nil && entry.Time.IsZero() && entry.err == "" {
+		return entry.Logger.disabledEntry(level)
+	}
+	disabled := entry.dup()
+	disabled.Data = entry.Data
+	disabled.disabled = true
+	disabled.disabledAt = level
+	return disabled
+}
+
+// enabled reports whether logging entry at level is enabled.
+func (entry *Entry) enabled(level Level) bool {
+	return entry.Logger.IsLevelEnabled(level)
+}
+
+// dropsFields reports whether entry is a disabled entry returned by At
+// whose level is still disabled, so that fields added to it are dropped.
+func (entry *Entry) dropsFields() bool {
+	return entry.disabled && !entry.Logger.IsLevelEnabled(entry.disabledAt)
+}
+
 // WithError adds an error as single field (using the key defined in [ErrorKey])
 // to the Entry.
 func (entry *Entry) WithError(err error) *Entry {
@@ -164,6 +214,9 @@ func (entry *Entry) WithError(err error) *Entry {
 
 // WithContext adds a context to the Entry.
 func (entry *Entry) WithContext(ctx context.Context) *Entry {
+	if entry.dropsFields() {
+		return entry
+	}
 	dup := entry.dup()
 	dup.Data = maps.Clone(entry.Data)
 	dup.Context = ctx
@@ -172,6 +225,9 @@ func (entry *Entry) WithContext(ctx context.Context) *Entry {
 
 // WithField adds a single field to the Entry.
 func (entry *Entry) WithField(key string, value any) *Entry {
+	if entry.dropsFields() {
+		return entry
+	}
 	dup := entry.dup()
 	dup.Data = maps.Clone(entry.Data)
 	dup.addField(key, value)
@@ -180,6 +236,9 @@ func (entry *Entry) WithField(key string, value any) *Entry {
 
 // WithFields adds a map of fields to the Entry.
 func (entry *Entry) WithFields(fields Fields) *Entry {
+	if entry.dropsFields() {
+		return entry
+	}
 	dup := entry.dup()
 	dup.Data = make(Fields, len(entry.Data)+len(fields))
 	maps.Copy(dup.Data, entry.Data)
@@ -192,6 +251,9 @@ func (entry *Entry) WithFields(fields Fields) *Entry {
 
 // WithTime overrides the time of the Entry.
 func (entry *Entry) WithTime(t time.Time) *Entry {
+	if entry.dropsFields() {
+		return entry
+	}
 	dup := entry.dup()
 	dup.Data = maps.Clone(entry.Data)
 	dup.Time = t
@@ -320,21 +382,49 @@ func (entry *Entry) log(level Level, panicAfter bool, msg string) {
 	newEntry.Message = msg
 
 	logger := newEntry.Logger
+	// Snapshot the configuration of the logger at once, so that an entry is
+	// never logged with a mix of the settings before and after a concurrent
+	// Config.Apply. The level is read under the lock for the same reason.
 	logger.mu.Lock()
+	// Entries less severe than the logger's level are only logged for
+	// capture hooks.
+	captureOnly := !level.AtLeast(logger.level())
 	reportCaller := logger.ReportCaller
 	bufPool := newEntry.getBufferPool()
+	hookErrors := hookErrorConfig{
+		policy:      logger.HookErrorPolicy,
+		maxFailures: logger.MaxHookFailures,
+		handler:     logger.HookErrorHandler,
+	}
+	// Select hooks based on the level for this log call. Hooks receive the
+	// Entry and may mutate it, but that does not affect which hooks are
+	// fired for this event.
+	hooks := logger.hooksForLevel(level)
+	formatter, out, sinks := logger.Formatter, logger.Out, logger.Sinks
+	if o, ok := out.(*configOutput); ok {
+		o.acquire()
+		defer o.release()
+	}
 	logger.mu.Unlock()
+	if !captureOnly {
+		logger.stats.entry(level)
+	}
 
 	// Preserve explicitly set caller information.
 	if reportCaller && newEntry.Caller == nil {
 		newEntry.Caller = getCaller()
 	}
 
-	// Select hooks based on the level for this log call. Hooks receive the
-	// Entry and may mutate it, but that does not affect which hooks are
-	// fired for this event.
-	hooks := logger.hooksForLevel(level)
-	newEntry.fireHooks(hooks)
+	if captureOnly {
+		hooks = slices.DeleteFunc(hooks, func(hook Hook) bool {
+			c, ok := hook.(CaptureHook)
+			return !ok || !level.AtLeast(c.CaptureLevel())
+		})
+	}
+	newEntry.fireHooks(hooks, hookErrors)
+	if captureOnly {
+		return
+	}
 
 	buffer := bufPool.Get()
 	defer func() {
@@ -344,7 +434,7 @@ func (entry *Entry) log(level Level, panicAfter bool, msg string) {
 	}()
 	buffer.Reset()
 	newEntry.Buffer = buffer
-	newEntry.write()
+	newEntry.write(formatter, out, sinks)
 	newEntry.Buffer = nil
 
 	// Panic here so the panic value contains the fully populated entry without
@@ -361,35 +451,69 @@ func (entry *Entry) getBufferPool() (pool BufferPool) {
 	return bufferPool
 }
 
-func (entry *Entry) fireHooks(hooks []Hook) {
+// hookErrorConfig is the hook error handling configuration of a Logger,
+// taken for a single log call.
+type hookErrorConfig struct {
+	policy      HookErrorPolicy
+	maxFailures int
+	handler     HookErrorHandler
+}
+
+func (entry *Entry) fireHooks(hooks []Hook, cfg hookErrorConfig) {
+	failures := &entry.Logger.hookFailures
+	disableAfter := 0
+	if cfg.policy == HookErrorDisable {
+		disableAfter = max(cfg.maxFailures, 1)
+	}
+	handler := cfg.handler
+	if handler == nil {
+		handler = defaultHookErrorHandler
+	}
+
 	for _, hook := range hooks {
-		if err := hook.Fire(entry); err != nil {
-			_, _ = fmt.Fprintln(os.Stderr, "Failed to fire hook:", err)
+		if disableAfter > 0 && failures.disabled(hook) {
+			continue
+		}
+		err := fireHook(hook, entry)
+		failures.record(hook, err, disableAfter)
+		if err == nil {
+			continue
+		}
+		entry.Logger.stats.hookFailures.Add(1)
+		handler(hook, entry, err)
+		if cfg.policy == HookErrorStop {
 			return
 		}
 	}
 }
 
-func (entry *Entry) write() {
-	// Snapshot the formatter under the lock to protect against concurrent
-	// SetFormatter calls, then release the lock before formatting.
-	// This avoids a deadlock when Format() triggers reentrant logging (e.g.,
-	// a field's MarshalJSON calls logrus). See #1448, #1440.
-	entry.Logger.mu.Lock()
-	formatter := entry.Logger.Formatter
-	entry.Logger.mu.Unlock()
+// write formats entry and writes it to out and sinks, a snapshot of the
+// logger's configuration taken by the caller.
+//
+// The formatter is snapshotted under the lock to protect against concurrent
+// SetFormatter calls, and the lock is released before formatting. This
+// avoids a deadlock when Format() triggers reentrant logging (e.g., a
+// field's MarshalJSON calls logrus). See #1448, #1440.
+func (entry *Entry) write(formatter Formatter, out io.Writer, sinks []*Sink) {
+	if len(sinks) > 0 || out == nil {
+		entry.writeOutputs(formatter, out, sinks)
+		return
+	}
 
 	serialized, err := formatter.Format(entry)
 	if err != nil {
-		_, _ = fmt.Fprintln(os.Stderr, "Failed to format entry:", err)
+		entry.Logger.stats.formatErrors.Add(1)
+		_, _ = fmt.Fprintln(Stderr(), "Failed to format entry:", err)
 		return
 	}
 
 	// Re-acquire the lock to serialize writes to the underlying io.Writer.
 	entry.Logger.mu.Lock()
 	defer entry.Logger.mu.Unlock()
-	if _, err := entry.Logger.Out.Write(serialized); err != nil {
-		_, _ = fmt.Fprintln(os.Stderr, "Failed to write to log:", err)
+	n, err := writeLevel(out, entry.Level, serialized)
+	entry.Logger.stats.written(n, err)
+	if err != nil {
+		_, _ = fmt.Fprintln(Stderr(), "Failed to write to log:", err)
 	}
 }
 
@@ -400,7 +524,7 @@ func (entry *Entry) write() {
 // use [Entry.Panic] or [Entry.Fatal] when those side effects are desired.
 func (entry *Entry) Log(level Level, args ...any) {
 	const panicAfter = false
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.enabled(level) {
 		entry.logArgs(level, panicAfter, args...)
 	}
 }
@@ -440,7 +564,7 @@ func (entry *Entry) Fatal(args ...any) {
 
 func (entry *Entry) Panic(args ...any) {
 	const panicAfter = true
-	if entry.Logger.IsLevelEnabled(PanicLevel) {
+	if entry.enabled(PanicLevel) {
 		entry.logArgs(PanicLevel, panicAfter, args...)
 	}
 }
@@ -454,7 +578,7 @@ func (entry *Entry) Panic(args ...any) {
 // use [Entry.Panicf] or [Entry.Fatalf] when those side effects are desired.
 func (entry *Entry) Logf(level Level, format string, args ...any) {
 	const panicAfter = false
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.enabled(level) {
 		entry.logf(level, panicAfter, format, args...)
 	}
 }
@@ -494,7 +618,7 @@ func (entry *Entry) Fatalf(format string, args ...any) {
 
 func (entry *Entry) Panicf(format string, args ...any) {
 	const panicAfter = true
-	if entry.Logger.IsLevelEnabled(PanicLevel) {
+	if entry.enabled(PanicLevel) {
 		entry.logf(PanicLevel, panicAfter, format, args...)
 	}
 }
@@ -508,7 +632,7 @@ func (entry *Entry) Panicf(format string, args ...any) {
 // use [Entry.Panicln] or [Entry.Fatalln] when those side effects are desired.
 func (entry *Entry) Logln(level Level, args ...any) {
 	const panicAfter = false
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.enabled(level) {
 		entry.logln(level, panicAfter, args...)
 	}
 }
@@ -548,7 +672,7 @@ func (entry *Entry) Fatalln(args ...any) {
 
 func (entry *Entry) Panicln(args ...any) {
 	const panicAfter = true
-	if entry.Logger.IsLevelEnabled(PanicLevel) {
+	if entry.enabled(PanicLevel) {
 		entry.logln(PanicLevel, panicAfter, args...)
 	}
 }
@@ -113,6 +113,31 @@ func BenchmarkEntry_WithField_Chain_Disabled(b *testing.B) {
 	}
 }
 
+// BenchmarkEntry_At_Disabled measures the cost of the chain of
+// BenchmarkEntry_WithField_Chain_Disabled when it is gated by At, which
+// makes it free of allocations.
+func BenchmarkEntry_At_Disabled(b *testing.B) {
+	logger := logrus.New()
+	logger.SetFormatter(nopFormatter{})
+	logger.SetLevel(logrus.InfoLevel)
+
+	base := logrus.NewEntry(logger).WithField("a", 1)
+	errBoom := errors.New("boom")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		base.At(logrus.DebugLevel).
+			WithField("k0", 0).
+			WithField("k1", 1).
+			WithField("k2", 2).
+			WithField("k3", 3).
+			WithError(errBoom).
+			Debugf("message %d", 1)
+	}
+}
+
 // BenchmarkEntry_WithField_Chain_Enabled measures the cost of constructing and
 // logging a chain of fields when the log level is enabled.
 func BenchmarkEntry_WithField_Chain_Enabled(b *testing.B) {
@@ -332,22 +332,27 @@ func (p *panickyHook) Fire(entry *logrus.Entry) error {
 }
 
 func TestEntryHooksPanic(t *testing.T) {
+	var buf bytes.Buffer
 	logger := logrus.New()
-	logger.SetOutput(io.Discard)
+	logger.SetOutput(&buf)
 	logger.SetLevel(logrus.InfoLevel)
 	logger.AddHook(&panickyHook{})
 
-	defer func() {
-		p := recover()
-		assert.NotNil(t, p)
-		assert.Equal(t, panicMessage, p)
-
-		entry := logrus.NewEntry(logger)
-		entry.Info("another message")
-	}()
+	var reported error
+	logger.SetHookErrorHandler(func(_ logrus.Hook, _ *logrus.Entry, err error) {
+		reported = err
+	})
 
 	entry := logrus.NewEntry(logger)
 	entry.Info(badMessage)
+
+	var panicErr *logrus.HookPanicError
+	require.ErrorAs(t, reported, &panicErr)
+	assert.Equal(t, panicMessage, panicErr.Value)
+	assert.Contains(t, buf.String(), badMessage, "the entry must still be written")
+
+	entry.Info("another message")
+	assert.Contains(t, buf.String(), "another message")
 }
 
 // TestEntryDerivationPreservesCaller verifies that derived entries retain
@@ -655,3 +660,70 @@ func TestEntryDataIsMutable(t *testing.T) {
 		"three": 3,
 	}, hook.Entries[1].Data)
 }
+
+func TestEntryAt(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.InfoLevel)
+	var exitCode int
+	logger.ExitFunc = func(code int) { exitCode = code }
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+	base := logger.WithField("a", 1).WithContext(ctx)
+
+	assert.Same(t, base, base.At(logrus.InfoLevel))
+	shared := logger.At(logrus.DebugLevel)
+	assert.Same(t, shared, logger.At(logrus.DebugLevel), "disabled entries without data are shared")
+	assert.NotSame(t, shared, logger.At(logrus.TraceLevel))
+	disabled := base.At(logrus.DebugLevel)
+	assert.Same(t, disabled, disabled.WithField("b", 2).WithFields(logrus.Fields{"c": 3}).
+		WithError(errors.New("boom")).WithContext(context.Background()).WithTime(time.Now()))
+	disabled.Debugf("%d", 1)
+	disabled.Traceln("trace")
+	assert.Empty(t, hook.AllEntries())
+
+	// More severe levels are logged with the data of the receiver of At,
+	// but without the dropped fields.
+	disabled.WithField("b", 2).Error("error")
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "error", hook.LastEntry().Message)
+	assert.Equal(t, logrus.Fields{"a": 1}, hook.LastEntry().Data)
+	assert.Equal(t, ctx, hook.LastEntry().Context)
+	disabled.Fatal("fatal")
+	assert.Equal(t, "fatal", hook.LastEntry().Message, "Fatal logs before exiting")
+	assert.Equal(t, 1, exitCode)
+
+	// Once debug is enabled, disabled entries log debug entries and keep
+	// the fields added to them.
+	logger.SetLevel(logrus.DebugLevel)
+	hook.Reset()
+	shared.Debug("shared")
+	assert.Equal(t, "shared", hook.LastEntry().Message)
+	disabled.WithField("b", 2).Debug("debug")
+	assert.Equal(t, "debug", hook.LastEntry().Message)
+	assert.Equal(t, logrus.Fields{"a": 1, "b": 2}, hook.LastEntry().Data)
+
+	logger.At(logrus.InfoLevel).WithField("b", 2).Info("enabled")
+	assert.Equal(t, logrus.Fields{"b": 2}, hook.LastEntry().Data)
+	base.At(logrus.DebugLevel).Debug("debug")
+	assert.Equal(t, logrus.Fields{"a": 1}, hook.LastEntry().Data)
+}
+
+func TestEntryAtDisabledDoesNotAllocate(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	errBoom := errors.New("boom")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.At(logrus.DebugLevel).
+			WithFields(logrus.Fields{"k0": 0, "k1": "one"}).
+			WithField("k2", 2).
+			WithError(errBoom).
+			Debugf("retrying %s after %v", "request", errBoom)
+	})
+	assert.Zero(t, allocs)
+
+	allocs = testing.AllocsPerRun(100, func() {
+		logger.Debugf("retrying %s after %v", "request", errBoom)
+	})
+	assert.Zero(t, allocs)
+}
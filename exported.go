@@ -52,6 +52,23 @@ func AddHook(hook Hook) {
 	std.AddHook(hook)
 }
 
+// AddSink adds a sink to the standard logger.
+func AddSink(sink *Sink) {
+	std.AddSink(sink)
+}
+
+// RemoveHook removes a hook from the standard logger hooks.
+func RemoveHook(hook Hook) bool {
+	return std.RemoveHook(hook)
+}
+
+// At returns an entry of the standard logger if logging at level is enabled,
+// and otherwise a disabled entry on which adding fields and logging at
+// level do nothing. See [Entry.At].
+func At(level Level) *Entry {
+	return std.At(level)
+}
+
 // WithError creates an entry from the standard logger and adds an error to it,
 // using the value defined in [ErrorKey] as key.
 func WithError(err error) *Entry {
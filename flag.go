@@ -0,0 +1,102 @@
+package logrus
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// LevelFlag defines a flag in fs with the given name and default level and
+// returns the address of the level it sets. The usage message lists the
+// valid levels:
+//
+//	level := logrus.LevelFlag(flag.CommandLine, "log-level", logrus.InfoLevel)
+//	flag.Parse()
+//	logrus.SetLevel(*level)
+func LevelFlag(fs *flag.FlagSet, name string, value Level) *Level {
+	p := new(Level)
+	*p = value
+	fs.Var(p, name, levelUsage())
+	return p
+}
+
+// FormatFlag defines a flag in fs with the given name and default format
+// and returns the address of the formatter it sets. The formats are the
+// names registered with [RegisterFormatterFactory], such as "text",
+// "logfmt" and "json", and the usage message lists them. The formatters are
+// created without options. FormatFlag panics if value is not a registered
+// format.
+//
+//	formatter := logrus.FormatFlag(flag.CommandLine, "log-format", "text")
+//	flag.Parse()
+//	logrus.SetFormatter(*formatter)
+func FormatFlag(fs *flag.FlagSet, name string, value string) *Formatter {
+	v := &formatValue{formatter: new(Formatter)}
+	if err := v.Set(value); err != nil {
+		panic("logrus: FormatFlag: " + err.Error())
+	}
+	fs.Var(v, name, formatUsage())
+	return v.formatter
+}
+
+// formatValue is the flag.Value of a [FormatFlag].
+type formatValue struct {
+	name      string
+	formatter *Formatter
+}
+
+func (v *formatValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.name
+}
+
+func (v *formatValue) Set(s string) error {
+	factory, err := lookupFormatterFactory(s)
+	if err != nil {
+		return err
+	}
+	formatter, err := factory(nil)
+	if err != nil {
+		return fmt.Errorf("format %q: %w", s, err)
+	}
+	v.name, *v.formatter = s, formatter
+	return nil
+}
+
+func lookupFormatterFactory(name string) (FormatterFactory, error) {
+	factoriesMu.RLock()
+	factory := formatterFactories[name]
+	factoriesMu.RUnlock()
+	if factory == nil {
+		return nil, fmt.Errorf("unknown format %q, want one of %s", name, strings.Join(formatNames(), ", "))
+	}
+	return factory, nil
+}
+
+// formatNames returns the sorted names of the registered formatters.
+func formatNames() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(formatterFactories))
+	for name := range formatterFactories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func levelUsage() string {
+	levels := Levels()
+	names := make([]string, len(levels))
+	for i, level := range levels {
+		names[i] = level.String()
+	}
+	return "log `level`: " + strings.Join(names, ", ")
+}
+
+func formatUsage() string {
+	return "log `format`: " + strings.Join(formatNames(), ", ")
+}
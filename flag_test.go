@@ -0,0 +1,67 @@
+package logrus_test
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/sirupsen/logrus"
+)
+
+func TestLevelFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	level := LevelFlag(fs, "log-level", InfoLevel)
+	assert.Equal(t, InfoLevel, *level)
+
+	require.NoError(t, fs.Parse([]string{"-log-level", "DEBUG"}))
+	assert.Equal(t, DebugLevel, *level)
+	assert.Equal(t, "debug", fs.Lookup("log-level").Value.String())
+
+	assert.Error(t, fs.Parse([]string{"-log-level=loud"}))
+	assert.Equal(t, DebugLevel, *level)
+}
+
+func TestLevelFlagVar(t *testing.T) {
+	level := WarnLevel
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&level, "v", "level")
+	require.NoError(t, fs.Parse([]string{"-v=trace"}))
+	assert.Equal(t, TraceLevel, level)
+}
+
+func TestFormatFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	formatter := FormatFlag(fs, "log-format", "text")
+	assert.IsType(t, &TextFormatter{}, *formatter)
+
+	require.NoError(t, fs.Parse([]string{"-log-format", "json"}))
+	assert.IsType(t, &JSONFormatter{}, *formatter)
+	require.NoError(t, fs.Parse([]string{"-log-format", "logfmt"}))
+	assert.Equal(t, &TextFormatter{DisableColors: true, FullTimestamp: true}, *formatter)
+	assert.Equal(t, "logfmt", fs.Lookup("log-format").Value.String())
+
+	err := fs.Parse([]string{"-log-format", "xml"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "json, logfmt, text")
+
+	assert.Panics(t, func() { FormatFlag(fs, "format", "xml") })
+}
+
+func TestFlagUsage(t *testing.T) {
+	var buf bytes.Buffer
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&buf)
+	LevelFlag(fs, "log-level", InfoLevel)
+	FormatFlag(fs, "log-format", "text")
+	fs.PrintDefaults()
+	assert.Contains(t, buf.String(), "-log-level level")
+	assert.Contains(t, buf.String(), "panic, fatal, error, warning, info, debug, trace (default info)")
+	assert.Contains(t, buf.String(), "-log-format format")
+	assert.Contains(t, buf.String(), "json, logfmt, text (default text)")
+}
@@ -4,7 +4,6 @@ go 1.23
 
 require (
 	github.com/stretchr/testify v1.12.1
+	go.yaml.in/yaml/v3 v3.0.5
 	golang.org/x/sys v0.13.0
 )
-
-require go.yaml.in/yaml/v3 v3.0.5 // indirect
@@ -1,6 +1,8 @@
 package logrus_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"maps"
@@ -259,3 +261,300 @@ func TestHookFireOrder(t *testing.T) {
 	}
 	require.Equal(t, []string{"first hook", "second hook", "third hook"}, checkers)
 }
+
+type PriorityHook struct {
+	HookCallFunc
+	P int
+}
+
+func (h *PriorityHook) Priority() int {
+	return h.P
+}
+
+func TestHookPriorityOrder(t *testing.T) {
+	var order []string
+	record := func(name string) func() {
+		return func() { order = append(order, name) }
+	}
+
+	h := LevelHooks{}
+	h.Add(&HookCallFunc{F: record("default 1")})
+	h.Add(&PriorityHook{HookCallFunc{F: record("low")}, -10})
+	h.Add(&PriorityHook{HookCallFunc{F: record("high 1")}, 10})
+	h.Add(&HookCallFunc{F: record("default 2")})
+	h.Add(&PriorityHook{HookCallFunc{F: record("high 2")}, 10})
+
+	require.NoError(t, h.Fire(InfoLevel, &Entry{}))
+	assert.Equal(t, []string{"high 1", "high 2", "default 1", "default 2", "low"}, order)
+}
+
+func TestLevelHooksRemoveAndReplace(t *testing.T) {
+	first, second, third := &TestHook{}, &TestHook{}, &TestHook{}
+	h := LevelHooks{}
+	h.Add(first)
+	h.Add(second)
+	h.Add(first)
+
+	assert.True(t, h.Remove(first))
+	assert.False(t, h.Remove(first))
+	assert.Equal(t, []Hook{second}, h[InfoLevel])
+
+	assert.False(t, h.Replace(first, third))
+	assert.True(t, h.Replace(second, third))
+	assert.Equal(t, []Hook{third}, h[InfoLevel])
+
+	assert.True(t, h.Remove(third))
+	assert.Empty(t, h)
+}
+
+// SliceHook is a hook whose dynamic type is not comparable.
+type SliceHook []string
+
+func (SliceHook) Levels() []Level { return AllLevels }
+func (SliceHook) Fire(*Entry) error {
+	return nil
+}
+
+func TestLevelHooksRemoveIncomparable(t *testing.T) {
+	h := LevelHooks{}
+	h.Add(SliceHook{"a"})
+	assert.NotPanics(t, func() {
+		assert.False(t, h.Remove(SliceHook{"a"}))
+	})
+	assert.Len(t, h[InfoLevel], 1)
+}
+
+func TestLoggerRemoveHook(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	failing := &FailingHook{Err: errors.New("boom")}
+	logger.SetHookErrorHandler(func(Hook, *Entry, error) {})
+	handle := logger.RegisterHook(failing)
+	assert.Same(t, failing, handle.Hook())
+
+	logger.Info("first")
+	assert.Equal(t, int64(1), failing.Calls.Load())
+	require.Len(t, logger.HookFailures(), 1)
+
+	assert.True(t, handle.Remove())
+	assert.False(t, handle.Remove())
+	assert.Empty(t, logger.HookFailures())
+
+	logger.Info("second")
+	assert.Equal(t, int64(1), failing.Calls.Load())
+	assert.Len(t, hook.AllEntries(), 2)
+
+	replacement := &FailingHook{}
+	assert.True(t, logger.ReplaceHook(hook, replacement))
+	logger.Info("third")
+	assert.Len(t, hook.AllEntries(), 2)
+	assert.Equal(t, int64(1), replacement.Calls.Load())
+}
+
+type FailingHook struct {
+	Calls atomic.Int64
+	Err   error
+}
+
+func (h *FailingHook) Levels() []Level {
+	return AllLevels
+}
+
+func (h *FailingHook) Fire(*Entry) error {
+	h.Calls.Add(1)
+	return h.Err
+}
+
+func TestLevelHooksFireContinuesAfterError(t *testing.T) {
+	errFirst, errThird := errors.New("first"), errors.New("third")
+	first := &FailingHook{Err: errFirst}
+	second := &FailingHook{}
+	third := &FailingHook{Err: errThird}
+
+	h := LevelHooks{}
+	h.Add(first)
+	h.Add(second)
+	h.Add(third)
+
+	err := h.Fire(InfoLevel, &Entry{})
+	require.ErrorIs(t, err, errFirst)
+	require.ErrorIs(t, err, errThird)
+	assert.Equal(t, int64(1), second.Calls.Load())
+}
+
+func TestHookErrorPolicy(t *testing.T) {
+	tests := []struct {
+		policy     HookErrorPolicy
+		wantSecond int64
+	}{
+		{HookErrorStop, 0},
+		{HookErrorContinue, 3},
+		{HookErrorDisable, 3},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.policy), func(t *testing.T) {
+			failing := &FailingHook{Err: errors.New("boom")}
+			second := &FailingHook{}
+
+			var reported []error
+			l := New()
+			l.SetOutput(io.Discard)
+			l.SetHookErrorPolicy(tc.policy, 2)
+			l.SetHookErrorHandler(func(hook Hook, _ *Entry, err error) {
+				assert.Same(t, failing, hook)
+				reported = append(reported, err)
+			})
+			l.AddHook(failing)
+			l.AddHook(second)
+
+			for range 3 {
+				l.Info("test")
+			}
+
+			assert.Equal(t, tc.wantSecond, second.Calls.Load())
+			if tc.policy == HookErrorDisable {
+				assert.Equal(t, int64(2), failing.Calls.Load(), "hook must be disabled after two failures")
+				assert.Len(t, reported, 2)
+			} else {
+				assert.Equal(t, int64(3), failing.Calls.Load())
+				assert.Len(t, reported, 3)
+			}
+		})
+	}
+}
+
+func TestHookFailures(t *testing.T) {
+	errBoom := errors.New("boom")
+	failing := &FailingHook{Err: errBoom}
+
+	l := New()
+	l.SetOutput(io.Discard)
+	l.SetHookErrorPolicy(HookErrorDisable, 3)
+	l.SetHookErrorHandler(func(Hook, *Entry, error) {})
+	l.AddHook(failing)
+	assert.Empty(t, l.HookFailures())
+
+	l.Info("one")
+	l.Info("two")
+	failing.Err = nil
+	l.Info("three")
+
+	failures := l.HookFailures()
+	require.Len(t, failures, 1)
+	assert.Same(t, failing, failures[0].Hook)
+	assert.Equal(t, uint64(2), failures[0].Failures)
+	assert.Equal(t, 0, failures[0].Consecutive)
+	assert.False(t, failures[0].Disabled)
+	assert.Equal(t, errBoom, failures[0].LastError)
+
+	failing.Err = errBoom
+	for range 3 {
+		l.Info("fail")
+	}
+	failures = l.HookFailures()
+	require.Len(t, failures, 1)
+	assert.True(t, failures[0].Disabled)
+	assert.Equal(t, uint64(5), failures[0].Failures)
+
+	l.ResetHookFailures(failing)
+	assert.Empty(t, l.HookFailures())
+	calls := failing.Calls.Load()
+	l.Info("enabled again")
+	assert.Equal(t, calls+1, failing.Calls.Load())
+}
+
+type PanickingHook struct{}
+
+func (PanickingHook) Levels() []Level { return AllLevels }
+func (PanickingHook) Fire(*Entry) error {
+	panic("hook panic")
+}
+
+func TestHookPanicIsRecovered(t *testing.T) {
+	var reported error
+	second := &FailingHook{}
+	l := New()
+	l.SetOutput(io.Discard)
+	l.SetHookErrorPolicy(HookErrorContinue, 0)
+	l.SetHookErrorHandler(func(_ Hook, _ *Entry, err error) { reported = err })
+	l.AddHook(PanickingHook{})
+	l.AddHook(second)
+
+	require.NotPanics(t, func() { l.Info("test") })
+
+	var panicErr *HookPanicError
+	require.ErrorAs(t, reported, &panicErr)
+	assert.Equal(t, "hook panic", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+	assert.Equal(t, int64(1), second.Calls.Load())
+	require.Len(t, l.HookFailures(), 1)
+}
+
+type CapturingHook struct {
+	test.Hook
+	Level Level
+}
+
+func (h *CapturingHook) CaptureLevel() Level {
+	return h.Level
+}
+
+func TestCaptureHook(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	capture := &CapturingHook{Level: DebugLevel}
+
+	assert.False(t, logger.IsLevelEnabled(DebugLevel))
+	logger.AddHook(capture)
+	assert.True(t, logger.IsLevelEnabled(DebugLevel))
+	assert.False(t, logger.IsLevelEnabled(TraceLevel))
+
+	logger.Debug("captured")
+	logger.Trace("dropped")
+	logger.Info("logged")
+	require.Len(t, capture.AllEntries(), 2)
+	assert.Equal(t, "captured", capture.AllEntries()[0].Message)
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "logged", hook.LastEntry().Message)
+
+	logger.ReplaceHooks(make(LevelHooks))
+	assert.False(t, logger.IsLevelEnabled(DebugLevel))
+}
+
+func TestWriteEntry(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&TextFormatter{DisableColors: true, DisableTimestamp: true})
+	hook := test.NewLocal(logger)
+
+	entry := NewEntry(New()).WithField("key", "value")
+	entry.Level = TraceLevel
+	entry.Message = "replayed"
+	logger.WriteEntry(entry)
+
+	assert.Equal(t, "level=trace msg=replayed key=value\n", buf.String())
+	assert.Empty(t, hook.AllEntries())
+}
+
+type ClosingHook struct {
+	Closed int
+	Err    error
+}
+
+func (h *ClosingHook) Levels() []Level   { return []Level{ErrorLevel, InfoLevel} }
+func (h *ClosingHook) Fire(*Entry) error { return nil }
+func (h *ClosingHook) Close() error {
+	h.Closed++
+	return h.Err
+}
+
+func TestLoggerClose(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	ok := &ClosingHook{}
+	failing := &ClosingHook{Err: errors.New("boom")}
+	logger.AddHook(ok)
+	logger.AddHook(failing)
+
+	assert.EqualError(t, logger.Close(), "boom")
+	assert.Equal(t, 1, ok.Closed, "hooks registered for several levels are closed once")
+	assert.Equal(t, 1, failing.Closed)
+}
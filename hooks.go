@@ -1,5 +1,15 @@
 package logrus
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
 // Hook describes hooks to be fired when logging on the logging levels returned from
 // [Hook.Levels] on your implementation of the interface. Note that this is not
 // fired in a goroutine or a channel with workers, you should handle such
@@ -13,22 +23,286 @@ type Hook interface {
 // LevelHooks is an internal type for storing the hooks on a logger instance.
 type LevelHooks map[Level][]Hook
 
+// PrioritizedHook is a hook that controls the order in which it is fired.
+// Hooks with a higher priority fire before hooks with a lower priority, so
+// that, for example, a hook redacting fields of an entry can run before a
+// hook shipping it. Hooks that do not implement PrioritizedHook have
+// priority 0, and hooks with the same priority fire in the order they were
+// added.
+type PrioritizedHook interface {
+	Hook
+	Priority() int
+}
+
+// CaptureHook is a hook that receives entries even if they are less severe
+// than the logger's level, for example to keep recent debug entries in
+// memory while only info entries are written. Entries that are only
+// enabled by a capture hook are passed to the capture hooks of their level
+// alone; they are neither written nor passed to other hooks.
+//
+// The capture level of a hook is taken into account when the hook is
+// added with [Logger.AddHook] or a related Logger method, not when it is
+// added to Logger.Hooks directly.
+type CaptureHook interface {
+	Hook
+	// CaptureLevel returns the least severe level the hook receives
+	// regardless of the logger's level.
+	CaptureLevel() Level
+}
+
+// captureLevel returns the least severe capture level of the hooks, or
+// PanicLevel if there are no capture hooks.
+func (hooks LevelHooks) captureLevel() Level {
+	level := PanicLevel
+	for _, levelHooks := range hooks {
+		for _, hook := range levelHooks {
+			if c, ok := hook.(CaptureHook); ok {
+				if !c.CaptureLevel().AtLeast(level) {
+					level = c.CaptureLevel()
+				}
+			}
+		}
+	}
+	return level
+}
+
+// hookPriority returns the priority of hook.
+func hookPriority(hook Hook) int {
+	if p, ok := hook.(PrioritizedHook); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
 // Add a hook to an instance of logger. This is called with
 // `log.Hooks.Add(new(MyHook))` where `MyHook` implements the `Hook` interface.
+//
+// The hook is inserted after all hooks of the same or a higher priority,
+// see [PrioritizedHook].
 func (hooks LevelHooks) Add(hook Hook) {
+	priority := hookPriority(hook)
 	for _, level := range hook.Levels() {
-		hooks[level] = append(hooks[level], hook)
+		i := len(hooks[level])
+		for i > 0 && hookPriority(hooks[level][i-1]) < priority {
+			i--
+		}
+		hooks[level] = slices.Insert(hooks[level], i, hook)
 	}
 }
 
+// Remove removes every registration of hook and reports whether hook was
+// registered. Hooks are compared by identity, so hooks whose dynamic type
+// is not comparable, such as a struct containing a slice, cannot be
+// removed; use pointers for those.
+func (hooks LevelHooks) Remove(hook Hook) bool {
+	if !trackable(hook) {
+		return false
+	}
+	found := false
+	for level, levelHooks := range hooks {
+		kept := slices.DeleteFunc(slices.Clone(levelHooks), func(h Hook) bool {
+			return sameHook(h, hook)
+		})
+		if len(kept) == len(levelHooks) {
+			continue
+		}
+		found = true
+		if len(kept) == 0 {
+			delete(hooks, level)
+		} else {
+			hooks[level] = kept
+		}
+	}
+	return found
+}
+
+// Replace replaces every registration of oldHook with newHook and reports
+// whether oldHook was registered. If it was not, newHook is not added.
+// Since newHook may fire for different levels or have a different priority
+// than oldHook, it is added as if by [LevelHooks.Add].
+func (hooks LevelHooks) Replace(oldHook, newHook Hook) bool {
+	if !hooks.Remove(oldHook) {
+		return false
+	}
+	hooks.Add(newHook)
+	return true
+}
+
+// sameHook reports whether a and b are the same hook. Hooks of
+// incomparable types are never the same.
+func sameHook(a, b Hook) bool {
+	return trackable(a) && trackable(b) && a == b
+}
+
+// HookHandle refers to a hook registered with [Logger.RegisterHook].
+type HookHandle struct {
+	logger *Logger
+	hook   Hook
+}
+
+// Hook returns the registered hook.
+func (h *HookHandle) Hook() Hook {
+	return h.hook
+}
+
+// Remove removes the hook from the logger, like [Logger.RemoveHook], and
+// reports whether it was still registered.
+func (h *HookHandle) Remove() bool {
+	return h.logger.RemoveHook(h.hook)
+}
+
 // Fire all the hooks for the passed level. Used by `entry.log` to fire
 // appropriate hooks for a log entry.
+//
+// A failing or panicking hook does not prevent the remaining hooks from
+// firing. The errors of all failed hooks are joined into the returned error.
 func (hooks LevelHooks) Fire(level Level, entry *Entry) error {
+	var errs []error
 	for _, hook := range hooks[level] {
-		if err := hook.Fire(entry); err != nil {
-			return err
+		if err := fireHook(hook, entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// fireHook fires hook, converting a panic into an error.
+func fireHook(hook Hook, entry *Entry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &HookPanicError{Value: r, Stack: debug.Stack()}
 		}
+	}()
+	return hook.Fire(entry)
+}
+
+// HookPanicError is the error reported for a hook whose Fire method
+// panicked.
+type HookPanicError struct {
+	// Value is the value the hook panicked with.
+	Value any
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *HookPanicError) Error() string {
+	return fmt.Sprintf("hook panicked: %v", e.Value)
+}
+
+// HookErrorPolicy controls how a [Logger] proceeds when one of its hooks
+// fails.
+type HookErrorPolicy uint32
+
+const (
+	// HookErrorStop stops firing the remaining hooks for an entry once a
+	// hook fails. This is the default.
+	HookErrorStop HookErrorPolicy = iota
+	// HookErrorContinue fires the remaining hooks for an entry after a hook
+	// fails.
+	HookErrorContinue
+	// HookErrorDisable fires the remaining hooks for an entry after a hook
+	// fails, and stops firing a hook altogether once it failed
+	// [Logger.MaxHookFailures] times in a row. A disabled hook is enabled
+	// again by [Logger.ResetHookFailures].
+	HookErrorDisable
+)
+
+// HookErrorHandler is called for every failed or panicking hook. A panic is
+// reported as a [*HookPanicError].
+type HookErrorHandler func(hook Hook, entry *Entry, err error)
+
+// defaultHookErrorHandler is used when a Logger has no HookErrorHandler.
+func defaultHookErrorHandler(_ Hook, _ *Entry, err error) {
+	_, _ = fmt.Fprintln(Stderr(), "Failed to fire hook:", err)
+}
+
+// HookFailure describes the failures of a hook, as reported by
+// [Logger.HookFailures].
+type HookFailure struct {
+	Hook Hook
+	// Failures is the total number of times the hook failed.
+	Failures uint64
+	// Consecutive is the number of failures since the hook last succeeded.
+	Consecutive int
+	// Disabled reports whether the hook was disabled by [HookErrorDisable].
+	Disabled bool
+	// LastError is the error of the most recent failure.
+	LastError error
+}
+
+// hookFailures tracks the failures of the hooks of a Logger.
+type hookFailures struct {
+	// seen is set once any hook has failed, so that the bookkeeping of
+	// successful calls can be skipped until then.
+	seen atomic.Bool
+
+	mu    sync.Mutex
+	hooks map[Hook]*HookFailure
+}
+
+// trackable reports whether the failures of hook can be tracked. Hooks of
+// incomparable types cannot be used as map keys.
+func trackable(hook Hook) bool {
+	return hook != nil && reflect.TypeOf(hook).Comparable()
+}
+
+func (f *hookFailures) disabled(hook Hook) bool {
+	if !f.seen.Load() || !trackable(hook) {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.hooks[hook]
+	return ok && s.Disabled
+}
+
+// record records the result of firing hook. If disableAfter is positive,
+// the hook is disabled once it failed that many times in a row.
+func (f *hookFailures) record(hook Hook, err error, disableAfter int) {
+	if (err == nil && !f.seen.Load()) || !trackable(hook) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.hooks[hook]
+	if err == nil {
+		if ok {
+			s.Consecutive = 0
+		}
+		return
 	}
+	if !ok {
+		if f.hooks == nil {
+			f.hooks = make(map[Hook]*HookFailure)
+		}
+		s = &HookFailure{Hook: hook}
+		f.hooks[hook] = s
+		f.seen.Store(true)
+	}
+	s.Failures++
+	s.Consecutive++
+	s.LastError = err
+	if disableAfter > 0 && s.Consecutive >= disableAfter {
+		s.Disabled = true
+	}
+}
 
-	return nil
+func (f *hookFailures) snapshot() []HookFailure {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]HookFailure, 0, len(f.hooks))
+	for _, s := range f.hooks {
+		out = append(out, *s)
+	}
+	return out
+}
+
+func (f *hookFailures) reset(hook Hook) {
+	if !trackable(hook) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.hooks, hook)
 }
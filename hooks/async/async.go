@@ -0,0 +1,450 @@
+// Package async provides a [logrus.Hook] wrapper that delivers entries to
+// another hook in the background.
+//
+// Hooks are fired synchronously while an entry is logged, so a slow hook,
+// for example one sending entries over the network, delays every log call.
+// A [Hook] created with [New] instead copies the entry into a bounded queue
+// and returns immediately. Worker goroutines fire the wrapped hook, either
+// one entry at a time or, for hooks implementing [BatchHook], in batches.
+//
+// Call [Hook.Close] before the program exits to deliver the queued entries:
+//
+//	hook := async.New(slowHook, nil)
+//	logger.AddHook(hook)
+//	defer hook.Close(context.Background())
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultQueueSize is the queue size used if Options.QueueSize is 0.
+	DefaultQueueSize = 1024
+	// DefaultBatchSize is the batch size used for a [BatchHook] if
+	// Options.BatchSize is 0.
+	DefaultBatchSize = 100
+	// DefaultFlushInterval is the flush interval used for a [BatchHook] if
+	// Options.FlushInterval is 0.
+	DefaultFlushInterval = time.Second
+)
+
+var (
+	// ErrClosed is returned by Fire after the hook has been closed.
+	ErrClosed = errors.New("async: hook closed")
+	// ErrTimeout is reported when the wrapped hook does not return within
+	// Options.Timeout.
+	ErrTimeout = errors.New("async: hook timed out")
+)
+
+// BatchHook is a hook that can receive several entries at once, such as a
+// hook that ships entries to a remote service. A [Hook] wrapping a
+// BatchHook calls FireBatch instead of Fire.
+type BatchHook interface {
+	logrus.Hook
+	// FireBatch is called with one or more entries. The entries are owned
+	// by the hook. If only some of the entries could not be delivered, it
+	// returns a [*BatchError] holding them.
+	FireBatch(entries []*logrus.Entry) error
+}
+
+// BatchError is returned by [BatchHook.FireBatch] if only some entries of
+// a batch could not be delivered. The other entries count as delivered.
+type BatchError struct {
+	Err     error
+	Entries []*logrus.Entry
+}
+
+func (e *BatchError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// DropPolicy determines what happens to an entry that is logged while the
+// queue is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the entry being logged. This is the default, so
+	// that logging never blocks.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest queued entry to make room.
+	DropOldest
+	// Block waits until there is room in the queue.
+	Block
+)
+
+// Options are options for a [Hook].
+// A zero Options consists entirely of default values.
+type Options struct {
+	// QueueSize is the maximum number of queued entries. If 0,
+	// DefaultQueueSize is used.
+	QueueSize int
+
+	// Workers is the number of goroutines firing the wrapped hook. If 0,
+	// a single worker is used, which preserves the order of entries.
+	Workers int
+
+	// DropPolicy determines what happens when the queue is full.
+	DropPolicy DropPolicy
+
+	// Timeout limits the duration of a single call to the wrapped hook. A
+	// call that does not return in time is reported as ErrTimeout and
+	// abandoned; it keeps running in the background. If 0, calls are not
+	// limited.
+	Timeout time.Duration
+
+	// BatchSize is the maximum number of entries passed to FireBatch if the
+	// wrapped hook is a [BatchHook]. If 0, DefaultBatchSize is used.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits for its batch to
+	// fill up if the wrapped hook is a [BatchHook]. If 0,
+	// DefaultFlushInterval is used.
+	FlushInterval time.Duration
+
+	// ErrorHandler is called when the wrapped hook fails, with the entries
+	// it failed to deliver. A panic is reported as a
+	// [*logrus.HookPanicError]. If nil, errors are printed to stderr.
+	ErrorHandler func(err error, entries []*logrus.Entry)
+}
+
+// Stats are the delivery counters of a [Hook].
+type Stats struct {
+	// Queued is the number of entries currently queued or being delivered.
+	Queued int
+	// Delivered is the number of entries the wrapped hook accepted.
+	Delivered uint64
+	// Failed is the number of entries the wrapped hook failed to deliver,
+	// including timed out ones.
+	Failed uint64
+	// Dropped is the number of entries discarded because the queue was
+	// full.
+	Dropped uint64
+}
+
+// Hook delivers entries to a wrapped hook asynchronously.
+type Hook struct {
+	hook  logrus.Hook
+	batch BatchHook
+	opts  Options
+
+	queue chan *logrus.Entry
+	wg    sync.WaitGroup
+
+	// mu guards closed and the closing of queue against concurrent sends.
+	mu     sync.RWMutex
+	closed bool
+
+	// pendingMu guards pending, idle and flush.
+	pendingMu sync.Mutex
+	pending   int
+	idle      chan struct{}
+	flush     chan struct{}
+
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+var _ logrus.Hook = (*Hook)(nil)
+
+// New returns a Hook that delivers entries to hook in the background and
+// starts its workers. If opts is nil, the default options are used.
+//
+// New panics if hook is nil.
+func New(hook logrus.Hook, opts *Options) *Hook {
+	if hook == nil {
+		panic("cannot create async hook from nil hook")
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	h := &Hook{
+		hook:  hook,
+		opts:  *opts,
+		flush: make(chan struct{}),
+	}
+	if h.opts.QueueSize <= 0 {
+		h.opts.QueueSize = DefaultQueueSize
+	}
+	if h.opts.Workers <= 0 {
+		h.opts.Workers = 1
+	}
+	if h.opts.BatchSize <= 0 {
+		h.opts.BatchSize = DefaultBatchSize
+	}
+	if h.opts.FlushInterval <= 0 {
+		h.opts.FlushInterval = DefaultFlushInterval
+	}
+	if h.opts.ErrorHandler == nil {
+		h.opts.ErrorHandler = func(err error, _ []*logrus.Entry) {
+			_, _ = fmt.Fprintln(logrus.Stderr(), "Failed to fire hook:", err)
+		}
+	}
+	h.batch, _ = hook.(BatchHook)
+	h.queue = make(chan *logrus.Entry, h.opts.QueueSize)
+
+	h.wg.Add(h.opts.Workers)
+	for range h.opts.Workers {
+		if h.batch != nil {
+			go h.batchWorker()
+		} else {
+			go h.worker()
+		}
+	}
+	return h
+}
+
+// Levels returns the levels of the wrapped hook.
+func (h *Hook) Levels() []logrus.Level {
+	return h.hook.Levels()
+}
+
+// Fire queues a copy of entry for delivery. It only returns an error if the
+// hook has been closed; entries dropped because the queue is full are
+// counted in [Hook.Stats].
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	entry = snapshot(entry)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		return ErrClosed
+	}
+
+	h.addPending(1)
+	switch h.opts.DropPolicy {
+	case Block:
+		h.queue <- entry
+	case DropOldest:
+		for {
+			select {
+			case h.queue <- entry:
+				return nil
+			default:
+			}
+			select {
+			case <-h.queue:
+				h.dropped.Add(1)
+				h.addPending(-1)
+			default:
+			}
+		}
+	default:
+		select {
+		case h.queue <- entry:
+		default:
+			h.dropped.Add(1)
+			h.addPending(-1)
+		}
+	}
+	return nil
+}
+
+// snapshot copies entry so that it can be used after Fire returns, when the
+// logger reuses or modifies the original.
+func snapshot(entry *logrus.Entry) *logrus.Entry {
+	dup := entry.Dup()
+	dup.Level = entry.Level
+	dup.Message = entry.Message
+	if entry.Caller != nil {
+		caller := *entry.Caller
+		dup.Caller = &caller
+	}
+	return dup
+}
+
+// Flush waits until all queued entries have been delivered, sending
+// incomplete batches immediately. It returns ctx.Err()
+// if ctx is done first.
+func (h *Hook) Flush(ctx context.Context) error {
+	h.pendingMu.Lock()
+	if h.pending == 0 {
+		h.pendingMu.Unlock()
+		return nil
+	}
+	if h.idle == nil {
+		h.idle = make(chan struct{})
+	}
+	idle := h.idle
+	close(h.flush)
+	h.flush = make(chan struct{})
+	h.pendingMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting entries and waits until the queued entries have
+// been delivered. It returns ctx.Err() if ctx is done first, in which case
+// the remaining entries are still delivered in the background. Close may be
+// called more than once.
+func (h *Hook) Close(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current delivery counters.
+func (h *Hook) Stats() Stats {
+	h.pendingMu.Lock()
+	queued := h.pending
+	h.pendingMu.Unlock()
+	return Stats{
+		Queued:    queued,
+		Delivered: h.delivered.Load(),
+		Failed:    h.failed.Load(),
+		Dropped:   h.dropped.Load(),
+	}
+}
+
+func (h *Hook) addPending(n int) {
+	h.pendingMu.Lock()
+	defer h.pendingMu.Unlock()
+	h.pending += n
+	if h.pending == 0 && h.idle != nil {
+		close(h.idle)
+		h.idle = nil
+	}
+}
+
+// flushSignal returns a channel that is closed by the next call to Flush.
+func (h *Hook) flushSignal() <-chan struct{} {
+	h.pendingMu.Lock()
+	defer h.pendingMu.Unlock()
+	return h.flush
+}
+
+func (h *Hook) worker() {
+	defer h.wg.Done()
+	for entry := range h.queue {
+		h.deliver([]*logrus.Entry{entry}, func() error {
+			return h.hook.Fire(entry)
+		})
+	}
+}
+
+func (h *Hook) batchWorker() {
+	defer h.wg.Done()
+
+	var (
+		batch []*logrus.Entry
+		timer = time.NewTimer(h.opts.FlushInterval)
+	)
+	timer.Stop()
+	flush := h.flushSignal()
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		entries := batch
+		batch = nil
+		timer.Stop()
+		h.deliver(entries, func() error {
+			return h.batch.FireBatch(entries)
+		})
+	}
+
+	for {
+		select {
+		case entry, ok := <-h.queue:
+			if !ok {
+				send()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(h.opts.FlushInterval)
+			}
+			batch = append(batch, entry)
+			if len(batch) >= h.opts.BatchSize {
+				send()
+			}
+		case <-timer.C:
+			send()
+		case <-flush:
+			flush = h.flushSignal()
+			send()
+		}
+	}
+}
+
+// deliver calls fire for entries, enforcing the timeout and reporting
+// failures, and updates the counters.
+func (h *Hook) deliver(entries []*logrus.Entry, fire func() error) {
+	defer h.addPending(-len(entries))
+
+	err := h.call(fire)
+	if err != nil {
+		failed := entries
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) {
+			err, failed = batchErr.Err, batchErr.Entries
+		}
+		h.failed.Add(uint64(len(failed)))
+		h.delivered.Add(uint64(len(entries) - len(failed)))
+		h.opts.ErrorHandler(err, failed)
+		return
+	}
+	h.delivered.Add(uint64(len(entries)))
+}
+
+func (h *Hook) call(fire func() error) error {
+	if h.opts.Timeout <= 0 {
+		return safeCall(fire)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- safeCall(fire)
+	}()
+	timer := time.NewTimer(h.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case err := <-errc:
+		return err
+	case <-timer.C:
+		return ErrTimeout
+	}
+}
+
+// safeCall calls fire, converting a panic into a [*logrus.HookPanicError],
+// since there is no logging call on a worker goroutine to recover it.
+func safeCall(fire func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &logrus.HookPanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fire()
+}
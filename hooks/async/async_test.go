@@ -0,0 +1,256 @@
+package async_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/async"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// blockingHook records entries and blocks in Fire until release is closed.
+type blockingHook struct {
+	test.Hook
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHook() *blockingHook {
+	return &blockingHook{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (h *blockingHook) Fire(e *logrus.Entry) error {
+	select {
+	case h.started <- struct{}{}:
+	default:
+	}
+	<-h.release
+	return h.Hook.Fire(e)
+}
+
+type batchHook struct {
+	mu      sync.Mutex
+	batches [][]*logrus.Entry
+}
+
+func (h *batchHook) Levels() []logrus.Level { return logrus.AllLevels }
+func (h *batchHook) Fire(*logrus.Entry) error {
+	panic("Fire must not be called for a BatchHook")
+}
+
+func (h *batchHook) FireBatch(entries []*logrus.Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.batches = append(h.batches, entries)
+	return nil
+}
+
+func (h *batchHook) sizes() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var sizes []int
+	for _, b := range h.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func TestAsyncDelivery(t *testing.T) {
+	inner := &test.Hook{}
+	hook := async.New(inner, nil)
+
+	logger, _ := test.NewNullLogger()
+	logger.AddHook(hook)
+	entry := logger.WithField("key", "value")
+	entry.Info("first")
+	entry.Data["key"] = "changed"
+	entry.Warn("second")
+
+	require.NoError(t, hook.Close(context.Background()))
+
+	entries := inner.AllEntries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "first", entries[0].Message)
+	assert.Equal(t, logrus.InfoLevel, entries[0].Level)
+	assert.Equal(t, "value", entries[0].Data["key"])
+	assert.Equal(t, "second", entries[1].Message)
+	assert.Equal(t, logrus.WarnLevel, entries[1].Level)
+	assert.Equal(t, "changed", entries[1].Data["key"])
+
+	assert.ErrorIs(t, hook.Fire(logrus.NewEntry(logger)), async.ErrClosed)
+	assert.Equal(t, async.Stats{Delivered: 2}, hook.Stats())
+}
+
+func TestAsyncDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy   async.DropPolicy
+		messages []string
+	}{
+		{async.DropNewest, []string{"0", "1", "2"}},
+		{async.DropOldest, []string{"0", "3", "4"}},
+	}
+	for _, tc := range tests {
+		inner := newBlockingHook()
+		hook := async.New(inner, &async.Options{QueueSize: 2, DropPolicy: tc.policy})
+		logger, _ := test.NewNullLogger()
+		logger.AddHook(hook)
+
+		logger.Info("0")
+		// Wait until the worker has taken the first entry, so that the
+		// queue is empty.
+		<-inner.started
+		for _, msg := range []string{"1", "2", "3", "4"} {
+			logger.Info(msg)
+		}
+		assert.Equal(t, uint64(2), hook.Stats().Dropped)
+
+		close(inner.release)
+		require.NoError(t, hook.Close(context.Background()))
+		var got []string
+		for _, e := range inner.AllEntries() {
+			got = append(got, e.Message)
+		}
+		assert.Equal(t, tc.messages, got)
+	}
+}
+
+func TestAsyncBatching(t *testing.T) {
+	inner := &batchHook{}
+	hook := async.New(inner, &async.Options{BatchSize: 3, FlushInterval: time.Hour})
+	logger, _ := test.NewNullLogger()
+	logger.AddHook(hook)
+
+	for range 4 {
+		logger.Info("test")
+	}
+	require.Eventually(t, func() bool { return len(inner.sizes()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []int{3}, inner.sizes())
+
+	// The incomplete batch is sent by Flush instead of waiting an hour.
+	require.NoError(t, hook.Flush(context.Background()))
+	assert.Equal(t, []int{3, 1}, inner.sizes())
+
+	logger.Info("last")
+	require.NoError(t, hook.Close(context.Background()))
+	assert.Equal(t, []int{3, 1, 1}, inner.sizes())
+}
+
+func TestAsyncFlushInterval(t *testing.T) {
+	inner := &batchHook{}
+	hook := async.New(inner, &async.Options{FlushInterval: 10 * time.Millisecond})
+	defer hook.Close(context.Background())
+	logger, _ := test.NewNullLogger()
+	logger.AddHook(hook)
+
+	logger.Info("test")
+	require.Eventually(t, func() bool { return len(inner.sizes()) == 1 }, time.Second, time.Millisecond)
+}
+
+// rejectingHook fails the entries of a batch with the message "rejected".
+type rejectingHook struct{ batchHook }
+
+func (h *rejectingHook) FireBatch(entries []*logrus.Entry) error {
+	var rejected []*logrus.Entry
+	for _, e := range entries {
+		if e.Message == "rejected" {
+			rejected = append(rejected, e)
+		}
+	}
+	return &async.BatchError{Err: errors.New("rejected"), Entries: rejected}
+}
+
+func TestAsyncBatchError(t *testing.T) {
+	var failed []*logrus.Entry
+	hook := async.New(&rejectingHook{}, &async.Options{
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+		ErrorHandler: func(err error, entries []*logrus.Entry) {
+			assert.EqualError(t, err, "rejected")
+			failed = append(failed, entries...)
+		},
+	})
+	logger, _ := test.NewNullLogger()
+	logger.AddHook(hook)
+
+	logger.Info("ok")
+	logger.Info("rejected")
+	logger.Info("ok")
+	require.NoError(t, hook.Close(context.Background()))
+
+	require.Len(t, failed, 1)
+	assert.Equal(t, "rejected", failed[0].Message)
+	assert.Equal(t, async.Stats{Delivered: 2, Failed: 1}, hook.Stats())
+}
+
+type funcHook func(*logrus.Entry) error
+
+func (f funcHook) Levels() []logrus.Level     { return logrus.AllLevels }
+func (f funcHook) Fire(e *logrus.Entry) error { return f(e) }
+
+func TestAsyncErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	release := make(chan struct{})
+	defer close(release)
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	hook := async.New(funcHook(func(e *logrus.Entry) error {
+		switch e.Message {
+		case "error":
+			return errBoom
+		case "panic":
+			panic("boom")
+		case "slow":
+			<-release
+		}
+		return nil
+	}), &async.Options{
+		Timeout: 20 * time.Millisecond,
+		ErrorHandler: func(err error, entries []*logrus.Entry) {
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, entries, 1)
+			errs = append(errs, err)
+		},
+	})
+	logger, _ := test.NewNullLogger()
+	logger.AddHook(hook)
+
+	logger.Info("error")
+	logger.Info("panic")
+	logger.Info("slow")
+	logger.Info("ok")
+	require.NoError(t, hook.Close(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, errs, 3)
+	assert.ErrorIs(t, errs[0], errBoom)
+	var panicErr *logrus.HookPanicError
+	assert.ErrorAs(t, errs[1], &panicErr)
+	assert.ErrorIs(t, errs[2], async.ErrTimeout)
+	assert.Equal(t, async.Stats{Delivered: 1, Failed: 3}, hook.Stats())
+}
+
+func TestAsyncCloseTimeout(t *testing.T) {
+	inner := newBlockingHook()
+	defer close(inner.release)
+	hook := async.New(inner, nil)
+	logger, _ := test.NewNullLogger()
+	logger.AddHook(hook)
+	logger.Info("test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, hook.Close(ctx), context.DeadlineExceeded)
+	assert.ErrorIs(t, hook.Flush(ctx), context.DeadlineExceeded)
+}
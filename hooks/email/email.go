@@ -0,0 +1,476 @@
+// Package email provides a hook sending severe entries to operators by
+// email, collected into periodic digests rather than one mail per entry.
+//
+//	hook, err := email.New(&email.Options{
+//		Addr:     "smtp.example.com:587",
+//		Username: "alerts",
+//		Password: password,
+//		From:     "batch@example.com",
+//		To:       []string{"ops@example.com"},
+//		Subject:  "nightly import",
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer hook.Close()
+//	logger.AddHook(hook)
+//
+// Each digest has a plain text part, formatted by a [logrus.TextFormatter],
+// and an HTML part listing the entries in a table. The pending digests of
+// the hooks that are not closed are sent by an exit handler registered
+// with [logrus.RegisterExitHandler] when the program exits through
+// [logrus.Exit] or a Fatal entry.
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultInterval is the maximum time an entry waits for its digest if
+	// Options.Interval is 0.
+	DefaultInterval = 5 * time.Minute
+	// DefaultMaxEntries is the number of entries that triggers sending a
+	// digest early if Options.MaxEntries is 0.
+	DefaultMaxEntries = 100
+	// DefaultSubject is the subject prefix if Options.Subject is empty.
+	DefaultSubject = "Log digest"
+	// DefaultTimeout is the timeout of an SMTP session if Options.Timeout
+	// is 0.
+	DefaultTimeout = 30 * time.Second
+)
+
+// ErrClosed is returned by Fire after the hook has been closed.
+var ErrClosed = errors.New("email: hook closed")
+
+// Options are options for a [Hook].
+type Options struct {
+	// Addr is the host and port of the SMTP server.
+	Addr string
+
+	// Username and Password are used for PLAIN authentication if Username
+	// is not empty. Auth, if set, is used instead.
+	Username string
+	Password string
+	Auth     smtp.Auth
+
+	// TLSConfig is the TLS configuration for STARTTLS and ImplicitTLS. If
+	// nil, a configuration verifying the host name of Addr is used.
+	TLSConfig *tls.Config
+
+	// ImplicitTLS connects using TLS from the start, usually on port 465.
+	// Otherwise STARTTLS is used if the server supports it.
+	ImplicitTLS bool
+
+	// RequireTLS refuses to send digests over a connection that is not
+	// encrypted.
+	RequireTLS bool
+
+	// From and To are the sender and recipients.
+	From string
+	To   []string
+
+	// Subject is the beginning of the subjects of the digests, which are
+	// followed by the number of entries. If empty, DefaultSubject is used.
+	Subject string
+
+	// Levels are the levels of the entries sent. If nil, Error, Fatal and
+	// Panic entries are sent.
+	Levels []logrus.Level
+
+	// Interval is the maximum time an entry waits before its digest is
+	// sent. If 0, DefaultInterval is used.
+	Interval time.Duration
+
+	// MaxEntries is the number of entries that triggers sending a digest
+	// before the interval elapses. If 0, DefaultMaxEntries is used.
+	MaxEntries int
+
+	// Formatter formats the entries of the plain text part. If nil, a
+	// TextFormatter with full timestamps and without colors is used.
+	Formatter logrus.Formatter
+
+	// Timeout limits an SMTP session. If 0, DefaultTimeout is used.
+	Timeout time.Duration
+
+	// ErrorHandler is called when a digest sent in the background fails.
+	// If nil, errors are printed to stderr.
+	ErrorHandler func(err error)
+}
+
+// Hook collects entries and sends them as email digests.
+type Hook struct {
+	opts Options
+	host string
+
+	mu      sync.Mutex
+	pending []*logrus.Entry
+	timer   *time.Timer
+	// flushing is set while digests of MaxEntries entries are sent in the
+	// background.
+	flushing bool
+	closed   bool
+
+	// sendMu serializes sending, so that digests are sent in order.
+	sendMu sync.Mutex
+}
+
+var _ logrus.Hook = (*Hook)(nil)
+
+var (
+	// hooks are the open hooks, flushed by the exit handler.
+	hooksMu      sync.Mutex
+	hooks        = make(map[*Hook]struct{})
+	registerExit sync.Once
+)
+
+// flushAll sends the pending digests of the open hooks before the program
+// exits.
+func flushAll() {
+	hooksMu.Lock()
+	open := make([]*Hook, 0, len(hooks))
+	for h := range hooks {
+		open = append(open, h)
+	}
+	hooksMu.Unlock()
+
+	for _, h := range open {
+		if err := h.Flush(); err != nil {
+			h.opts.ErrorHandler(err)
+		}
+	}
+}
+
+// New returns a Hook. It returns an error if Addr, From or To is missing.
+//
+// New panics if opts is nil.
+func New(opts *Options) (*Hook, error) {
+	if opts == nil {
+		panic("cannot create email hook from nil options")
+	}
+	h := &Hook{opts: *opts}
+	host, _, err := net.SplitHostPort(h.opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("email: invalid address: %w", err)
+	}
+	h.host = host
+	if h.opts.From == "" || len(h.opts.To) == 0 {
+		return nil, errors.New("email: sender and recipients are required")
+	}
+	if h.opts.Subject == "" {
+		h.opts.Subject = DefaultSubject
+	}
+	if h.opts.Levels == nil {
+		h.opts.Levels = []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+	}
+	if h.opts.Interval <= 0 {
+		h.opts.Interval = DefaultInterval
+	}
+	if h.opts.MaxEntries <= 0 {
+		h.opts.MaxEntries = DefaultMaxEntries
+	}
+	if h.opts.Formatter == nil {
+		h.opts.Formatter = &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+	}
+	if h.opts.Timeout <= 0 {
+		h.opts.Timeout = DefaultTimeout
+	}
+	if h.opts.ErrorHandler == nil {
+		h.opts.ErrorHandler = func(err error) {
+			_, _ = fmt.Fprintln(logrus.Stderr(), "Failed to fire hook:", err)
+		}
+	}
+	if h.opts.Auth == nil && h.opts.Username != "" {
+		h.opts.Auth = smtp.PlainAuth("", h.opts.Username, h.opts.Password, host)
+	}
+	if h.opts.TLSConfig == nil {
+		h.opts.TLSConfig = &tls.Config{ServerName: host}
+	}
+
+	registerExit.Do(func() { logrus.RegisterExitHandler(flushAll) })
+	hooksMu.Lock()
+	hooks[h] = struct{}{}
+	hooksMu.Unlock()
+	return h, nil
+}
+
+// Levels returns Options.Levels.
+func (h *Hook) Levels() []logrus.Level {
+	return h.opts.Levels
+}
+
+// Fire adds entry to the pending digest, which is sent in the background
+// once it has MaxEntries entries or Interval has elapsed since its first
+// entry. It returns ErrClosed after the hook has been closed.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	dup := entry.Dup()
+	dup.Level = entry.Level
+	dup.Message = entry.Message
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrClosed
+	}
+	h.pending = append(h.pending, dup)
+	switch {
+	case len(h.pending) >= h.opts.MaxEntries:
+		if !h.flushing {
+			h.flushing = true
+			go h.flushFull()
+		}
+	case h.timer == nil:
+		h.timer = time.AfterFunc(h.opts.Interval, h.flushBackground)
+	}
+	return nil
+}
+
+func (h *Hook) flushBackground() {
+	if err := h.Flush(); err != nil {
+		h.opts.ErrorHandler(err)
+	}
+}
+
+// flushFull sends the pending digest until fewer than MaxEntries entries
+// are pending, so that a single goroutine sends the digests filled up
+// while a digest is sent.
+func (h *Hook) flushFull() {
+	for {
+		h.flushBackground()
+		h.mu.Lock()
+		if h.closed || len(h.pending) < h.opts.MaxEntries {
+			h.flushing = false
+			h.mu.Unlock()
+			return
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Flush sends the pending digest, if any.
+func (h *Hook) Flush() error {
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+
+	h.mu.Lock()
+	entries := h.pending
+	h.pending = nil
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	h.mu.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+	msg, err := h.message(entries, time.Now())
+	if err != nil {
+		return err
+	}
+	return h.send(msg)
+}
+
+// Close stops accepting entries and sends the pending digest. Fire
+// returns ErrClosed afterwards, and the exit handler no longer flushes the
+// hook.
+func (h *Hook) Close() error {
+	h.mu.Lock()
+	closed := h.closed
+	h.closed = true
+	h.mu.Unlock()
+	if closed {
+		return nil
+	}
+
+	hooksMu.Lock()
+	delete(hooks, h)
+	hooksMu.Unlock()
+	return h.Flush()
+}
+
+// message returns the digest of entries as a MIME message.
+func (h *Hook) message(entries []*logrus.Entry, now time.Time) ([]byte, error) {
+	var text bytes.Buffer
+	for _, entry := range entries {
+		line, err := h.opts.Formatter.Format(entry)
+		if err != nil {
+			return nil, err
+		}
+		text.Write(line)
+	}
+	var html bytes.Buffer
+	if err := htmlTemplate.Execute(&html, htmlData(entries)); err != nil {
+		return nil, err
+	}
+
+	var boundary [12]byte
+	_, _ = rand.Read(boundary[:])
+	b := "digest-" + hex.EncodeToString(boundary[:])
+
+	var msg bytes.Buffer
+	subject := fmt.Sprintf("%s: %d %s", h.opts.Subject, len(entries), plural(len(entries), "entry", "entries"))
+	fmt.Fprintf(&msg, "From: %s\r\n", h.opts.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(h.opts.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mimeHeader(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", b)
+	for _, part := range []struct {
+		typ  string
+		body []byte
+	}{
+		{"text/plain", text.Bytes()},
+		{"text/html", html.Bytes()},
+	} {
+		fmt.Fprintf(&msg, "--%s\r\n", b)
+		fmt.Fprintf(&msg, "Content-Type: %s; charset=utf-8\r\n", part.typ)
+		msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qw := quotedprintable.NewWriter(&msg)
+		if _, err := qw.Write(part.body); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+		msg.WriteString("\r\n")
+	}
+	fmt.Fprintf(&msg, "--%s--\r\n", b)
+	return msg.Bytes(), nil
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// mimeHeader encodes s for a header if it is not plain ASCII.
+func mimeHeader(s string) string {
+	for _, r := range s {
+		if r >= 0x80 || r < 0x20 {
+			return mime.QEncoding.Encode("utf-8", s)
+		}
+	}
+	return s
+}
+
+type htmlEntry struct {
+	Time    string
+	Level   string
+	Message string
+	Fields  []string
+}
+
+func htmlData(entries []*logrus.Entry) []htmlEntry {
+	data := make([]htmlEntry, len(entries))
+	for i, entry := range entries {
+		keys := make([]string, 0, len(entry.Data))
+		for k := range entry.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]string, len(keys))
+		for j, k := range keys {
+			fields[j] = fmt.Sprintf("%s=%v", k, entry.Data[k])
+		}
+		data[i] = htmlEntry{
+			Time:    entry.Time.Format(time.RFC3339),
+			Level:   strings.ToUpper(entry.Level.String()),
+			Message: entry.Message,
+			Fields:  fields,
+		}
+	}
+	return data
+}
+
+var htmlTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html><body>
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse: collapse; font-family: monospace">
+<tr><th>Time</th><th>Level</th><th>Message</th><th>Fields</th></tr>
+{{range .}}<tr><td>{{.Time}}</td><td>{{.Level}}</td><td>{{.Message}}</td><td>{{range .Fields}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+// send sends msg in a single SMTP session.
+func (h *Hook) send(msg []byte) error {
+	deadline := time.Now().Add(h.opts.Timeout)
+	dialer := &net.Dialer{Deadline: deadline}
+	var conn net.Conn
+	var err error
+	if h.opts.ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", h.opts.Addr, h.opts.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", h.opts.Addr)
+	}
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, h.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("email: %w", err)
+	}
+	defer c.Close()
+	if err := h.session(c, msg); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return nil
+}
+
+func (h *Hook) session(c *smtp.Client, msg []byte) error {
+	if !h.opts.ImplicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(h.opts.TLSConfig); err != nil {
+				return err
+			}
+		} else if h.opts.RequireTLS {
+			return errors.New("server does not support STARTTLS")
+		}
+	}
+	if h.opts.Auth != nil {
+		if err := c.Auth(h.opts.Auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(h.opts.From); err != nil {
+		return err
+	}
+	for _, to := range h.opts.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, bytes.NewReader(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,304 @@
+package email_test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/email"
+)
+
+// smtpServer is a minimal SMTP server recording the received messages.
+type smtpServer struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []message
+	// hold, if set, delays the greeting until it is closed.
+	hold chan struct{}
+}
+
+type message struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	var msg message
+
+	s.mu.Lock()
+	hold := s.hold
+	s.mu.Unlock()
+	if hold != nil {
+		<-hold
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			_, creds, _ := strings.Cut(arg, " ")
+			b, _ := base64.StdEncoding.DecodeString(creds)
+			msg.auth = string(b)
+			reply("235 OK")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = message{}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) received() []message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]message(nil), s.messages...)
+}
+
+func newHook(t *testing.T, s *smtpServer, opts email.Options) *email.Hook {
+	t.Helper()
+	opts.Addr = s.ln.Addr().String()
+	opts.From = "job@example.com"
+	opts.To = []string{"ops@example.com", "dev@example.com"}
+	opts.ErrorHandler = func(err error) { t.Error(err) }
+	hook, err := email.New(&opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = hook.Close() })
+	return hook
+}
+
+func newLogger(hook logrus.Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+	return logger
+}
+
+// parts returns the subject and the decoded parts of a message by content
+// type.
+func parts(t *testing.T, data string) (string, map[string]string) {
+	t.Helper()
+	m, err := mail.ReadMessage(strings.NewReader(data))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	out := make(map[string]string)
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		typ, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		b, err := io.ReadAll(p)
+		require.NoError(t, err)
+		out[typ] = string(b)
+	}
+	return m.Header.Get("Subject"), out
+}
+
+func TestDigest(t *testing.T) {
+	s := newSMTPServer(t)
+	hook := newHook(t, s, email.Options{Username: "user", Password: "secret", Subject: "import"})
+	logger := newLogger(hook)
+
+	logger.Info("not sent")
+	logger.WithField("file", "a.csv").Error("bad <row>")
+	logger.Error("second")
+	assert.Empty(t, s.received(), "entries are collected")
+	require.NoError(t, hook.Flush())
+
+	msgs := s.received()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "\x00user\x00secret", msgs[0].auth)
+	assert.Equal(t, "job@example.com", msgs[0].from)
+	assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, msgs[0].to)
+
+	subject, body := parts(t, msgs[0].data)
+	assert.Equal(t, "import: 2 entries", subject)
+	assert.Contains(t, body["text/plain"], `level=error msg="bad <row>" file=a.csv`)
+	assert.Contains(t, body["text/plain"], "msg=second")
+	assert.NotContains(t, body["text/plain"], "not sent")
+	assert.Contains(t, body["text/html"], "bad &lt;row&gt;")
+	assert.Contains(t, body["text/html"], "file=a.csv")
+
+	require.NoError(t, hook.Flush())
+	assert.Len(t, s.received(), 1, "nothing is sent without pending entries")
+}
+
+func TestDigestInterval(t *testing.T) {
+	s := newSMTPServer(t)
+	hook := newHook(t, s, email.Options{Interval: 20 * time.Millisecond})
+	logger := newLogger(hook)
+	logger.Error("one")
+	logger.Error("two")
+
+	require.Eventually(t, func() bool { return len(s.received()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	subject, _ := parts(t, s.received()[0].data)
+	assert.Equal(t, "Log digest: 2 entries", subject)
+}
+
+func TestDigestMaxEntries(t *testing.T) {
+	s := newSMTPServer(t)
+	hook := newHook(t, s, email.Options{MaxEntries: 3, Interval: time.Hour})
+	logger := newLogger(hook)
+	for range 3 {
+		logger.Error("failure")
+	}
+
+	require.Eventually(t, func() bool { return len(s.received()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	subject, _ := parts(t, s.received()[0].data)
+	assert.Equal(t, "Log digest: 3 entries", subject)
+}
+
+func TestDigestMaxEntriesSingleFlush(t *testing.T) {
+	s := newSMTPServer(t)
+	hold := make(chan struct{})
+	s.mu.Lock()
+	s.hold = hold
+	s.mu.Unlock()
+	hook := newHook(t, s, email.Options{MaxEntries: 1, Interval: time.Hour})
+	logger := newLogger(hook)
+
+	// While the first digest is being sent, full digests do not start
+	// more goroutines.
+	logger.Error("first")
+	before := runtime.NumGoroutine()
+	for range 100 {
+		logger.Error("failure")
+	}
+	assert.Less(t, runtime.NumGoroutine()-before, 10)
+	close(hold)
+
+	require.Eventually(t, func() bool {
+		n := 0
+		for _, msg := range s.received() {
+			n += strings.Count(msg.data, "msg=")
+		}
+		return n == 101
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestClose(t *testing.T) {
+	s := newSMTPServer(t)
+	hook := newHook(t, s, email.Options{Interval: time.Hour})
+	logger := newLogger(hook)
+	logger.Error("pending")
+
+	require.NoError(t, hook.Close())
+	assert.Len(t, s.received(), 1, "Close sends the pending digest")
+	assert.ErrorIs(t, hook.Fire(logrus.NewEntry(logger)), email.ErrClosed)
+	assert.NoError(t, hook.Close())
+	assert.Len(t, s.received(), 1)
+}
+
+func TestDigestOnExit(t *testing.T) {
+	s := newSMTPServer(t)
+	hook := newHook(t, s, email.Options{Interval: time.Hour})
+	logger := newLogger(hook)
+	var code int
+	logger.ExitFunc = func(c int) { code = c }
+
+	logger.Fatal("giving up")
+	assert.Equal(t, 1, code)
+	msgs := s.received()
+	require.Len(t, msgs, 1)
+	_, body := parts(t, msgs[0].data)
+	assert.Contains(t, body["text/plain"], "level=fatal msg=\"giving up\"")
+}
+
+func TestRequireTLS(t *testing.T) {
+	s := newSMTPServer(t)
+	var got error
+	hook, err := email.New(&email.Options{
+		Addr:         s.ln.Addr().String(),
+		From:         "job@example.com",
+		To:           []string{"ops@example.com"},
+		RequireTLS:   true,
+		ErrorHandler: func(err error) { got = err },
+	})
+	require.NoError(t, err)
+	defer hook.Close()
+	newLogger(hook).Error("secret")
+	assert.ErrorContains(t, hook.Flush(), "STARTTLS")
+	assert.NoError(t, got)
+	assert.Empty(t, s.received())
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	_, err := email.New(&email.Options{Addr: "localhost", From: "a@example.com", To: []string{"b@example.com"}})
+	assert.Error(t, err)
+	_, err = email.New(&email.Options{Addr: "localhost:25"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,272 @@
+// Package file provides a hook writing entries to different files by level
+// or field, for example errors to error.log and requests to access.log:
+//
+//	hook := file.New(&file.Options{
+//		Routes: []file.Route{
+//			{Match: filter.LevelAtLeast(logrus.ErrorLevel), Path: "/var/log/app/error.log", Continue: true},
+//			{Match: filter.FieldEquals("component", "http"), Path: "/var/log/app/access.log"},
+//			{Path: "/var/log/app/app.log", Formatter: &logrus.JSONFormatter{}},
+//		},
+//	})
+//	logger.AddHook(hook)
+//	defer logger.Close()
+//
+// A path may contain field placeholders such as "/var/log/app/{component}.log",
+// which are replaced by the values of the entry's fields, so that each
+// component gets its own file. Files are opened when the first entry is
+// written to them, and the least recently used file is closed when more
+// than [Options.MaxOpenFiles] files would be open. Rotation is supported by
+// opening files with a writer that rotates them, see [Options.Open].
+//
+// The routes are delivered by a [filter.Router].
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/filter"
+)
+
+// DefaultMaxOpenFiles is the maximum number of open files of a Hook if
+// Options.MaxOpenFiles is 0.
+const DefaultMaxOpenFiles = 64
+
+// Route selects the entries written to a file.
+type Route struct {
+	// Match selects the entries written to the file. If nil, every entry
+	// is written.
+	Match filter.Predicate
+
+	// Path is the path of the file. Placeholders of the form {field} are
+	// replaced by the value of the field, with path separators replaced
+	// by underscores. Entries without such a field do not match the route.
+	Path string
+
+	// Formatter formats the entries written to the file. If nil, the
+	// formatter of the entry's logger is used.
+	Formatter logrus.Formatter
+
+	// Continue makes the hook try the following routes after this one
+	// matched. By default, an entry is written to the first matching
+	// route only.
+	Continue bool
+}
+
+// Options are options for a [Hook].
+type Options struct {
+	// Routes are tried in order for every entry.
+	Routes []Route
+
+	// Levels are the levels of the entries written. If nil, all levels are
+	// written.
+	Levels []logrus.Level
+
+	// Open opens the file at path for appending. It may return a writer
+	// that rotates the file. If nil, the file and its directory are
+	// created if needed, and the file is opened for appending.
+	Open func(path string) (io.WriteCloser, error)
+
+	// MaxOpenFiles is the maximum number of files kept open. Opening
+	// another file closes the least recently written one, which is opened
+	// again for the next entry written to it. Paths with placeholders may
+	// otherwise open a file for every value of a field. If 0,
+	// DefaultMaxOpenFiles is used.
+	MaxOpenFiles int
+}
+
+// Hook writes entries to files.
+type Hook struct {
+	opts   Options
+	router *filter.Router
+
+	// mu guards files and serializes writes.
+	mu    sync.Mutex
+	files map[string]*handle
+	// clock orders the writes to files, to find the least recently used.
+	clock uint64
+}
+
+// handle is an open file of a Hook.
+type handle struct {
+	w        io.WriteCloser
+	lastUsed uint64
+}
+
+var (
+	_ logrus.Hook = (*Hook)(nil)
+	_ io.Closer   = (*Hook)(nil)
+)
+
+var placeholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// New returns a Hook writing to the files of opts.Routes.
+//
+// New panics if opts is nil.
+func New(opts *Options) *Hook {
+	if opts == nil {
+		panic("cannot create file hook from nil options")
+	}
+	h := &Hook{
+		opts:  *opts,
+		files: make(map[string]*handle),
+	}
+	h.opts.Routes = slices.Clone(h.opts.Routes)
+	if h.opts.Levels == nil {
+		h.opts.Levels = logrus.Levels()
+	}
+	if h.opts.Open == nil {
+		h.opts.Open = openFile
+	}
+	if h.opts.MaxOpenFiles <= 0 {
+		h.opts.MaxOpenFiles = DefaultMaxOpenFiles
+	}
+
+	routes := make([]filter.Route, len(h.opts.Routes))
+	for i, route := range h.opts.Routes {
+		rh := &routeHook{hook: h, route: route}
+		match := []filter.Predicate{route.Match}
+		for _, m := range placeholder.FindAllStringSubmatch(route.Path, -1) {
+			rh.fields = append(rh.fields, m[1])
+			match = append(match, filter.FieldExists(m[1]))
+		}
+		routes[i] = filter.Route{Match: filter.And(match...), Hook: rh, Continue: route.Continue}
+	}
+	h.router = filter.NewRouter(routes...)
+	return h
+}
+
+func openFile(path string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+}
+
+// Levels returns Options.Levels.
+func (h *Hook) Levels() []logrus.Level {
+	return h.opts.Levels
+}
+
+// Fire writes entry to the files of the matching routes. The errors of all
+// routes are joined.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	return h.router.Fire(entry)
+}
+
+// routeHook writes the entries of a route to its file.
+type routeHook struct {
+	hook   *Hook
+	route  Route
+	fields []string // placeholders of the path
+}
+
+func (rh *routeHook) Levels() []logrus.Level {
+	return rh.hook.opts.Levels
+}
+
+func (rh *routeHook) Fire(entry *logrus.Entry) error {
+	return rh.hook.write(&rh.route, rh.path(entry), entry)
+}
+
+// path returns the path of the route for entry, which has the fields of
+// all placeholders.
+func (rh *routeHook) path(entry *logrus.Entry) string {
+	if len(rh.fields) == 0 {
+		return rh.route.Path
+	}
+	return placeholder.ReplaceAllStringFunc(rh.route.Path, func(m string) string {
+		return sanitize(fmt.Sprint(entry.Data[m[1:len(m)-1]]))
+	})
+}
+
+// sanitize makes a field value safe to use as part of a file name.
+func sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator || r == 0 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+func (h *Hook) write(route *Route, path string, entry *logrus.Entry) error {
+	formatter := route.Formatter
+	if formatter == nil {
+		if entry.Logger == nil {
+			return errors.New("file: no formatter for entry without logger")
+		}
+		formatter = entry.Logger.Formatter
+	}
+	line, err := formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, ok := h.files[path]
+	var closeErr error
+	if !ok {
+		if len(h.files) >= h.opts.MaxOpenFiles {
+			closeErr = h.closeLeastRecentlyUsed()
+		}
+		w, err := h.opts.Open(path)
+		if err != nil {
+			return errors.Join(closeErr, err)
+		}
+		f = &handle{w: w}
+		h.files[path] = f
+	}
+	h.clock++
+	f.lastUsed = h.clock
+	_, err = f.w.Write(line)
+	return errors.Join(closeErr, err)
+}
+
+// closeLeastRecentlyUsed closes the file that was written least recently.
+// It must be called with h.mu held.
+func (h *Hook) closeLeastRecentlyUsed() error {
+	var (
+		lruPath string
+		lru     *handle
+	)
+	for path, f := range h.files {
+		if lru == nil || f.lastUsed < lru.lastUsed {
+			lruPath, lru = path, f
+		}
+	}
+	delete(h.files, lruPath)
+	if err := lru.w.Close(); err != nil {
+		return fmt.Errorf("file: closing %s: %w", lruPath, err)
+	}
+	return nil
+}
+
+// Close closes the open files and returns their joined errors. Files are
+// opened again when entries are written to them afterwards, so Close can
+// also be used to reopen files after they were rotated externally.
+func (h *Hook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var errs []error
+	for path, f := range h.files {
+		if err := f.w.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("file: closing %s: %w", path, err))
+		}
+		delete(h.files, path)
+	}
+	return errors.Join(errs...)
+}
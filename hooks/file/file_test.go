@@ -0,0 +1,154 @@
+package file_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/file"
+	"github.com/sirupsen/logrus/hooks/filter"
+)
+
+func newLogger(hook logrus.Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Formatter = &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true}
+	logger.AddHook(hook)
+	return logger
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestRoutes(t *testing.T) {
+	dir := t.TempDir()
+	hook := file.New(&file.Options{
+		Routes: []file.Route{
+			{Match: filter.LevelAtLeast(logrus.ErrorLevel), Path: filepath.Join(dir, "error.log"), Continue: true},
+			{Match: filter.FieldEquals("component", "http"), Path: filepath.Join(dir, "access.log"), Formatter: &logrus.JSONFormatter{DisableTimestamp: true}},
+			{Path: filepath.Join(dir, "app.log")},
+		},
+	})
+	logger := newLogger(hook)
+
+	logger.Info("started")
+	logger.WithField("component", "http").Info("GET /")
+	logger.Error("failed")
+	require.NoError(t, logger.Close())
+
+	assert.Equal(t, "level=error msg=failed\n", readFile(t, filepath.Join(dir, "error.log")))
+	assert.Equal(t, `{"component":"http","level":"info","msg":"GET /"}`+"\n", readFile(t, filepath.Join(dir, "access.log")))
+	assert.Equal(t, "level=info msg=started\nlevel=error msg=failed\n", readFile(t, filepath.Join(dir, "app.log")))
+}
+
+func TestFieldPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	hook := file.New(&file.Options{
+		Routes: []file.Route{
+			{Path: filepath.Join(dir, "{component}", "{component}.log")},
+			{Path: filepath.Join(dir, "other.log")},
+		},
+	})
+	logger := newLogger(hook)
+
+	logger.WithField("component", "db").Info("query")
+	logger.WithField("component", "../etc").Info("sneaky")
+	logger.Info("no component")
+	require.NoError(t, logger.Close())
+
+	assert.Equal(t, "level=info msg=query component=db\n", readFile(t, filepath.Join(dir, "db", "db.log")))
+	assert.Contains(t, readFile(t, filepath.Join(dir, ".._etc", ".._etc.log")), "sneaky")
+	assert.Equal(t, "level=info msg=\"no component\"\n", readFile(t, filepath.Join(dir, "other.log")))
+}
+
+// closeRecorder is a writer recording whether it was closed.
+type closeRecorder struct {
+	strings.Builder
+	closed bool
+}
+
+func (w *closeRecorder) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestOpenAndClose(t *testing.T) {
+	opened := map[string]*closeRecorder{}
+	hook := file.New(&file.Options{
+		Routes: []file.Route{{Path: "app.log"}},
+		Levels: []logrus.Level{logrus.ErrorLevel},
+		Open: func(path string) (io.WriteCloser, error) {
+			w := &closeRecorder{}
+			opened[path] = w
+			return w, nil
+		},
+	})
+	logger := newLogger(hook)
+	assert.Empty(t, opened, "files are opened lazily")
+
+	logger.Info("not written")
+	logger.Error("one")
+	logger.Error("two")
+	require.Len(t, opened, 1)
+	w := opened["app.log"]
+	assert.Equal(t, "level=error msg=one\nlevel=error msg=two\n", w.String())
+
+	require.NoError(t, logger.Close())
+	assert.True(t, w.closed)
+
+	// The file is reopened after closing.
+	logger.Error("three")
+	assert.NotSame(t, w, opened["app.log"])
+}
+
+func TestOpenError(t *testing.T) {
+	hook := file.New(&file.Options{
+		Routes: []file.Route{{Path: "app.log"}},
+		Open: func(string) (io.WriteCloser, error) {
+			return nil, errors.New("disk full")
+		},
+	})
+	entry := logrus.NewEntry(newLogger(hook))
+	entry.Message = "lost"
+	assert.EqualError(t, hook.Fire(entry), "disk full")
+}
+
+func TestMaxOpenFiles(t *testing.T) {
+	var opened []*closeRecorder
+	hook := file.New(&file.Options{
+		Routes:       []file.Route{{Path: "{user}.log"}},
+		MaxOpenFiles: 2,
+		Open: func(string) (io.WriteCloser, error) {
+			w := &closeRecorder{}
+			opened = append(opened, w)
+			return w, nil
+		},
+	})
+	logger := newLogger(hook)
+
+	logger.WithField("user", "alice").Info("one")
+	logger.WithField("user", "bob").Info("two")
+	logger.WithField("user", "alice").Info("three")
+	// Opening a third file closes bob's, the least recently written one.
+	logger.WithField("user", "carol").Info("four")
+	require.Len(t, opened, 3)
+	assert.False(t, opened[0].closed)
+	assert.True(t, opened[1].closed)
+
+	// Bob's file is opened again.
+	logger.WithField("user", "bob").Info("five")
+	require.Len(t, opened, 4)
+	assert.True(t, opened[0].closed)
+	require.NoError(t, logger.Close())
+}
@@ -0,0 +1,416 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SyntaxError is returned by [Parse] for an invalid expression.
+type SyntaxError struct {
+	// Offset is the byte offset in the expression at which the error was
+	// detected.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Parse parses a filter expression into a predicate. Expressions are meant
+// to be parsed once, for example when reading a configuration file, and
+// evaluated for every entry.
+//
+// An expression consists of comparisons combined with && (and), || (or),
+// ! (not) and parentheses:
+//
+//	level>=warn && component=="db"
+//	!(msg=~"^health") || caller=="github.com/acme/app/db"
+//
+// The left-hand side of a comparison names what is compared:
+//
+//   - level is the level of the entry. It supports ==, !=, <, <=, > and >=,
+//     with a level name on the right-hand side. A level is greater than
+//     another one if it is more severe, so level>=warn matches warnings,
+//     errors, fatal and panic entries.
+//   - msg is the message of the entry. It supports ==, != and the regular
+//     expression operators =~ and !~.
+//   - caller is the import path of the package the entry was logged from,
+//     see [CallerPackage]. It supports ==, !=, =~ and !~.
+//   - Any other name refers to a field. Fields support ==, != (see
+//     [FieldEquals]), =~ and !~ (see [FieldMatches]), and the numeric
+//     comparisons <, <=, > and >= with a number on the right-hand side. A
+//     field named like one of the names above can be referred to as
+//     data.name, for example data.level.
+//
+// A field name without a comparison matches entries that have the field.
+// A comparison with a missing field never matches, except for != and !~.
+//
+// The right-hand side is a double- or back-quoted string, a number, or a
+// bare word such as a level name or true.
+func Parse(expr string) (Predicate, error) {
+	p := &parser{lexer: lexer{input: expr}}
+	p.next()
+	pred := p.parseOr()
+	if p.err == nil && p.tok.kind != tokEOF {
+		p.fail(p.tok.pos, "unexpected %s", p.tok)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return pred, nil
+}
+
+// MustParse is like [Parse] but panics if the expression cannot be parsed.
+func MustParse(expr string) Predicate {
+	pred, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return pred
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	pos  int
+	text string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return "string " + t.text
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+type lexer struct {
+	input string
+	pos   int
+}
+
+// operators are sorted so that longer operators are matched first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.input) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	rest := l.input[l.pos:]
+	c := rest[0]
+	switch {
+	case c == '"' || c == '`':
+		prefix, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return token{}, &SyntaxError{Offset: start, Msg: "unterminated string"}
+		}
+		l.pos += len(prefix)
+		s, _ := strconv.Unquote(prefix)
+		return token{kind: tokString, pos: start, text: s}, nil
+	case c == '-' || c == '.' || ('0' <= c && c <= '9'):
+		end := strings.IndexFunc(rest[1:], func(r rune) bool {
+			return !strings.ContainsRune("0123456789.eE+-_", r)
+		})
+		if end < 0 {
+			end = len(rest)
+		} else {
+			end++
+		}
+		l.pos += end
+		return token{kind: tokNumber, pos: start, text: rest[:end]}, nil
+	case isIdentRune(firstRune(rest)):
+		end := strings.IndexFunc(rest, func(r rune) bool { return !isIdentRune(r) })
+		if end < 0 {
+			end = len(rest)
+		}
+		l.pos += end
+		return token{kind: tokIdent, pos: start, text: rest[:end]}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			l.pos += len(op)
+			return token{kind: tokOp, pos: start, text: op}, nil
+		}
+	}
+	return token{}, &SyntaxError{Offset: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '.' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type parser struct {
+	lexer lexer
+	tok   token
+	err   error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	tok, err := p.lexer.next()
+	if err != nil {
+		p.err = err
+		tok = token{kind: tokEOF, pos: p.lexer.pos}
+	}
+	p.tok = tok
+}
+
+func (p *parser) fail(pos int, format string, args ...any) {
+	if p.err == nil {
+		p.err = &SyntaxError{Offset: pos, Msg: fmt.Sprintf(format, args...)}
+	}
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+// parseOr parses: and { "||" and }.
+func (p *parser) parseOr() Predicate {
+	preds := []Predicate{p.parseAnd()}
+	for p.isOp("||") {
+		p.next()
+		preds = append(preds, p.parseAnd())
+	}
+	if len(preds) == 1 {
+		return preds[0]
+	}
+	return Or(preds...)
+}
+
+// parseAnd parses: unary { "&&" unary }.
+func (p *parser) parseAnd() Predicate {
+	preds := []Predicate{p.parseUnary()}
+	for p.isOp("&&") {
+		p.next()
+		preds = append(preds, p.parseUnary())
+	}
+	if len(preds) == 1 {
+		return preds[0]
+	}
+	return And(preds...)
+}
+
+// parseUnary parses: "!" unary | "(" or ")" | comparison.
+func (p *parser) parseUnary() Predicate {
+	switch {
+	case p.isOp("!"):
+		p.next()
+		return Not(p.parseUnary())
+	case p.isOp("("):
+		p.next()
+		pred := p.parseOr()
+		if !p.isOp(")") {
+			p.fail(p.tok.pos, "expected \")\", found %s", p.tok)
+		}
+		p.next()
+		return pred
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses: name [ operator value ].
+func (p *parser) parseComparison() Predicate {
+	if p.tok.kind != tokIdent {
+		p.fail(p.tok.pos, "expected name, found %s", p.tok)
+		return nil
+	}
+	name := p.tok.text
+	p.next()
+
+	if p.tok.kind != tokOp || !isComparison(p.tok.text) {
+		if field, ok := fieldName(name); ok {
+			return FieldExists(field)
+		}
+		p.fail(p.tok.pos, "expected comparison after %q", name)
+		return nil
+	}
+	op, opPos := p.tok.text, p.tok.pos
+	p.next()
+
+	value := p.tok
+	if value.kind != tokIdent && value.kind != tokString && value.kind != tokNumber {
+		p.fail(value.pos, "expected value, found %s", value)
+		return nil
+	}
+	p.next()
+
+	var (
+		pred Predicate
+		err  error
+	)
+	switch name {
+	case "level":
+		pred, err = levelComparison(op, value.text)
+	case "msg":
+		pred, err = stringComparison(op, value.text, func(e *logrus.Entry) (string, bool) {
+			return e.Message, true
+		})
+	case "caller":
+		pred, err = stringComparison(op, value.text, func(e *logrus.Entry) (string, bool) {
+			if e.Caller == nil {
+				return "", false
+			}
+			return callerPackage(e.Caller.Function), true
+		})
+	default:
+		field, _ := fieldName(name)
+		pred, err = fieldComparison(field, op, value)
+	}
+	if err != nil {
+		p.fail(opPos, "invalid comparison %s %s %s: %v", name, op, value.text, err)
+		return nil
+	}
+	return pred
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		return true
+	}
+	return false
+}
+
+// fieldName returns the field a name refers to, and false for the names
+// that are not fields.
+func fieldName(name string) (string, bool) {
+	switch name {
+	case "level", "msg", "caller":
+		return "", false
+	}
+	return strings.TrimPrefix(name, "data."), true
+}
+
+func levelComparison(op, value string) (Predicate, error) {
+	level, err := logrus.ParseLevel(value)
+	if err != nil {
+		return nil, err
+	}
+	// More severe levels have lower values.
+	switch op {
+	case "==":
+		return LevelIn(level), nil
+	case "!=":
+		return Not(LevelIn(level)), nil
+	case ">=":
+		return LevelAtLeast(level), nil
+	case "<=":
+		return LevelAtMost(level), nil
+	case ">":
+		return And(LevelAtLeast(level), Not(LevelIn(level))), nil
+	case "<":
+		return And(LevelAtMost(level), Not(LevelIn(level))), nil
+	}
+	return nil, fmt.Errorf("operator not supported for level")
+}
+
+func stringComparison(op, value string, get func(*logrus.Entry) (string, bool)) (Predicate, error) {
+	switch op {
+	case "==", "!=":
+		negate := op == "!="
+		return func(e *logrus.Entry) bool {
+			s, ok := get(e)
+			return (ok && s == value) != negate
+		}, nil
+	case "=~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		negate := op == "!~"
+		return func(e *logrus.Entry) bool {
+			s, ok := get(e)
+			return (ok && re.MatchString(s)) != negate
+		}, nil
+	}
+	return nil, fmt.Errorf("operator not supported for strings")
+}
+
+func fieldComparison(field, op string, value token) (Predicate, error) {
+	switch op {
+	case "==", "!=":
+		var want any = value.text
+		if value.kind == tokNumber {
+			f, err := strconv.ParseFloat(value.text, 64)
+			if err != nil {
+				return nil, err
+			}
+			want = f
+		}
+		pred := FieldEquals(field, want)
+		if op == "!=" {
+			pred = Not(pred)
+		}
+		return pred, nil
+	case "=~", "!~":
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, err
+		}
+		pred := FieldMatches(field, re)
+		if op == "!~" {
+			pred = Not(pred)
+		}
+		return pred, nil
+	}
+
+	if value.kind != tokNumber {
+		return nil, fmt.Errorf("operator requires a number")
+	}
+	want, err := strconv.ParseFloat(value.text, 64)
+	if err != nil {
+		return nil, err
+	}
+	return func(e *logrus.Entry) bool {
+		v, ok := e.Data[field]
+		if !ok {
+			return false
+		}
+		f, ok := toFloat(v)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "<":
+			return f < want
+		case "<=":
+			return f <= want
+		case ">":
+			return f > want
+		default:
+			return f >= want
+		}
+	}, nil
+}
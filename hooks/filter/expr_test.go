@@ -0,0 +1,96 @@
+package filter_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/filter"
+)
+
+func TestParse(t *testing.T) {
+	e := entry(logrus.WarnLevel, "health check failed", logrus.Fields{
+		"component": "db",
+		"status":    503,
+		"level":     "custom",
+		"ok":        false,
+	})
+	e.Caller = &runtime.Frame{Function: "github.com/acme/app/db.Query"}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`level>=warn`, true},
+		{`level>warn`, false},
+		{`level<=warn`, true},
+		{`level<warn`, false},
+		{`level==warning`, true},
+		{`level!="warn"`, false},
+		{`level>=error || level==debug`, false},
+		{`component=="db"`, true},
+		{`component==db`, true},
+		{`component!="db"`, false},
+		{`component=~"^d"`, true},
+		{`component!~"^d"`, false},
+		{`status==503`, true},
+		{`status>=500 && status<600`, true},
+		{`status<500`, false},
+		{`status>500.5`, true},
+		{`missing>1`, false},
+		{`missing!="x"`, true},
+		{`component`, true},
+		{`!component`, false},
+		{`missing`, false},
+		{`data.level=="custom"`, true},
+		{`ok==false`, true},
+		{`msg=="health check failed"`, true},
+		{"msg=~`^health`", true},
+		{`msg!~"^health"`, false},
+		{`caller=="github.com/acme/app/db"`, true},
+		{`caller=~"/app$"`, false},
+		{`level>=warn && component=="db"`, true},
+		{`level>=error && component=="db"`, false},
+		{`level>=error || component=="db"`, true},
+		{`!(level>=error || component=="db")`, false},
+		{`(level>=error || component=="db") && status==503`, true},
+	}
+	for _, tc := range tests {
+		pred, err := filter.Parse(tc.expr)
+		require.NoError(t, err, tc.expr)
+		assert.Equal(t, tc.want, pred.Match(e), tc.expr)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		offset int
+	}{
+		{``, 0},
+		{`level`, 5},
+		{`level>=loud`, 5},
+		{`level=~"warn"`, 5},
+		{`component==`, 11},
+		{`component=="db`, 11},
+		{`(component`, 10},
+		{`component)`, 9},
+		{`component && `, 13},
+		{`msg>"a"`, 3},
+		{`status>"a"`, 6},
+		{`msg=~"("`, 3},
+		{`component # x`, 10},
+	}
+	for _, tc := range tests {
+		_, err := filter.Parse(tc.expr)
+		var syntaxErr *filter.SyntaxError
+		if assert.ErrorAs(t, err, &syntaxErr, tc.expr) {
+			assert.Equal(t, tc.offset, syntaxErr.Offset, "%s: %v", tc.expr, err)
+		}
+	}
+
+	assert.Panics(t, func() { filter.MustParse(`level>=`) })
+}
@@ -0,0 +1,316 @@
+// Package filter provides predicates on log entries, a hook wrapper that
+// only fires for matching entries and a router that delivers entries to
+// different hooks or writers.
+//
+// Predicates can be composed in Go:
+//
+//	match := filter.And(
+//		filter.LevelAtLeast(logrus.ErrorLevel),
+//		filter.FieldEquals("component", "payments"),
+//	)
+//	logger.AddHook(filter.New(alertHook, match))
+//
+// or parsed from an expression, for example one read from a configuration
+// file, see [Parse]:
+//
+//	match, err := filter.Parse(`level>=error && component=="payments"`)
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Predicate reports whether an entry matches. A nil Predicate matches every
+// entry.
+type Predicate func(entry *logrus.Entry) bool
+
+// Match reports whether entry matches p.
+func (p Predicate) Match(entry *logrus.Entry) bool {
+	return p == nil || p(entry)
+}
+
+// And returns a predicate that matches entries matching all of preds.
+func And(preds ...Predicate) Predicate {
+	return func(entry *logrus.Entry) bool {
+		for _, p := range preds {
+			if !p.Match(entry) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a predicate that matches entries matching any of preds.
+func Or(preds ...Predicate) Predicate {
+	return func(entry *logrus.Entry) bool {
+		for _, p := range preds {
+			if p.Match(entry) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a predicate that matches entries not matching p.
+func Not(p Predicate) Predicate {
+	return func(entry *logrus.Entry) bool {
+		return !p.Match(entry)
+	}
+}
+
+// LevelAtLeast matches entries whose level is at least as severe as level,
+// for example LevelAtLeast(logrus.WarnLevel) matches warnings and errors.
+func LevelAtLeast(level logrus.Level) Predicate {
+	return func(entry *logrus.Entry) bool {
+		return entry.Level.AtLeast(level)
+	}
+}
+
+// LevelAtMost matches entries whose level is at most as severe as level,
+// for example LevelAtMost(logrus.DebugLevel) matches debug and trace
+// entries.
+func LevelAtMost(level logrus.Level) Predicate {
+	return func(entry *logrus.Entry) bool {
+		return level.AtLeast(entry.Level)
+	}
+}
+
+// LevelIn matches entries with one of levels.
+func LevelIn(levels ...logrus.Level) Predicate {
+	return func(entry *logrus.Entry) bool {
+		return slices.Contains(levels, entry.Level)
+	}
+}
+
+// FieldExists matches entries that have a field key.
+func FieldExists(key string) Predicate {
+	return func(entry *logrus.Entry) bool {
+		_, ok := entry.Data[key]
+		return ok
+	}
+}
+
+// FieldEquals matches entries whose field key equals value. Values of the
+// same comparable type are compared with ==, numbers of different types by
+// their numeric value, and anything else by their string representation as
+// formatted by [fmt.Sprint], so that FieldEquals("status", "500") matches a
+// field holding the int 500.
+func FieldEquals(key string, value any) Predicate {
+	return func(entry *logrus.Entry) bool {
+		v, ok := entry.Data[key]
+		return ok && equal(v, value)
+	}
+}
+
+// FieldMatches matches entries whose field key, formatted by [fmt.Sprint],
+// matches re.
+func FieldMatches(key string, re *regexp.Regexp) Predicate {
+	return func(entry *logrus.Entry) bool {
+		v, ok := entry.Data[key]
+		return ok && re.MatchString(fmt.Sprint(v))
+	}
+}
+
+// MessageMatches matches entries whose message matches re.
+func MessageMatches(re *regexp.Regexp) Predicate {
+	return func(entry *logrus.Entry) bool {
+		return re.MatchString(entry.Message)
+	}
+}
+
+// MessageContains matches entries whose message contains substr.
+func MessageContains(substr string) Predicate {
+	return func(entry *logrus.Entry) bool {
+		return strings.Contains(entry.Message, substr)
+	}
+}
+
+// CallerPackage matches entries logged from the package with the import
+// path pkg. It requires caller reporting, see [logrus.Logger.SetReportCaller];
+// entries without a caller never match.
+func CallerPackage(pkg string) Predicate {
+	return func(entry *logrus.Entry) bool {
+		return entry.Caller != nil && callerPackage(entry.Caller.Function) == pkg
+	}
+}
+
+// callerPackage returns the import path of the package of a fully
+// qualified function name such as "github.com/x/y.(*T).Method".
+func callerPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// equal implements the comparison of FieldEquals.
+func equal(v, value any) bool {
+	if t := reflect.TypeOf(v); t != nil && t == reflect.TypeOf(value) && t.Comparable() {
+		return v == value
+	}
+	if a, ok := toFloat(v); ok {
+		if b, ok := toFloat(value); ok {
+			return a == b
+		}
+	}
+	return fmt.Sprint(v) == fmt.Sprint(value)
+}
+
+// toFloat converts a value of a numeric kind to a float64.
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(rv.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Hook fires a wrapped hook only for entries matching a predicate.
+type Hook struct {
+	hook  logrus.Hook
+	match Predicate
+}
+
+var _ logrus.PrioritizedHook = (*Hook)(nil)
+
+// New returns a Hook that fires hook for entries matching match.
+//
+// New panics if hook is nil.
+func New(hook logrus.Hook, match Predicate) *Hook {
+	if hook == nil {
+		panic("cannot create filter hook from nil hook")
+	}
+	return &Hook{hook: hook, match: match}
+}
+
+// Levels returns the levels of the wrapped hook.
+func (h *Hook) Levels() []logrus.Level {
+	return h.hook.Levels()
+}
+
+// Priority returns the priority of the wrapped hook, so that wrapping a
+// hook does not change the order in which it fires.
+func (h *Hook) Priority() int {
+	if p, ok := h.hook.(logrus.PrioritizedHook); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// Fire fires the wrapped hook if entry matches.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	if !h.match.Match(entry) {
+		return nil
+	}
+	return h.hook.Fire(entry)
+}
+
+// Route is a destination of a [Router].
+type Route struct {
+	// Match selects the entries delivered to the route. If nil, every
+	// entry is delivered.
+	Match Predicate
+
+	// Hook is fired for matching entries of its levels.
+	Hook logrus.Hook
+
+	// Writer receives matching entries, formatted by Formatter. If
+	// Formatter is nil, the formatter of the entry's logger is used.
+	Writer    io.Writer
+	Formatter logrus.Formatter
+
+	// Continue makes the router try the following routes after this one
+	// matched. By default, an entry is delivered to the first matching
+	// route only.
+	Continue bool
+}
+
+// Router is a hook that delivers entries to the hooks and writers of the
+// first route that matches, or of every matching route up to one without
+// Continue.
+type Router struct {
+	routes []Route
+	// mu serializes writes to the route writers.
+	mu sync.Mutex
+}
+
+var _ logrus.Hook = (*Router)(nil)
+
+// NewRouter returns a Router with routes, which are tried in order.
+func NewRouter(routes ...Route) *Router {
+	return &Router{routes: slices.Clone(routes)}
+}
+
+// Levels returns all levels.
+func (r *Router) Levels() []logrus.Level {
+	return logrus.Levels()
+}
+
+// Fire delivers entry to the matching routes. The errors of all routes
+// are joined.
+func (r *Router) Fire(entry *logrus.Entry) error {
+	var errs []error
+	for i := range r.routes {
+		route := &r.routes[i]
+		if !route.Match.Match(entry) {
+			continue
+		}
+		if err := r.deliver(route, entry); err != nil {
+			errs = append(errs, err)
+		}
+		if !route.Continue {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Router) deliver(route *Route, entry *logrus.Entry) error {
+	var errs []error
+	if route.Hook != nil && slices.Contains(route.Hook.Levels(), entry.Level) {
+		if err := route.Hook.Fire(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if route.Writer != nil {
+		var (
+			line []byte
+			err  error
+		)
+		if route.Formatter != nil {
+			line, err = route.Formatter.Format(entry)
+		} else {
+			line, err = entry.Bytes()
+		}
+		if err == nil {
+			r.mu.Lock()
+			_, err = route.Writer.Write(line)
+			r.mu.Unlock()
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
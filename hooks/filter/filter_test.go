@@ -0,0 +1,137 @@
+package filter_test
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/filter"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func entry(level logrus.Level, msg string, fields logrus.Fields) *logrus.Entry {
+	e := logrus.NewEntry(logrus.New()).WithFields(fields)
+	e.Level = level
+	e.Message = msg
+	return e
+}
+
+func TestPredicates(t *testing.T) {
+	e := entry(logrus.WarnLevel, "connection timeout", logrus.Fields{
+		"component": "db",
+		"status":    500,
+		"latency":   1.5,
+	})
+	e.Caller = &runtime.Frame{Function: "github.com/acme/app/db.(*Pool).Get"}
+
+	tests := []struct {
+		name string
+		pred filter.Predicate
+		want bool
+	}{
+		{"nil", nil, true},
+		{"level at least warn", filter.LevelAtLeast(logrus.WarnLevel), true},
+		{"level at least error", filter.LevelAtLeast(logrus.ErrorLevel), false},
+		{"level at most info", filter.LevelAtMost(logrus.InfoLevel), false},
+		{"level in", filter.LevelIn(logrus.ErrorLevel, logrus.WarnLevel), true},
+		{"field exists", filter.FieldExists("component"), true},
+		{"field missing", filter.FieldExists("user"), false},
+		{"field equals", filter.FieldEquals("component", "db"), true},
+		{"field equals number as string", filter.FieldEquals("status", "500"), true},
+		{"field equals other number type", filter.FieldEquals("status", 500.0), true},
+		{"field not equal", filter.FieldEquals("status", 404), false},
+		{"field matches", filter.FieldMatches("status", regexp.MustCompile(`^5\d\d$`)), true},
+		{"message matches", filter.MessageMatches(regexp.MustCompile(`time(out)?`)), true},
+		{"message contains", filter.MessageContains("refused"), false},
+		{"caller package", filter.CallerPackage("github.com/acme/app/db"), true},
+		{"other caller package", filter.CallerPackage("github.com/acme/app"), false},
+		{"and", filter.And(filter.FieldExists("component"), filter.LevelIn(logrus.WarnLevel)), true},
+		{"or", filter.Or(filter.FieldExists("user"), filter.LevelIn(logrus.InfoLevel)), false},
+		{"not", filter.Not(filter.FieldExists("user")), true},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, tc.pred.Match(e), tc.name)
+	}
+
+	assert.False(t, filter.CallerPackage("github.com/acme/app/db").Match(entry(logrus.InfoLevel, "", nil)))
+}
+
+func TestHook(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	inner := &test.Hook{}
+	logger.AddHook(filter.New(inner, filter.FieldEquals("component", "payments")))
+
+	logger.WithField("component", "payments").Error("charged twice")
+	logger.WithField("component", "search").Error("index stale")
+	logger.Error("no component")
+
+	require.Len(t, inner.AllEntries(), 1)
+	assert.Equal(t, "charged twice", inner.LastEntry().Message)
+}
+
+type priorityHook struct {
+	test.Hook
+}
+
+func (*priorityHook) Priority() int { return 42 }
+
+func TestHookPriority(t *testing.T) {
+	assert.Equal(t, 42, filter.New(&priorityHook{}, nil).Priority())
+	assert.Equal(t, 0, filter.New(&test.Hook{}, nil).Priority())
+}
+
+type errorHook struct{ err error }
+
+func (h errorHook) Levels() []logrus.Level   { return logrus.AllLevels }
+func (h errorHook) Fire(*logrus.Entry) error { return h.err }
+
+func TestRouter(t *testing.T) {
+	var dbOut, errOut, allOut bytes.Buffer
+	formatter := &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true}
+	errorsOnly := &test.Hook{}
+
+	router := filter.NewRouter(
+		filter.Route{
+			Match:     filter.MustParse(`component=="db"`),
+			Writer:    &dbOut,
+			Formatter: formatter,
+		},
+		filter.Route{
+			Match:    filter.LevelAtLeast(logrus.ErrorLevel),
+			Hook:     errorsOnly,
+			Writer:   &errOut,
+			Continue: true,
+		},
+		filter.Route{Writer: &allOut},
+	)
+
+	logger, _ := test.NewNullLogger()
+	logger.SetFormatter(formatter)
+	logger.AddHook(router)
+
+	logger.WithField("component", "db").Error("db error")
+	logger.Error("other error")
+	logger.Info("other info")
+
+	assert.Equal(t, "level=error msg=\"db error\" component=db\n", dbOut.String())
+	assert.Equal(t, "level=error msg=\"other error\"\n", errOut.String())
+	assert.Equal(t, "level=error msg=\"other error\"\nlevel=info msg=\"other info\"\n", allOut.String())
+	require.Len(t, errorsOnly.AllEntries(), 1)
+}
+
+func TestRouterErrors(t *testing.T) {
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	router := filter.NewRouter(
+		filter.Route{Hook: errorHook{errFirst}, Continue: true},
+		filter.Route{Hook: errorHook{errSecond}},
+	)
+	err := router.Fire(entry(logrus.InfoLevel, "", nil))
+	assert.ErrorIs(t, err, errFirst)
+	assert.ErrorIs(t, err, errSecond)
+}
@@ -0,0 +1,324 @@
+// Package fluent provides a hook sending entries to Fluentd or Fluent Bit
+// using the Forward protocol, see
+// https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1.
+//
+// Each entry is sent as an event whose record holds the entry's fields,
+// message and level, tagged with the configured prefix and the level, such
+// as "myapp.error":
+//
+//	hook := fluent.New("tcp", "localhost:24224", &fluent.Options{TagPrefix: "myapp"})
+//	defer hook.Close()
+//	logger.AddHook(hook)
+//
+// A [Hook] sends every entry as it is fired. Wrap it with [async.New] to
+// send entries in the background; the async hook then passes them in
+// batches, which are sent in PackedForward mode:
+//
+//	hook := async.New(fluent.New("tcp", "localhost:24224", nil), nil)
+package fluent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/async"
+)
+
+const (
+	// DefaultTagPrefix is the tag prefix if Options.TagPrefix is empty.
+	DefaultTagPrefix = "logrus"
+	// DefaultTimeout is the dial, write and acknowledgement timeout if the
+	// corresponding option is 0.
+	DefaultTimeout = 5 * time.Second
+)
+
+// Options are options for a [Hook].
+// A zero Options consists entirely of default values.
+type Options struct {
+	// TagPrefix is the prefix of the event tags, which are the prefix and
+	// the entry's level separated by a dot. If empty, DefaultTagPrefix is
+	// used.
+	TagPrefix string
+
+	// FieldMap allows customizing the record keys of the message, level,
+	// caller function and file, like [logrus.JSONFormatter.FieldMap].
+	FieldMap logrus.FieldMap
+
+	// RequireAck makes the hook send a chunk ID with every message and wait
+	// for the server to acknowledge it, so that an error is returned if
+	// the events might not have been received.
+	RequireAck bool
+
+	// Dial, if set, replaces the dialing of network and address.
+	Dial func(ctx context.Context) (net.Conn, error)
+
+	// DialTimeout, WriteTimeout and AckTimeout limit connecting, sending a
+	// message and waiting for its acknowledgement. If 0, DefaultTimeout is
+	// used.
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	AckTimeout   time.Duration
+}
+
+// Hook sends entries to a Fluentd server.
+type Hook struct {
+	opts Options
+	dial func(ctx context.Context) (net.Conn, error)
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+var _ async.BatchHook = (*Hook)(nil)
+
+func init() {
+	logrus.RegisterHookFactory("fluent", newFromOptions)
+}
+
+// newFromOptions creates a Hook from the options of a [logrus.HookConfig]
+// of type "fluent", which are the network and address passed to New and
+// the fields of Options:
+//
+//	{"type": "fluent", "options": {"network": "tcp", "address": "localhost:24224", "TagPrefix": "app"}}
+//
+// The network defaults to "tcp".
+func newFromOptions(options map[string]any) (logrus.Hook, error) {
+	var opts struct {
+		Network string `json:"network"`
+		Address string `json:"address"`
+		Options
+	}
+	if err := logrus.DecodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	if opts.Address == "" {
+		return nil, errors.New("fluent: address is required")
+	}
+	if opts.Network == "" {
+		opts.Network = "tcp"
+	}
+	return New(opts.Network, opts.Address, &opts.Options), nil
+}
+
+// New returns a Hook sending to address on network, usually "tcp" or
+// "unix". The connection is established when the first entry is fired,
+// and re-established after it fails. If opts is nil, the default options
+// are used.
+func New(network, address string, opts *Options) *Hook {
+	if opts == nil {
+		opts = &Options{}
+	}
+	h := &Hook{opts: *opts}
+	if h.opts.TagPrefix == "" {
+		h.opts.TagPrefix = DefaultTagPrefix
+	}
+	if h.opts.DialTimeout <= 0 {
+		h.opts.DialTimeout = DefaultTimeout
+	}
+	if h.opts.WriteTimeout <= 0 {
+		h.opts.WriteTimeout = DefaultTimeout
+	}
+	if h.opts.AckTimeout <= 0 {
+		h.opts.AckTimeout = DefaultTimeout
+	}
+	h.dial = h.opts.Dial
+	if h.dial == nil {
+		dialer := &net.Dialer{Timeout: h.opts.DialTimeout}
+		h.dial = func(ctx context.Context) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+	return h
+}
+
+// Levels returns all levels.
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.Levels()
+}
+
+// Tag returns the tag of entry.
+func (h *Hook) Tag(entry *logrus.Entry) string {
+	return h.opts.TagPrefix + "." + entry.Level.String()
+}
+
+// Fire sends entry in Message mode: [tag, time, record].
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	var chunk string
+	e := &encoder{}
+	if h.opts.RequireAck {
+		chunk = newChunkID()
+		e.arrayHeader(4)
+	} else {
+		e.arrayHeader(3)
+	}
+	e.string(h.Tag(entry))
+	e.eventTime(entry.Time)
+	e.stringMap(h.record(entry))
+	if chunk != "" {
+		e.stringMap(map[string]any{"chunk": chunk})
+	}
+	return h.send(e.buf, chunk)
+}
+
+// FireBatch sends entries in PackedForward mode, one message per tag:
+// [tag, entries, option], where entries is a binary string of the
+// concatenated [time, record] events. A failed message does not prevent
+// sending the others; if some messages could not be sent, FireBatch
+// returns an [*async.BatchError] holding their entries.
+func (h *Hook) FireBatch(entries []*logrus.Entry) error {
+	var tags []string
+	events := make(map[string]*encoder)
+	tagged := make(map[string][]*logrus.Entry)
+	for _, entry := range entries {
+		tag := h.Tag(entry)
+		e, ok := events[tag]
+		if !ok {
+			e = &encoder{}
+			events[tag] = e
+			tags = append(tags, tag)
+		}
+		e.arrayHeader(2)
+		e.eventTime(entry.Time)
+		e.stringMap(h.record(entry))
+		tagged[tag] = append(tagged[tag], entry)
+	}
+
+	var batchErr async.BatchError
+	for _, tag := range tags {
+		option := map[string]any{"size": len(tagged[tag])}
+		var chunk string
+		if h.opts.RequireAck {
+			chunk = newChunkID()
+			option["chunk"] = chunk
+		}
+		e := &encoder{}
+		e.arrayHeader(3)
+		e.string(tag)
+		e.bin(events[tag].buf)
+		e.stringMap(option)
+		if err := h.send(e.buf, chunk); err != nil {
+			batchErr.Err = errors.Join(batchErr.Err, err)
+			batchErr.Entries = append(batchErr.Entries, tagged[tag]...)
+		}
+	}
+	if len(batchErr.Entries) == 0 {
+		return nil
+	}
+	return &batchErr
+}
+
+// record returns the record of entry: its fields, message, level and
+// caller. Fields clashing with those keys are prefixed with "fields.", like
+// the formatters do.
+func (h *Hook) record(entry *logrus.Entry) map[string]any {
+	record := make(map[string]any, len(entry.Data)+4)
+	for k, v := range entry.Data {
+		record[k] = v
+	}
+	set := func(key string, value any) {
+		key = h.resolve(key)
+		if v, ok := record[key]; ok {
+			record["fields."+key] = v
+		}
+		record[key] = value
+	}
+	set(logrus.FieldKeyMsg, entry.Message)
+	set(logrus.FieldKeyLevel, entry.Level.String())
+	if entry.HasCaller() {
+		set(logrus.FieldKeyFunc, entry.Caller.Function)
+		set(logrus.FieldKeyFile, fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line))
+	}
+	return record
+}
+
+// resolve returns the record key of a default field.
+func (h *Hook) resolve(key string) string {
+	for k, v := range h.opts.FieldMap {
+		if string(k) == key {
+			return v
+		}
+	}
+	return key
+}
+
+// newChunkID returns a random chunk ID.
+func newChunkID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// send writes msg, waiting for the acknowledgement of chunk if it is not
+// empty. If an existing connection fails, it is closed and the message is
+// sent again once over a new connection, so that a connection broken by a
+// restart of the server does not lose entries.
+func (h *Hook) send(msg []byte, chunk string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	reused := h.conn != nil
+	err := h.trySend(msg, chunk)
+	if err != nil && reused {
+		err = h.trySend(msg, chunk)
+	}
+	return err
+}
+
+func (h *Hook) trySend(msg []byte, chunk string) error {
+	if h.conn == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), h.opts.DialTimeout)
+		conn, err := h.dial(ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+		h.conn = conn
+	}
+
+	err := h.conn.SetWriteDeadline(time.Now().Add(h.opts.WriteTimeout))
+	if err == nil {
+		_, err = h.conn.Write(msg)
+	}
+	if err == nil && chunk != "" {
+		err = h.readAck(chunk)
+	}
+	if err != nil {
+		_ = h.conn.Close()
+		h.conn = nil
+	}
+	return err
+}
+
+func (h *Hook) readAck(chunk string) error {
+	if err := h.conn.SetReadDeadline(time.Now().Add(h.opts.AckTimeout)); err != nil {
+		return err
+	}
+	v, err := (&decoder{r: h.conn}).decode()
+	if err != nil {
+		return fmt.Errorf("fluent: reading ack: %w", err)
+	}
+	resp, ok := v.(map[string]any)
+	if !ok || resp["ack"] != chunk {
+		return fmt.Errorf("fluent: unexpected ack %v for chunk %s", v, chunk)
+	}
+	return nil
+}
+
+// Close closes the connection. The hook may still be used afterwards; it
+// then connects again.
+func (h *Hook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
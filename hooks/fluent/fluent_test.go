@@ -0,0 +1,362 @@
+package fluent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/async"
+)
+
+// event is an event received by the test server.
+type event struct {
+	tag    string
+	time   time.Time
+	record map[string]any
+	option map[string]any
+}
+
+// server is a Forward protocol server decoding the received messages.
+type server struct {
+	ln   net.Listener
+	ack  bool
+	mu   sync.Mutex
+	evts []event
+	msgs int
+}
+
+func listen(t *testing.T, network, address string, ack bool) *server {
+	t.Helper()
+	ln, err := net.Listen(network, address)
+	require.NoError(t, err)
+	s := &server{ln: ln, ack: ack}
+	go s.serve()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	d := &decoder{r: bufio.NewReader(conn)}
+	for {
+		v, err := d.decode()
+		if err != nil {
+			return
+		}
+		msg := v.([]any)
+		tag := msg[0].(string)
+		var events []event
+		var option map[string]any
+		switch entries := msg[1].(type) {
+		case []byte:
+			// PackedForward
+			option, _ = msg[2].(map[string]any)
+			pd := &decoder{r: bytes.NewReader(entries)}
+			for {
+				ev, err := pd.decode()
+				if err != nil {
+					break
+				}
+				pair := ev.([]any)
+				events = append(events, event{tag: tag, time: pair[0].(time.Time), record: pair[1].(map[string]any), option: option})
+			}
+		case time.Time:
+			// Message
+			if len(msg) > 3 {
+				option, _ = msg[3].(map[string]any)
+			}
+			events = append(events, event{tag: tag, time: entries, record: msg[2].(map[string]any), option: option})
+		}
+
+		s.mu.Lock()
+		s.evts = append(s.evts, events...)
+		s.msgs++
+		s.mu.Unlock()
+
+		if s.ack && option != nil {
+			e := &encoder{}
+			e.stringMap(map[string]any{"ack": option["chunk"]})
+			if _, err := conn.Write(e.buf); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *server) events(t *testing.T, n int) []event {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.evts) >= n
+	}, 5*time.Second, 5*time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]event(nil), s.evts...)
+}
+
+func newEntry(level logrus.Level, msg string, fields logrus.Fields) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.New()).WithFields(fields)
+	entry.Level = level
+	entry.Message = msg
+	entry.Time = time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	return entry
+}
+
+func TestFire(t *testing.T) {
+	s := listen(t, "tcp", "127.0.0.1:0", false)
+	hook := New("tcp", s.ln.Addr().String(), &Options{TagPrefix: "app"})
+	defer hook.Close()
+
+	entry := newEntry(logrus.WarnLevel, "disk almost full", logrus.Fields{
+		"free":  int64(1024),
+		"ratio": 0.05,
+		"err":   errors.New("boom"),
+		"msg":   "clash",
+	})
+	require.NoError(t, hook.Fire(entry))
+
+	ev := s.events(t, 1)[0]
+	assert.Equal(t, "app.warning", ev.tag)
+	assert.True(t, entry.Time.Equal(ev.time), "event time keeps nanoseconds")
+	assert.Equal(t, map[string]any{
+		"msg":        "disk almost full",
+		"level":      "warning",
+		"free":       int64(1024),
+		"ratio":      0.05,
+		"err":        "boom",
+		"fields.msg": "clash",
+	}, ev.record)
+	assert.Nil(t, ev.option)
+}
+
+func TestConfigHook(t *testing.T) {
+	s := listen(t, "tcp", "127.0.0.1:0", false)
+	var cfg logrus.Config
+	require.NoError(t, cfg.LoadJSON([]byte(`{"hooks": [{"type": "fluent", "options": {
+		"address": "`+s.ln.Addr().String()+`", "TagPrefix": "app"}}]}`)))
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	require.NoError(t, cfg.Apply(logger))
+	defer logger.Close()
+
+	logger.Error("configured")
+	ev := s.events(t, 1)[0]
+	assert.Equal(t, "app.error", ev.tag)
+	assert.Equal(t, "configured", ev.record["msg"])
+
+	cfg.Hooks[0].Options = map[string]any{"TagPrefix": "app"}
+	assert.ErrorContains(t, cfg.Apply(logrus.New()), "address is required")
+}
+
+func TestFireBatch(t *testing.T) {
+	s := listen(t, "tcp", "127.0.0.1:0", false)
+	hook := New("tcp", s.ln.Addr().String(), &Options{
+		FieldMap: logrus.FieldMap{logrus.FieldKeyMsg: "message"},
+	})
+	defer hook.Close()
+
+	require.NoError(t, hook.FireBatch([]*logrus.Entry{
+		newEntry(logrus.InfoLevel, "one", nil),
+		newEntry(logrus.ErrorLevel, "two", nil),
+		newEntry(logrus.InfoLevel, "three", nil),
+	}))
+
+	events := s.events(t, 3)
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.tag+":"+ev.record["message"].(string))
+	}
+	assert.Equal(t, []string{"logrus.info:one", "logrus.info:three", "logrus.error:two"}, got)
+	assert.Equal(t, int64(2), events[0].option["size"])
+	s.mu.Lock()
+	assert.Equal(t, 2, s.msgs)
+	s.mu.Unlock()
+}
+
+// failingConn fails its second write.
+type failingConn struct {
+	net.Conn
+	writes int
+}
+
+func (c *failingConn) Write(b []byte) (int, error) {
+	c.writes++
+	if c.writes == 2 {
+		return 0, errors.New("write failed")
+	}
+	return c.Conn.Write(b)
+}
+
+func TestFireBatchFailedTag(t *testing.T) {
+	s := listen(t, "tcp", "127.0.0.1:0", false)
+	dials := 0
+	hook := New("tcp", s.ln.Addr().String(), &Options{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			dials++
+			if dials == 2 {
+				return nil, errors.New("dial failed")
+			}
+			conn, err := net.Dial("tcp", s.ln.Addr().String())
+			return &failingConn{Conn: conn}, err
+		},
+	})
+	defer hook.Close()
+
+	failed := newEntry(logrus.ErrorLevel, "two", nil)
+	err := hook.FireBatch([]*logrus.Entry{
+		newEntry(logrus.InfoLevel, "one", nil),
+		failed,
+		newEntry(logrus.WarnLevel, "three", nil),
+	})
+	var batchErr *async.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []*logrus.Entry{failed}, batchErr.Entries, "only the entries of the failed tag")
+	assert.ErrorContains(t, err, "dial failed")
+
+	// The messages are sent over two connections, so their order on the
+	// server is not defined.
+	var tags []string
+	for _, ev := range s.events(t, 2) {
+		tags = append(tags, ev.tag)
+	}
+	assert.ElementsMatch(t, []string{"logrus.info", "logrus.warning"}, tags)
+}
+
+func TestRequireAck(t *testing.T) {
+	s := listen(t, "tcp", "127.0.0.1:0", true)
+	hook := New("tcp", s.ln.Addr().String(), &Options{RequireAck: true})
+	defer hook.Close()
+
+	require.NoError(t, hook.Fire(newEntry(logrus.InfoLevel, "single", nil)))
+	require.NoError(t, hook.FireBatch([]*logrus.Entry{newEntry(logrus.InfoLevel, "batch", nil)}))
+	events := s.events(t, 2)
+	assert.NotEmpty(t, events[0].option["chunk"])
+	assert.NotEmpty(t, events[1].option["chunk"])
+
+	// Without acknowledgements, Fire times out.
+	s2 := listen(t, "tcp", "127.0.0.1:0", false)
+	hook2 := New("tcp", s2.ln.Addr().String(), &Options{RequireAck: true, AckTimeout: 20 * time.Millisecond})
+	defer hook2.Close()
+	assert.Error(t, hook2.Fire(newEntry(logrus.InfoLevel, "lost", nil)))
+}
+
+func TestReconnect(t *testing.T) {
+	s := listen(t, "tcp", "127.0.0.1:0", true)
+	addr := s.ln.Addr().String()
+	hook := New("tcp", addr, &Options{RequireAck: true})
+	defer hook.Close()
+	require.NoError(t, hook.Fire(newEntry(logrus.InfoLevel, "before", nil)))
+
+	// Break the connection; the next Fire reconnects.
+	hook.mu.Lock()
+	_ = hook.conn.Close()
+	hook.mu.Unlock()
+	require.NoError(t, hook.Fire(newEntry(logrus.InfoLevel, "after", nil)))
+	assert.Len(t, s.events(t, 2), 2)
+}
+
+func TestUnix(t *testing.T) {
+	dir, err := os.MkdirTemp("", "fluent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := listen(t, "unix", filepath.Join(dir, "fluent.sock"), false)
+	hook := New("unix", s.ln.Addr().String(), nil)
+	defer hook.Close()
+	require.NoError(t, hook.Fire(newEntry(logrus.DebugLevel, "hello", nil)))
+	assert.Equal(t, "logrus.debug", s.events(t, 1)[0].tag)
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	type point struct{ X, Y int }
+	values := []any{
+		nil, true, false,
+		int64(0), int64(127), int64(128), int64(-1), int64(-33), int64(-200), int64(70000), int64(math.MinInt64),
+		uint64(math.MaxUint64),
+		1.5,
+		"", "short", string(bytes.Repeat([]byte("x"), 300)),
+		[]byte{1, 2, 3},
+		[]any{int64(1), "two"},
+		map[string]any{"a": int64(1), "b": []any{"c"}},
+	}
+	for _, v := range values {
+		e := &encoder{}
+		e.value(v)
+		got, err := (&decoder{r: bytes.NewReader(e.buf)}).decode()
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+
+	e := &encoder{}
+	e.value(point{1, 2})
+	e.value([]int{1, 2})
+	e.value(map[string]string{"k": "v"})
+	d := &decoder{r: bytes.NewReader(e.buf)}
+	got, err := d.decode()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"X": 1.0, "Y": 2.0}, got)
+	got, err = d.decode()
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(1), int64(2)}, got)
+	got, err = d.decode()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"k": "v"}, got)
+}
+
+type valueError struct{ msg string }
+
+func (e valueError) Error() string { return e.msg }
+
+type panickingStringer struct{}
+
+func (panickingStringer) String() string { panic("boom") }
+
+func TestMsgpackMethodPanics(t *testing.T) {
+	var nilErr *valueError
+	e := &encoder{}
+	e.value(error(nilErr))
+	e.value(panickingStringer{})
+	d := &decoder{r: bytes.NewReader(e.buf)}
+	got, err := d.decode()
+	require.NoError(t, err)
+	assert.Equal(t, "<nil>", got)
+	got, err = d.decode()
+	require.NoError(t, err)
+	assert.Equal(t, "%!v(PANIC=String method: boom)", got)
+}
+
+func TestMsgpackDecodeLimits(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"string": {0xdb, 0xff, 0xff, 0xff, 0xff},
+		"array":  {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"map":    {0xdf, 0xff, 0xff, 0xff, 0xff},
+		"depth":  bytes.Repeat([]byte{0x91}, 1000),
+	} {
+		_, err := (&decoder{r: bytes.NewReader(data)}).decode()
+		assert.ErrorIs(t, err, errInvalid, name)
+	}
+}
@@ -0,0 +1,501 @@
+package fluent
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// This file implements the subset of MessagePack used by the Forward
+// protocol, see https://github.com/msgpack/msgpack/blob/master/spec.md.
+
+// eventTimeExt is the extension type of EventTime.
+const eventTimeExt = 0
+
+// eventTime is a timestamp with nanosecond precision, encoded as the
+// EventTime extension type.
+type eventTime time.Time
+
+// encoder appends MessagePack to a buffer.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) nil() {
+	e.buf = append(e.buf, 0xc0)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf = append(e.buf, 0xc3)
+	} else {
+		e.buf = append(e.buf, 0xc2)
+	}
+}
+
+func (e *encoder) int(i int64) {
+	switch {
+	case i >= 0:
+		e.uint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd2), uint32(i))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd3), uint64(i))
+	}
+}
+
+func (e *encoder) uint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xce), uint32(u))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcf), u)
+	}
+}
+
+func (e *encoder) float(f float64) {
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcb), math.Float64bits(f))
+}
+
+func (e *encoder) string(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xda), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdb), uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bin(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xc5), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc6), uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) arrayHeader(n int) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xdc), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdd), uint32(n))
+	}
+}
+
+func (e *encoder) mapHeader(n int) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xde), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdf), uint32(n))
+	}
+}
+
+// eventTime encodes t as EventTime: fixext 8 with big-endian seconds and
+// nanoseconds.
+func (e *encoder) eventTime(t time.Time) {
+	e.buf = append(e.buf, 0xd7, eventTimeExt)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Unix()))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Nanosecond()))
+}
+
+// value encodes v. Errors, [encoding.TextMarshaler] and [fmt.Stringer]
+// values are encoded as strings; other types that have no MessagePack
+// equivalent, such as structs, are encoded like their JSON representation.
+func (e *encoder) value(v any) {
+	switch v := v.(type) {
+	case nil:
+		e.nil()
+	case bool:
+		e.bool(v)
+	case string:
+		e.string(v)
+	case []byte:
+		e.bin(v)
+	case int:
+		e.int(int64(v))
+	case int8:
+		e.int(int64(v))
+	case int16:
+		e.int(int64(v))
+	case int32:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case uint:
+		e.uint(uint64(v))
+	case uint8:
+		e.uint(uint64(v))
+	case uint16:
+		e.uint(uint64(v))
+	case uint32:
+		e.uint(uint64(v))
+	case uint64:
+		e.uint(v)
+	case float32:
+		e.float(float64(v))
+	case float64:
+		e.float(v)
+	case eventTime:
+		e.eventTime(time.Time(v))
+	case time.Time:
+		e.string(v.Format(time.RFC3339Nano))
+	case error:
+		e.method(v, "Error", v.Error)
+	case encoding.TextMarshaler:
+		e.method(v, "MarshalText", func() string {
+			text, err := v.MarshalText()
+			if err != nil {
+				return err.Error()
+			}
+			return string(text)
+		})
+	case fmt.Stringer:
+		e.method(v, "String", v.String)
+	case map[string]any:
+		e.stringMap(v)
+	case []any:
+		e.arrayHeader(len(v))
+		for _, x := range v {
+			e.value(x)
+		}
+	default:
+		e.reflect(v)
+	}
+}
+
+// method encodes the result of calling a method of v. Like TextFormatter,
+// it encodes "<nil>" if the method panics for a nil pointer, such as a nil
+// *MyError stored in an error, and a description of the panic otherwise.
+func (e *encoder) method(v any, name string, call func() string) {
+	defer func() {
+		if r := recover(); r != nil {
+			rv := reflect.ValueOf(v)
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				e.string("<nil>")
+			} else {
+				e.string(fmt.Sprintf("%%!v(PANIC=%s method: %v)", name, r))
+			}
+		}
+	}()
+	e.string(call())
+}
+
+func (e *encoder) stringMap(m map[string]any) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	e.mapHeader(len(keys))
+	for _, k := range keys {
+		e.string(k)
+		e.value(m[k])
+	}
+}
+
+// reflect encodes values of other types: slices and maps element by
+// element, and anything else through its JSON representation.
+func (e *encoder) reflect(v any) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			e.nil()
+			return
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			e.nil()
+			return
+		}
+		e.arrayHeader(rv.Len())
+		for i := range rv.Len() {
+			e.value(rv.Index(i).Interface())
+		}
+		return
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			m := make(map[string]any, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				m[iter.Key().String()] = iter.Value().Interface()
+			}
+			e.stringMap(m)
+			return
+		}
+	case reflect.String:
+		e.string(rv.String())
+		return
+	case reflect.Bool:
+		e.bool(rv.Bool())
+		return
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(rv.Int())
+		return
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.uint(rv.Uint())
+		return
+	case reflect.Float32, reflect.Float64:
+		e.float(rv.Float())
+		return
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		e.string(fmt.Sprint(v))
+		return
+	}
+	var decoded any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		e.string(string(b))
+		return
+	}
+	e.value(decoded)
+}
+
+var errInvalid = errors.New("fluent: invalid MessagePack")
+
+const (
+	// maxDecodeLen bounds the length of the strings, binaries, arrays and
+	// maps a decoder reads, so that a corrupt length cannot make it
+	// allocate gigabytes.
+	maxDecodeLen = 1 << 20
+	// maxDecodeDepth bounds the nesting of arrays and maps.
+	maxDecodeDepth = 64
+)
+
+// decoder reads MessagePack values. It is used to read acknowledgements.
+type decoder struct {
+	r     io.Reader
+	depth int
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n > maxDecodeLen {
+		return nil, errInvalid
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	return b, err
+}
+
+func (d *decoder) uintN(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// decode reads the next value. Maps are returned as map[string]any if all
+// keys are strings, and as map[any]any otherwise; arrays as []any, EventTime
+// as time.Time, and other extension types as []byte.
+func (d *decoder) decode() (any, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.decodeMap(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.decodeArray(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		s, err := d.read(int(c & 0x1f))
+		return string(s), err
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uintN(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.read(int(n))
+	case 0xca:
+		u, err := d.uintN(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uintN(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uintN(1 << (c - 0xcc))
+		if u > math.MaxInt64 {
+			return u, err
+		}
+		return int64(u), err
+	case 0xd0:
+		u, err := d.uintN(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.uintN(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.uintN(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.uintN(8)
+		return int64(u), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (c - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uintN(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(int(n))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uintN(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.read(int(n))
+		return string(s), err
+	case 0xdc, 0xdd:
+		n, err := d.uintN(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uintN(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n))
+	}
+	return nil, errInvalid
+}
+
+// enter checks the length of an array or map of n elements and its depth.
+// It returns a function to call after decoding the elements.
+func (d *decoder) enter(n int) (func(), error) {
+	if n > maxDecodeLen || d.depth >= maxDecodeDepth {
+		return nil, errInvalid
+	}
+	d.depth++
+	return func() { d.depth-- }, nil
+}
+
+func (d *decoder) decodeArray(n int) ([]any, error) {
+	leave, err := d.enter(n)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	a := make([]any, n)
+	for i := range a {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (d *decoder) decodeMap(n int) (any, error) {
+	leave, err := d.enter(n)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	m := make(map[string]any, n)
+	var other map[any]any
+	for range n {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := k.(string); ok && other == nil {
+			m[s] = v
+			continue
+		}
+		if other == nil {
+			other = make(map[any]any, n)
+			for k, v := range m {
+				other[k] = v
+			}
+		}
+		if k == nil || !reflect.TypeOf(k).Comparable() {
+			return nil, errInvalid
+		}
+		other[k] = v
+	}
+	if other != nil {
+		return other, nil
+	}
+	return m, nil
+}
+
+func (d *decoder) decodeExt(n int) (any, error) {
+	typ, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	if typ[0] == eventTimeExt && n == 8 {
+		sec := binary.BigEndian.Uint32(data)
+		nsec := binary.BigEndian.Uint32(data[4:])
+		return time.Unix(int64(sec), int64(nsec)), nil
+	}
+	return data, nil
+}
@@ -0,0 +1,369 @@
+package httpbatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/async"
+)
+
+// Encoder encodes a batch of entries as the body of a request.
+type Encoder interface {
+	// ContentType returns the content type of the encoded batches.
+	ContentType() string
+	// Encode encodes entries. If some entries cannot be encoded, Encode
+	// may skip them and return the encoding of the others together with
+	// an [*async.BatchError] holding the skipped entries.
+	Encode(entries []*logrus.Entry) ([]byte, error)
+}
+
+// ResponseChecker is implemented by encoders for endpoints that report
+// errors in the body of a successful response, such as the Elasticsearch
+// bulk API. CheckResponse is called with the body of every response with a
+// 2xx status code and the entries sent in the request. If only some of the
+// entries were rejected, it returns an [*async.BatchError] holding them.
+type ResponseChecker interface {
+	CheckResponse(body []byte, entries []*logrus.Entry) error
+}
+
+// defaultFormatter formats log lines and documents if an encoder has no
+// Formatter.
+var defaultFormatter = &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+
+// format formats entry with formatter, or the default formatter if it is
+// nil, without the trailing newline.
+func format(formatter logrus.Formatter, entry *logrus.Entry) ([]byte, error) {
+	if formatter == nil {
+		formatter = defaultFormatter
+	}
+	b, err := formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b, []byte("\n")), nil
+}
+
+// skip adds entry, which could not be encoded because of err, to skipped.
+func skip(skipped *async.BatchError, entry *logrus.Entry, err error) {
+	skipped.Err = errors.Join(skipped.Err, err)
+	skipped.Entries = append(skipped.Entries, entry)
+}
+
+// skippedErr returns skipped if it holds entries, and otherwise nil.
+func skippedErr(skipped *async.BatchError) error {
+	if len(skipped.Entries) == 0 {
+		return nil
+	}
+	return skipped
+}
+
+// errorString returns the message of err. Like TextFormatter, it returns
+// "<nil>" if Error panics for a nil pointer, such as a nil *MyError stored
+// in an error, and a description of the panic otherwise.
+func errorString(err error) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			if rv := reflect.ValueOf(err); rv.Kind() == reflect.Pointer && rv.IsNil() {
+				s = "<nil>"
+			} else {
+				s = fmt.Sprintf("%%!v(PANIC=Error method: %v)", r)
+			}
+		}
+	}()
+	return err.Error()
+}
+
+// LokiEncoder encodes entries for the Grafana Loki push API,
+// /loki/api/v1/push. Entries are grouped into streams by their labels.
+type LokiEncoder struct {
+	// Labels are the names of the fields used as stream labels, in
+	// addition to the "level" label holding the entry's level. Entries
+	// without such a field have no such label. Characters that are not
+	// valid in label names, such as dots, are replaced with underscores.
+	Labels []string
+
+	// StaticLabels are labels added to every stream, such as the name of
+	// the service. Their names are sanitized like those of Labels.
+	StaticLabels map[string]string
+
+	// Formatter formats the log lines. If nil, entries are formatted as
+	// JSON.
+	Formatter logrus.Formatter
+}
+
+var _ Encoder = (*LokiEncoder)(nil)
+
+// ContentType returns "application/json".
+func (e *LokiEncoder) ContentType() string {
+	return "application/json"
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Encode encodes entries as a push request.
+func (e *LokiEncoder) Encode(entries []*logrus.Entry) ([]byte, error) {
+	var (
+		streams []*lokiStream
+		skipped async.BatchError
+	)
+	byKey := make(map[string]*lokiStream)
+	for _, entry := range entries {
+		line, err := format(e.Formatter, entry)
+		if err != nil {
+			skip(&skipped, entry, err)
+			continue
+		}
+
+		labels := make(map[string]string, len(e.StaticLabels)+len(e.Labels)+1)
+		for k, v := range e.StaticLabels {
+			labels[labelName(k)] = v
+		}
+		for _, name := range e.Labels {
+			if v, ok := entry.Data[name]; ok {
+				labels[labelName(name)] = fmt.Sprint(v)
+			}
+		}
+		labels["level"] = entry.Level.String()
+
+		key := labelKey(labels)
+		stream, ok := byKey[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			byKey[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(entry.Time.UnixNano(), 10),
+			string(line),
+		})
+	}
+	body, err := json.Marshal(map[string]any{"streams": streams})
+	if err != nil {
+		return nil, err
+	}
+	return body, skippedErr(&skipped)
+}
+
+// labelName returns name as a valid Loki label name, replacing characters
+// other than ASCII letters, digits and underscores with underscores and
+// prefixing a leading digit with an underscore.
+func labelName(name string) string {
+	valid := func(i int, r rune) bool {
+		return r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9'
+	}
+	var b strings.Builder
+	for i, r := range name {
+		if valid(i, r) {
+			b.WriteRune(r)
+		} else if i == 0 && '0' <= r && r <= '9' {
+			b.WriteByte('_')
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// labelKey returns a string identifying a set of labels.
+func labelKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(strconv.Quote(name))
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// ElasticsearchEncoder encodes entries for the Elasticsearch bulk API,
+// /_bulk, creating one document per entry.
+type ElasticsearchEncoder struct {
+	// Index is the index or data stream the documents are added to. If
+	// empty, the index must be part of the URL, as in /my-index/_bulk.
+	Index string
+
+	// Formatter formats the documents and must produce a JSON object. If
+	// nil, entries are formatted by a [logrus.JSONFormatter] with the time
+	// in the "@timestamp" field.
+	Formatter logrus.Formatter
+}
+
+var (
+	_ Encoder         = (*ElasticsearchEncoder)(nil)
+	_ ResponseChecker = (*ElasticsearchEncoder)(nil)
+)
+
+var elasticsearchFormatter = &logrus.JSONFormatter{
+	TimestampFormat: time.RFC3339Nano,
+	FieldMap:        logrus.FieldMap{logrus.FieldKeyTime: "@timestamp"},
+}
+
+// ContentType returns "application/x-ndjson".
+func (e *ElasticsearchEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// Encode encodes entries as newline delimited "create" actions and
+// documents.
+func (e *ElasticsearchEncoder) Encode(entries []*logrus.Entry) ([]byte, error) {
+	action := []byte(`{"create":{}}`)
+	if e.Index != "" {
+		var err error
+		action, err = json.Marshal(map[string]any{"create": map[string]string{"_index": e.Index}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	formatter := e.Formatter
+	if formatter == nil {
+		formatter = elasticsearchFormatter
+	}
+
+	var (
+		buf     bytes.Buffer
+		skipped async.BatchError
+	)
+	for _, entry := range entries {
+		doc, err := format(formatter, entry)
+		if err != nil {
+			skip(&skipped, entry, err)
+			continue
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), skippedErr(&skipped)
+}
+
+// CheckResponse returns an [*async.BatchError] holding the entries whose
+// documents the bulk response reports as rejected.
+func (e *ElasticsearchEncoder) CheckResponse(body []byte, entries []*logrus.Entry) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	if len(resp.Items) != len(entries) {
+		return fmt.Errorf("bulk response has %d items for %d documents", len(resp.Items), len(entries))
+	}
+	var (
+		failed []*logrus.Entry
+		first  string
+	)
+	for i, item := range resp.Items {
+		for _, result := range item {
+			if result.Status >= 300 {
+				if len(failed) == 0 {
+					first = result.Error.Type + ": " + result.Error.Reason
+				}
+				failed = append(failed, entries[i])
+				break
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &async.BatchError{
+		Err:     fmt.Errorf("bulk request rejected %d of %d documents, first error: %s", len(failed), len(entries), first),
+		Entries: failed,
+	}
+}
+
+// SplunkEncoder encodes entries for the Splunk HTTP Event Collector,
+// /services/collector/event. The event data of an entry are its fields,
+// its message as "message" and its level as "level". The token must be
+// passed in an "Authorization: Splunk <token>" header.
+type SplunkEncoder struct {
+	// Host, Source, SourceType and Index set the corresponding event
+	// metadata if not empty.
+	Host       string
+	Source     string
+	SourceType string
+	Index      string
+}
+
+var _ Encoder = (*SplunkEncoder)(nil)
+
+// ContentType returns "application/json".
+func (e *SplunkEncoder) ContentType() string {
+	return "application/json"
+}
+
+type splunkEvent struct {
+	Time       json.Number    `json:"time"`
+	Host       string         `json:"host,omitempty"`
+	Source     string         `json:"source,omitempty"`
+	SourceType string         `json:"sourcetype,omitempty"`
+	Index      string         `json:"index,omitempty"`
+	Event      map[string]any `json:"event"`
+}
+
+// Encode encodes entries as concatenated event objects.
+func (e *SplunkEncoder) Encode(entries []*logrus.Entry) ([]byte, error) {
+	var (
+		buf     bytes.Buffer
+		skipped async.BatchError
+	)
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		data := make(map[string]any, len(entry.Data)+2)
+		for k, v := range entry.Data {
+			if err, ok := v.(error); ok {
+				// Otherwise errors are encoded as {}, like in JSONFormatter.
+				v = errorString(err)
+			}
+			data[k] = v
+		}
+		data["message"] = entry.Message
+		data["level"] = entry.Level.String()
+
+		ms := entry.Time.UnixMilli()
+		err := enc.Encode(splunkEvent{
+			Time:       json.Number(fmt.Sprintf("%d.%03d", ms/1000, ms%1000)),
+			Host:       e.Host,
+			Source:     e.Source,
+			SourceType: e.SourceType,
+			Index:      e.Index,
+			Event:      data,
+		})
+		if err != nil {
+			skip(&skipped, entry, fmt.Errorf("encoding event: %w", err))
+		}
+	}
+	return buf.Bytes(), skippedErr(&skipped)
+}
@@ -0,0 +1,338 @@
+// Package httpbatch provides a hook that sends entries in batches to HTTP
+// log ingestion endpoints, such as the Grafana Loki push API, the
+// Elasticsearch bulk API and the Splunk HTTP Event Collector.
+//
+// The format of the request bodies is determined by an [Encoder]:
+//
+//	hook := httpbatch.New("http://loki:3100/loki/api/v1/push",
+//		&httpbatch.LokiEncoder{Labels: []string{"service"}},
+//		&httpbatch.Options{Gzip: true})
+//	defer hook.Close(context.Background())
+//	logger.AddHook(hook)
+//
+// Entries are queued and sent in the background by an [async.Hook]. A batch
+// is sent when it reaches Options.BatchSize entries or Options.FlushInterval
+// elapses, and is split into several requests if its encoding exceeds
+// Options.MaxBatchBytes. Requests that fail with a network error, a 429 or
+// a 5xx status are retried with exponential backoff.
+package httpbatch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/async"
+)
+
+const (
+	// DefaultMaxBatchBytes is the maximum size of a request body, before
+	// compression, if Options.MaxBatchBytes is 0.
+	DefaultMaxBatchBytes = 1 << 20
+	// DefaultMaxRetries is the number of retries if Options.MaxRetries is 0.
+	DefaultMaxRetries = 3
+	// DefaultMinBackoff and DefaultMaxBackoff bound the delay between
+	// retries if Options.MinBackoff or Options.MaxBackoff is 0.
+	DefaultMinBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultTimeout is the timeout of a request if Options.Client is nil.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Options are options for a [Hook].
+// A zero Options consists entirely of default values.
+type Options struct {
+	// Client sends the requests. If nil, a client with a timeout of
+	// DefaultTimeout is used.
+	Client *http.Client
+
+	// Headers are added to every request, for example for authentication.
+	Headers http.Header
+
+	// Gzip compresses the request bodies.
+	Gzip bool
+
+	// QueueSize, BatchSize, FlushInterval and DropPolicy configure the
+	// queue and batching, see [async.Options].
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	DropPolicy    async.DropPolicy
+
+	// MaxBatchBytes is the maximum size of a request body before
+	// compression. Larger batches are split. If 0, DefaultMaxBatchBytes is
+	// used.
+	MaxBatchBytes int
+
+	// MaxRetries is the number of times a failed request is retried. If 0,
+	// DefaultMaxRetries is used; if negative, requests are not retried.
+	MaxRetries int
+
+	// MinBackoff is the delay before the first retry. It doubles after
+	// every retry, up to MaxBackoff. A Retry-After header of a 429 or 503
+	// response takes precedence, up to MaxBackoff. If 0, DefaultMinBackoff
+	// and DefaultMaxBackoff are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// ErrorHandler is called with the entries of a batch that could not be
+	// sent. If a batch was split into several requests, or the endpoint
+	// rejected some of the entries, only the entries that were not sent
+	// are included. If nil, errors are printed to stderr.
+	ErrorHandler func(err error, entries []*logrus.Entry)
+}
+
+// StatusError is returned for a response with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+	// Body is the beginning of the response body.
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpbatch: unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// Hook queues entries and sends them in batches. Its queue and counters
+// are those of the embedded [async.Hook]; it can be added to a
+// metrics.Collector with AddAsyncHook(name, hook.Hook).
+type Hook struct {
+	*async.Hook
+	sender *sender
+}
+
+var _ logrus.Hook = (*Hook)(nil)
+
+// New returns a Hook posting batches of entries encoded by enc to url, and
+// starts its background worker. If opts is nil, the default options are
+// used.
+//
+// New panics if enc is nil.
+func New(url string, enc Encoder, opts *Options) *Hook {
+	if enc == nil {
+		panic("cannot create HTTP batch hook from nil encoder")
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	s := &sender{url: url, enc: enc, opts: *opts}
+	if s.opts.Client == nil {
+		s.opts.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+	if s.opts.MaxBatchBytes <= 0 {
+		s.opts.MaxBatchBytes = DefaultMaxBatchBytes
+	}
+	if s.opts.MaxRetries == 0 {
+		s.opts.MaxRetries = DefaultMaxRetries
+	}
+	if s.opts.MinBackoff <= 0 {
+		s.opts.MinBackoff = DefaultMinBackoff
+	}
+	if s.opts.MaxBackoff <= 0 {
+		s.opts.MaxBackoff = max(DefaultMaxBackoff, s.opts.MinBackoff)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	return &Hook{
+		Hook: async.New(s, &async.Options{
+			QueueSize:     opts.QueueSize,
+			DropPolicy:    opts.DropPolicy,
+			BatchSize:     opts.BatchSize,
+			FlushInterval: opts.FlushInterval,
+			ErrorHandler:  opts.ErrorHandler,
+		}),
+		sender: s,
+	}
+}
+
+// Close stops accepting entries and waits until the queued entries have
+// been sent. If ctx is done first, pending requests and retries are
+// canceled and ctx.Err() is returned.
+func (h *Hook) Close(ctx context.Context) error {
+	err := h.Hook.Close(ctx)
+	if err != nil {
+		h.sender.cancel()
+	}
+	return err
+}
+
+// sender is the batch hook wrapped by the async hook.
+type sender struct {
+	url  string
+	enc  Encoder
+	opts Options
+
+	// ctx is canceled when closing the hook times out.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var _ async.BatchHook = (*sender)(nil)
+
+func (s *sender) Levels() []logrus.Level {
+	return logrus.Levels()
+}
+
+func (s *sender) Fire(entry *logrus.Entry) error {
+	return s.FireBatch([]*logrus.Entry{entry})
+}
+
+// FireBatch encodes entries, splitting them in halves until each part fits
+// into MaxBatchBytes, and sends the parts. If some entries could not be
+// sent, it returns an [*async.BatchError] holding them.
+func (s *sender) FireBatch(entries []*logrus.Entry) error {
+	var batchErr async.BatchError
+	s.fire(entries, &batchErr)
+	if len(batchErr.Entries) == 0 {
+		return nil
+	}
+	return &batchErr
+}
+
+// fire sends entries, or the parts they are split into, and adds the
+// entries that could not be encoded or sent and the errors to batchErr. A
+// failed part does not prevent sending the others.
+func (s *sender) fire(entries []*logrus.Entry, batchErr *async.BatchError) {
+	body, err := s.enc.Encode(entries)
+	var skipped *async.BatchError
+	if errors.As(err, &skipped) {
+		err = nil
+	}
+	if err == nil && len(body) > s.opts.MaxBatchBytes && len(entries) > 1 {
+		mid := len(entries) / 2
+		s.fire(entries[:mid], batchErr)
+		s.fire(entries[mid:], batchErr)
+		return
+	}
+	if skipped != nil {
+		batchErr.Err = errors.Join(batchErr.Err, skipped.Err)
+		batchErr.Entries = append(batchErr.Entries, skipped.Entries...)
+		entries = without(entries, skipped.Entries)
+	}
+	if err == nil && len(entries) > 0 {
+		err = s.send(body, entries)
+	}
+	if err == nil {
+		return
+	}
+
+	failed := entries
+	var partErr *async.BatchError
+	if errors.As(err, &partErr) {
+		err, failed = partErr.Err, partErr.Entries
+	}
+	batchErr.Err = errors.Join(batchErr.Err, err)
+	batchErr.Entries = append(batchErr.Entries, failed...)
+}
+
+// without returns the entries not in skipped, keeping their order.
+func without(entries, skipped []*logrus.Entry) []*logrus.Entry {
+	drop := make(map[*logrus.Entry]bool, len(skipped))
+	for _, entry := range skipped {
+		drop[entry] = true
+	}
+	kept := make([]*logrus.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if !drop[entry] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// send posts body, the encoding of entries, retrying as configured.
+func (s *sender) send(body []byte, entries []*logrus.Entry) error {
+	if s.opts.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	backoff := s.opts.MinBackoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := s.post(body, entries)
+		if err == nil || retryAfter < 0 || attempt >= s.opts.MaxRetries {
+			return err
+		}
+
+		delay := backoff
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		timer := time.NewTimer(min(delay, s.opts.MaxBackoff))
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff = min(backoff*2, s.opts.MaxBackoff)
+	}
+}
+
+// post sends a single request. If it fails, post returns a negative delay
+// if the request must not be retried, and otherwise the delay requested by
+// the server, or 0.
+func (s *sender) post(body []byte, entries []*logrus.Entry) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	for k, v := range s.opts.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", s.enc.ContentType())
+	if s.opts.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		if s.ctx.Err() != nil {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if c, ok := s.enc.(ResponseChecker); ok {
+			if err := c.CheckResponse(respBody, entries); err != nil {
+				return -1, err
+			}
+		}
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		var delay time.Duration
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			delay = time.Duration(secs) * time.Second
+		}
+		return delay, statusError(resp.StatusCode, respBody)
+	default:
+		return -1, statusError(resp.StatusCode, respBody)
+	}
+}
+
+func statusError(code int, body []byte) error {
+	const maxBody = 512
+	if len(body) > maxBody {
+		body = body[:maxBody]
+	}
+	return &StatusError{StatusCode: code, Body: string(bytes.TrimSpace(body))}
+}
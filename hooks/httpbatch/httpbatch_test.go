@@ -0,0 +1,400 @@
+package httpbatch_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/httpbatch"
+)
+
+// recorder is a handler recording the request bodies, responding with the
+// queued status codes first and 200 afterwards.
+type recorder struct {
+	mu       sync.Mutex
+	bodies   [][]byte
+	headers  []http.Header
+	statuses []int
+	respBody string
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	b, _ := io.ReadAll(body)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.bodies = append(rec.bodies, b)
+	rec.headers = append(rec.headers, r.Header.Clone())
+	if len(rec.statuses) > 0 {
+		status := rec.statuses[0]
+		rec.statuses = rec.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	_, _ = io.WriteString(w, rec.respBody)
+}
+
+func (rec *recorder) requests() ([][]byte, []http.Header) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.bodies, rec.headers
+}
+
+func newLogger(hook logrus.Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+	return logger
+}
+
+func closeHook(t *testing.T, hook *httpbatch.Hook) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, hook.Close(ctx))
+}
+
+func TestLoki(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	hook := httpbatch.New(srv.URL, &httpbatch.LokiEncoder{
+		Labels:       []string{"service"},
+		StaticLabels: map[string]string{"env": "test"},
+	}, &httpbatch.Options{
+		Gzip:    true,
+		Headers: http.Header{"X-Scope-Orgid": {"tenant"}},
+	})
+	logger := newLogger(hook)
+	logger.WithField("service", "api").Info("one")
+	logger.WithField("service", "api").Info("two")
+	logger.WithField("service", "db").Warn("three")
+	closeHook(t, hook)
+
+	bodies, headers := rec.requests()
+	require.Len(t, bodies, 1)
+	assert.Equal(t, "tenant", headers[0].Get("X-Scope-OrgID"))
+	assert.Equal(t, "application/json", headers[0].Get("Content-Type"))
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	require.NoError(t, json.Unmarshal(bodies[0], &push))
+	require.Len(t, push.Streams, 2)
+	assert.Equal(t, map[string]string{"env": "test", "level": "info", "service": "api"}, push.Streams[0].Stream)
+	assert.Len(t, push.Streams[0].Values, 2)
+	assert.Equal(t, map[string]string{"env": "test", "level": "warning", "service": "db"}, push.Streams[1].Stream)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal([]byte(push.Streams[1].Values[0][1]), &line))
+	assert.Equal(t, "three", line["msg"])
+}
+
+func TestLokiLabelNames(t *testing.T) {
+	enc := &httpbatch.LokiEncoder{
+		Labels:       []string{"http.method", "1st"},
+		StaticLabels: map[string]string{"k8s-namespace": "prod"},
+	}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"http.method": "GET", "1st": "x"})
+	body, err := enc.Encode([]*logrus.Entry{entry})
+	require.NoError(t, err)
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+		} `json:"streams"`
+	}
+	require.NoError(t, json.Unmarshal(body, &push))
+	require.Len(t, push.Streams, 1)
+	assert.Equal(t, map[string]string{
+		"http_method":   "GET",
+		"_1st":          "x",
+		"k8s_namespace": "prod",
+		"level":         "panic",
+	}, push.Streams[0].Stream)
+}
+
+func TestElasticsearch(t *testing.T) {
+	rec := &recorder{respBody: `{"errors":false,"items":[]}`}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	hook := httpbatch.New(srv.URL, &httpbatch.ElasticsearchEncoder{Index: "logs"}, nil)
+	logger := newLogger(hook)
+	logger.WithError(errors.New("boom")).Error("failed")
+	closeHook(t, hook)
+
+	bodies, headers := rec.requests()
+	require.Len(t, bodies, 1)
+	assert.Equal(t, "application/x-ndjson", headers[0].Get("Content-Type"))
+	sc := bufio.NewScanner(bytes.NewReader(bodies[0]))
+	require.True(t, sc.Scan())
+	assert.JSONEq(t, `{"create":{"_index":"logs"}}`, sc.Text())
+	require.True(t, sc.Scan())
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(sc.Bytes(), &doc))
+	assert.Equal(t, "failed", doc["msg"])
+	assert.Equal(t, "boom", doc["error"])
+	assert.Contains(t, doc, "@timestamp")
+	assert.False(t, sc.Scan())
+}
+
+func TestElasticsearchRejectedDocuments(t *testing.T) {
+	rec := &recorder{respBody: `{"errors":true,"items":[{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var errs []error
+	var mu sync.Mutex
+	hook := httpbatch.New(srv.URL, &httpbatch.ElasticsearchEncoder{}, &httpbatch.Options{
+		ErrorHandler: func(err error, _ []*logrus.Entry) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		},
+	})
+	newLogger(hook).Info("rejected")
+	closeHook(t, hook)
+
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "mapper_parsing_exception: bad")
+	assert.EqualValues(t, 1, hook.Stats().Failed)
+}
+
+func TestElasticsearchPartiallyRejected(t *testing.T) {
+	rec := &recorder{respBody: `{"errors":true,"items":[
+		{"create":{"status":201}},
+		{"create":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"busy"}}},
+		{"create":{"status":201}}]}`}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var failed []*logrus.Entry
+	hook := httpbatch.New(srv.URL, &httpbatch.ElasticsearchEncoder{}, &httpbatch.Options{
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+		ErrorHandler: func(err error, entries []*logrus.Entry) {
+			assert.ErrorContains(t, err, "rejected 1 of 3 documents")
+			failed = append(failed, entries...)
+		},
+	})
+	logger := newLogger(hook)
+	for _, msg := range []string{"one", "two", "three"} {
+		logger.Info(msg)
+	}
+	closeHook(t, hook)
+
+	require.Len(t, failed, 1)
+	assert.Equal(t, "two", failed[0].Message)
+	assert.Equal(t, uint64(2), hook.Stats().Delivered)
+	assert.Equal(t, uint64(1), hook.Stats().Failed)
+}
+
+func TestSplunk(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	hook := httpbatch.New(srv.URL, &httpbatch.SplunkEncoder{Source: "app", Index: "main"}, &httpbatch.Options{
+		Headers: http.Header{"Authorization": {"Splunk secret"}},
+	})
+	entry := logrus.NewEntry(logrus.New()).WithField("user", "alice")
+	entry.Level = logrus.InfoLevel
+	entry.Message = "login"
+	entry.Time = time.Unix(1700000000, 123456789)
+	require.NoError(t, hook.Fire(entry))
+	closeHook(t, hook)
+
+	bodies, headers := rec.requests()
+	require.Len(t, bodies, 1)
+	assert.Equal(t, "Splunk secret", headers[0].Get("Authorization"))
+	assert.JSONEq(t, `{
+		"time": 1700000000.123,
+		"source": "app",
+		"index": "main",
+		"event": {"message": "login", "level": "info", "user": "alice"}
+	}`, string(bodies[0]))
+}
+
+type valueError struct{ msg string }
+
+func (e *valueError) Error() string { return e.msg }
+
+func TestSplunkUnencodableEntry(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var (
+		failed    []*logrus.Entry
+		failedErr error
+	)
+	hook := httpbatch.New(srv.URL, &httpbatch.SplunkEncoder{}, &httpbatch.Options{
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+		ErrorHandler: func(err error, entries []*logrus.Entry) {
+			failedErr = err
+			failed = append(failed, entries...)
+		},
+	})
+	logger := newLogger(hook)
+	var nilErr *valueError
+	logger.WithError(nilErr).Info("one")
+	logger.WithField("ch", make(chan int)).Info("two")
+	logger.Info("three")
+	closeHook(t, hook)
+
+	bodies, _ := rec.requests()
+	require.Len(t, bodies, 1)
+	dec := json.NewDecoder(bytes.NewReader(bodies[0]))
+	var messages []any
+	for dec.More() {
+		var event struct{ Event map[string]any }
+		require.NoError(t, dec.Decode(&event))
+		messages = append(messages, event.Event["message"])
+		if event.Event["message"] == "one" {
+			assert.Equal(t, "<nil>", event.Event[logrus.ErrorKey])
+		}
+	}
+	assert.Equal(t, []any{"one", "three"}, messages)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "two", failed[0].Message)
+	assert.ErrorContains(t, failedErr, "encoding event")
+	assert.Equal(t, uint64(2), hook.Stats().Delivered)
+	assert.Equal(t, uint64(1), hook.Stats().Failed)
+}
+
+func TestBatching(t *testing.T) {
+	rec := &recorder{respBody: `{"errors":false}`}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	hook := httpbatch.New(srv.URL, &httpbatch.ElasticsearchEncoder{}, &httpbatch.Options{
+		BatchSize:     4,
+		FlushInterval: time.Hour,
+		MaxBatchBytes: 400,
+	})
+	logger := newLogger(hook)
+	for range 10 {
+		logger.Info("a message of some length")
+	}
+	closeHook(t, hook)
+
+	bodies, _ := rec.requests()
+	docs := 0
+	for _, body := range bodies {
+		assert.LessOrEqual(t, len(body), 400)
+		docs += bytes.Count(body, []byte("\n")) / 2
+	}
+	assert.Equal(t, 10, docs)
+	assert.Greater(t, len(bodies), 3, "batches of 4 exceed MaxBatchBytes and are split")
+	assert.EqualValues(t, 10, hook.Stats().Delivered)
+}
+
+func TestBatchingFailedPart(t *testing.T) {
+	rec := &recorder{respBody: `{"errors":false}`, statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var failed []*logrus.Entry
+	hook := httpbatch.New(srv.URL, &httpbatch.ElasticsearchEncoder{}, &httpbatch.Options{
+		BatchSize:     4,
+		FlushInterval: time.Hour,
+		MaxBatchBytes: 400,
+		ErrorHandler: func(_ error, entries []*logrus.Entry) {
+			failed = append(failed, entries...)
+		},
+	})
+	logger := newLogger(hook)
+	for range 4 {
+		logger.Info("a message of some length")
+	}
+	closeHook(t, hook)
+
+	// The first part is rejected, the others are still sent.
+	bodies, _ := rec.requests()
+	assert.Greater(t, len(bodies), 1)
+	require.NotEmpty(t, failed)
+	stats := hook.Stats()
+	assert.Equal(t, uint64(len(failed)), stats.Failed)
+	assert.Equal(t, uint64(4-len(failed)), stats.Delivered)
+	assert.NotZero(t, stats.Delivered)
+}
+
+func TestRetry(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	hook := httpbatch.New(srv.URL, &httpbatch.SplunkEncoder{}, &httpbatch.Options{MinBackoff: time.Millisecond})
+	newLogger(hook).Info("eventually")
+	closeHook(t, hook)
+
+	bodies, _ := rec.requests()
+	assert.Len(t, bodies, 3)
+	assert.EqualValues(t, 1, hook.Stats().Delivered)
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var got error
+	hook := httpbatch.New(srv.URL, &httpbatch.SplunkEncoder{}, &httpbatch.Options{
+		MinBackoff:   time.Millisecond,
+		ErrorHandler: func(err error, _ []*logrus.Entry) { got = err },
+	})
+	newLogger(hook).Info("rejected")
+	closeHook(t, hook)
+
+	bodies, _ := rec.requests()
+	assert.Len(t, bodies, 1)
+	var statusErr *httpbatch.StatusError
+	require.ErrorAs(t, got, &statusErr)
+	assert.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+}
+
+func TestCloseCancelsRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	hook := httpbatch.New(srv.URL, &httpbatch.SplunkEncoder{}, &httpbatch.Options{
+		MinBackoff:   time.Hour,
+		ErrorHandler: func(error, []*logrus.Entry) {},
+	})
+	newLogger(hook).Info("stuck")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, hook.Close(ctx), context.DeadlineExceeded)
+	require.Eventually(t, func() bool { return hook.Stats().Failed == 1 }, 5*time.Second, time.Millisecond)
+}
@@ -0,0 +1,331 @@
+// Package ringbuffer provides a hook that keeps the most recent log entries
+// in memory, including entries less severe than the logger's level, and
+// serves them over HTTP.
+//
+// A [Buffer] is a [logrus.CaptureHook]: with a capture level of
+// [logrus.DebugLevel], debug entries are recorded even if the logger only
+// writes info entries, so that they are available when investigating an
+// incident:
+//
+//	buf := ringbuffer.New(&ringbuffer.Options{Size: 5000})
+//	logger.AddHook(buf)
+//	http.Handle("/debug/logs", buf)
+//
+// In flight recorder mode, the recorded entries that were not written are
+// written to the logger's output when an error is logged, so that the
+// error is preceded by the debug entries leading up to it.
+package ringbuffer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/filter"
+)
+
+// DefaultSize is the number of entries kept if Options.Size is 0.
+const DefaultSize = 1000
+
+// Options are options for a [Buffer].
+// A zero Options consists entirely of default values.
+type Options struct {
+	// Size is the maximum number of entries kept. If 0, DefaultSize is
+	// used.
+	Size int
+
+	// MaxBytes, if positive, additionally limits the approximate size of
+	// the kept entries: the length of their messages, field keys and
+	// formatted field values.
+	MaxBytes int
+
+	// Level is the least severe level recorded, regardless of the logger's
+	// level. The zero value, PanicLevel, selects DebugLevel.
+	Level logrus.Level
+
+	// FlightRecorder enables writing the recorded entries that were not
+	// written, because they were less severe than the logger's level, to
+	// the logger's output when an entry of DumpLevel or more severe is
+	// logged. Each entry is written at most once.
+	FlightRecorder bool
+
+	// DumpLevel is the level triggering the flight recorder. The zero
+	// value, PanicLevel, selects ErrorLevel.
+	DumpLevel logrus.Level
+}
+
+// record is an entry kept in the buffer.
+type record struct {
+	entry *logrus.Entry
+	size  int
+	// written reports whether the entry was written by the logger or
+	// dumped by the flight recorder.
+	written bool
+}
+
+// Buffer is a hook keeping the most recent entries in memory. It is also an
+// [http.Handler] serving them, see [Buffer.ServeHTTP].
+type Buffer struct {
+	opts   Options
+	levels []logrus.Level
+
+	mu      sync.Mutex
+	records []record // ring of opts.Size records
+	start   int      // index of the oldest record
+	count   int
+	bytes   int
+}
+
+var (
+	_ logrus.CaptureHook = (*Buffer)(nil)
+	_ http.Handler       = (*Buffer)(nil)
+)
+
+// New returns an empty Buffer. If opts is nil, the default options are
+// used.
+func New(opts *Options) *Buffer {
+	if opts == nil {
+		opts = &Options{}
+	}
+	b := &Buffer{opts: *opts}
+	if b.opts.Size <= 0 {
+		b.opts.Size = DefaultSize
+	}
+	if b.opts.Level == logrus.PanicLevel {
+		b.opts.Level = logrus.DebugLevel
+	}
+	if b.opts.DumpLevel == logrus.PanicLevel {
+		b.opts.DumpLevel = logrus.ErrorLevel
+	}
+	for _, level := range logrus.Levels() {
+		if level.AtLeast(b.opts.Level) {
+			b.levels = append(b.levels, level)
+		}
+	}
+	b.records = make([]record, b.opts.Size)
+	return b
+}
+
+// Levels returns the levels up to Options.Level.
+func (b *Buffer) Levels() []logrus.Level {
+	return b.levels
+}
+
+// CaptureLevel returns Options.Level.
+func (b *Buffer) CaptureLevel() logrus.Level {
+	return b.opts.Level
+}
+
+// Fire records a copy of entry and, in flight recorder mode, writes the
+// recorded entries that were not written yet if entry is severe enough.
+func (b *Buffer) Fire(entry *logrus.Entry) error {
+	rec := record{
+		entry:   snapshot(entry),
+		written: entry.Logger != nil && entry.Level.AtLeast(entry.Logger.GetLevel()),
+	}
+	if b.opts.MaxBytes > 0 {
+		rec.size = size(entry)
+	}
+
+	var dump []*logrus.Entry
+	b.mu.Lock()
+	if b.opts.FlightRecorder && entry.Level.AtLeast(b.opts.DumpLevel) {
+		for i := range b.count {
+			r := &b.records[(b.start+i)%len(b.records)]
+			if !r.written {
+				dump = append(dump, r.entry)
+				r.written = true
+			}
+		}
+	}
+	b.push(rec)
+	b.mu.Unlock()
+
+	for _, e := range dump {
+		entry.Logger.WriteEntry(e)
+	}
+	return nil
+}
+
+// push adds rec, evicting the oldest records as needed. It must be called
+// with b.mu held.
+func (b *Buffer) push(rec record) {
+	if b.count == len(b.records) {
+		b.evict()
+	}
+	b.records[(b.start+b.count)%len(b.records)] = rec
+	b.count++
+	b.bytes += rec.size
+	for b.opts.MaxBytes > 0 && b.bytes > b.opts.MaxBytes && b.count > 1 {
+		b.evict()
+	}
+}
+
+func (b *Buffer) evict() {
+	b.bytes -= b.records[b.start].size
+	b.records[b.start] = record{}
+	b.start = (b.start + 1) % len(b.records)
+	b.count--
+}
+
+// Entries returns the recorded entries, oldest first. The entries are
+// shared with the buffer and must not be modified.
+func (b *Buffer) Entries() []*logrus.Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries := make([]*logrus.Entry, b.count)
+	for i := range b.count {
+		entries[i] = b.records[(b.start+i)%len(b.records)].entry
+	}
+	return entries
+}
+
+// Reset removes all recorded entries.
+func (b *Buffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.records)
+	b.start, b.count, b.bytes = 0, 0, 0
+}
+
+// ServeHTTP serves the recorded entries, oldest first, as a JSON array of
+// objects formatted by [logrus.JSONFormatter], or as text formatted by
+// [logrus.TextFormatter] if the format query parameter is "text".
+//
+// The entries can be selected with the following query parameters:
+//
+//	level   the least severe level, for example level=warn
+//	field   a field value as key=value; may be repeated
+//	filter  a filter expression, see [filter.Parse]
+//	since   the earliest time, as RFC 3339 or as a duration before now, for example since=5m
+//	until   the latest time, in the same format as since
+//	limit   the maximum number of entries; the most recent ones are kept
+func (b *Buffer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	match, limit, err := parseQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var entries []*logrus.Entry
+	for _, e := range b.Entries() {
+		if match.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		formatter := &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+		for _, e := range entries {
+			if line, err := formatter.Format(e); err == nil {
+				_, _ = w.Write(line)
+			}
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	formatter := &logrus.JSONFormatter{}
+	out := make([]json.RawMessage, 0, len(entries))
+	for _, e := range entries {
+		if obj, err := formatter.Format(e); err == nil {
+			out = append(out, obj)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// parseQuery returns the predicate and limit selected by the query
+// parameters of r.
+func parseQuery(r *http.Request, now time.Time) (filter.Predicate, int, error) {
+	q := r.URL.Query()
+	var preds []filter.Predicate
+
+	if s := q.Get("level"); s != "" {
+		level, err := logrus.ParseLevel(s)
+		if err != nil {
+			return nil, 0, err
+		}
+		preds = append(preds, filter.LevelAtLeast(level))
+	}
+	for _, kv := range q["field"] {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid field %q, want key=value", kv)
+		}
+		preds = append(preds, filter.FieldEquals(key, value))
+	}
+	if s := q.Get("filter"); s != "" {
+		pred, err := filter.Parse(s)
+		if err != nil {
+			return nil, 0, err
+		}
+		preds = append(preds, pred)
+	}
+	if s := q.Get("since"); s != "" {
+		since, err := parseTime(s, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		preds = append(preds, func(e *logrus.Entry) bool { return !e.Time.Before(since) })
+	}
+	if s := q.Get("until"); s != "" {
+		until, err := parseTime(s, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		preds = append(preds, func(e *logrus.Entry) bool { return !e.Time.After(until) })
+	}
+
+	limit := 0
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, 0, fmt.Errorf("invalid limit %q", s)
+		}
+		limit = n
+	}
+	return filter.And(preds...), limit, nil
+}
+
+// parseTime parses an RFC 3339 time or a duration before now.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want RFC 3339 or a duration", s)
+	}
+	return t, nil
+}
+
+// snapshot copies entry so that it can be kept after Fire returns.
+func snapshot(entry *logrus.Entry) *logrus.Entry {
+	dup := entry.Dup()
+	dup.Level = entry.Level
+	dup.Message = entry.Message
+	if entry.Caller != nil {
+		caller := *entry.Caller
+		dup.Caller = &caller
+	}
+	return dup
+}
+
+// size returns the approximate size of entry.
+func size(entry *logrus.Entry) int {
+	n := len(entry.Message)
+	for k, v := range entry.Data {
+		n += len(k) + len(fmt.Sprint(v))
+	}
+	return n
+}
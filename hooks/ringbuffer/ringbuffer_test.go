@@ -0,0 +1,163 @@
+package ringbuffer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/ringbuffer"
+)
+
+func newLogger(buf *bytes.Buffer) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	logger.SetFormatter(&logrus.TextFormatter{DisableColors: true, DisableTimestamp: true})
+	return logger
+}
+
+func messages(entries []*logrus.Entry) []string {
+	var msgs []string
+	for _, e := range entries {
+		msgs = append(msgs, e.Message)
+	}
+	return msgs
+}
+
+func TestCapturesBelowLoggerLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := newLogger(&out)
+	assert.False(t, logger.IsLevelEnabled(logrus.DebugLevel))
+
+	rb := ringbuffer.New(&ringbuffer.Options{Size: 3})
+	logger.AddHook(rb)
+	assert.True(t, logger.IsLevelEnabled(logrus.DebugLevel))
+	assert.False(t, logger.IsLevelEnabled(logrus.TraceLevel))
+
+	logger.Debug("debug 1")
+	logger.Info("info 1")
+	logger.Trace("trace")
+	logger.Debug("debug 2")
+	logger.WithField("key", "value").Warn("warn")
+
+	assert.Equal(t, []string{"info 1", "debug 2", "warn"}, messages(rb.Entries()))
+	assert.Equal(t, "level=info msg=\"info 1\"\nlevel=warning msg=warn key=value\n", out.String())
+	assert.Equal(t, map[logrus.Level]uint64{logrus.InfoLevel: 1, logrus.WarnLevel: 1}, logger.Stats().Entries)
+
+	require.True(t, logger.RemoveHook(rb))
+	assert.False(t, logger.IsLevelEnabled(logrus.DebugLevel))
+
+	rb.Reset()
+	assert.Empty(t, rb.Entries())
+}
+
+func TestOtherHooksDoNotSeeCapturedEntries(t *testing.T) {
+	var out bytes.Buffer
+	logger := newLogger(&out)
+	logger.AddHook(ringbuffer.New(nil))
+	var other []string
+	logger.AddHook(hookFunc(func(e *logrus.Entry) { other = append(other, e.Message) }))
+
+	logger.Debug("debug")
+	logger.Info("info")
+	assert.Equal(t, []string{"info"}, other)
+}
+
+type hookFunc func(*logrus.Entry)
+
+func (f hookFunc) Levels() []logrus.Level     { return logrus.AllLevels }
+func (f hookFunc) Fire(e *logrus.Entry) error { f(e); return nil }
+
+func TestMaxBytes(t *testing.T) {
+	logger := newLogger(&bytes.Buffer{})
+	rb := ringbuffer.New(&ringbuffer.Options{MaxBytes: 10})
+	logger.AddHook(rb)
+
+	logger.Info("12345")
+	logger.Info("67890")
+	logger.Info("abc")
+	assert.Equal(t, []string{"67890", "abc"}, messages(rb.Entries()))
+
+	logger.Info("a message longer than the limit")
+	assert.Equal(t, []string{"a message longer than the limit"}, messages(rb.Entries()))
+}
+
+func TestFlightRecorder(t *testing.T) {
+	var out bytes.Buffer
+	logger := newLogger(&out)
+	logger.AddHook(ringbuffer.New(&ringbuffer.Options{FlightRecorder: true}))
+
+	logger.Debug("connecting")
+	logger.Info("started")
+	logger.Debug("retrying")
+	logger.Error("failed")
+	logger.Error("failed again")
+
+	assert.Equal(t, `level=info msg=started
+level=debug msg=connecting
+level=debug msg=retrying
+level=error msg=failed
+level=error msg="failed again"
+`, out.String())
+}
+
+func TestServeHTTP(t *testing.T) {
+	logger := newLogger(&bytes.Buffer{})
+	rb := ringbuffer.New(nil)
+	logger.AddHook(rb)
+
+	old := time.Now().Add(-time.Hour)
+	logger.WithTime(old).Info("old")
+	logger.WithField("component", "db").Debug("query")
+	logger.WithField("component", "db").Warn("slow query")
+	logger.WithField("component", "http").Error("bad gateway")
+
+	get := func(query string) (*httptest.ResponseRecorder, []string) {
+		rec := httptest.NewRecorder()
+		rb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+			return rec, nil
+		}
+		var entries []map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+		var msgs []string
+		for _, e := range entries {
+			msgs = append(msgs, e["msg"].(string))
+		}
+		return rec, msgs
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"old", "query", "slow query", "bad gateway"}},
+		{"level=warn", []string{"slow query", "bad gateway"}},
+		{"field=component%3Ddb", []string{"query", "slow query"}},
+		{"filter=" + `component=="db"%26%26level>=warn`, []string{"slow query"}},
+		{"since=10m", []string{"query", "slow query", "bad gateway"}},
+		{"until=" + old.Add(time.Minute).UTC().Format(time.RFC3339), []string{"old"}},
+		{"limit=2", []string{"slow query", "bad gateway"}},
+	}
+	for _, tc := range tests {
+		rec, msgs := get(tc.query)
+		assert.Equal(t, http.StatusOK, rec.Code, tc.query)
+		assert.Equal(t, tc.want, msgs, tc.query)
+	}
+
+	for _, query := range []string{"level=loud", "filter=(", "since=yesterday", "limit=-1", "field=x"} {
+		rec, _ := get(query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	rec, _ := get("format=text&level=error")
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.True(t, strings.HasSuffix(rec.Body.String(), "level=error msg=\"bad gateway\" component=http\n"), rec.Body.String())
+}
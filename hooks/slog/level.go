@@ -40,7 +40,9 @@ type Leveler interface {
 //   - [logrus.FatalLevel] -> [slog.LevelError] + 2
 //   - [logrus.PanicLevel] -> [slog.LevelError] + 4
 //
-// Unknown Logrus levels map to [slog.LevelError].
+// Levels registered with [logrus.RegisterLevel] map to their
+// [logrus.CustomLevel.SlogLevel]. Unknown Logrus levels map to
+// [slog.LevelError].
 type Level logrus.Level
 
 // Level returns l mapped to the corresponding slog level.
@@ -61,7 +63,9 @@ var _ slog.Leveler = Level(0)
 //   - [slog.LevelError] + 2 -> [logrus.FatalLevel]
 //   - [slog.LevelError] + 4 -> [logrus.PanicLevel]
 //
-// Levels between these boundaries map to the next lower Logrus severity.
+// A slog level equal to the [logrus.CustomLevel.SlogLevel] of a level
+// registered with [logrus.RegisterLevel] maps to that level. Other levels
+// between these boundaries map to the next lower Logrus severity.
 // Levels below [slog.LevelDebug] map to [logrus.TraceLevel], and levels at or
 // above the Panic boundary map to [logrus.PanicLevel].
 type SlogLevel slog.Level
@@ -75,6 +79,11 @@ var _ Leveler = SlogLevel(0)
 
 // toLogrusLevel maps a slog level using the default mapping.
 func toLogrusLevel(level slog.Level) logrus.Level {
+	for _, l := range logrus.Levels() {
+		if c, ok := logrus.LookupLevel(l); ok && c.SlogLevel != nil && c.SlogLevel.Level() == level {
+			return l
+		}
+	}
 	switch {
 	case level >= slogLevelPanic:
 		return logrus.PanicLevel
@@ -113,6 +122,12 @@ func toSlogLevel(level logrus.Level) slog.Level {
 	case logrus.TraceLevel:
 		return slogLevelTrace
 	default:
+		if c, ok := logrus.LookupLevel(level); ok {
+			if c.SlogLevel != nil {
+				return c.SlogLevel.Level()
+			}
+			return toSlogLevel(level.Builtin())
+		}
 		// Treat all unknown levels as errors
 		return slogLevelError
 	}
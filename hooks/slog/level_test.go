@@ -158,3 +158,31 @@ func TestSlogLevel(t *testing.T) {
 		})
 	}
 }
+
+var noticeLevel, _ = logrus.RegisterLevel(logrus.CustomLevel{
+	Name:      "notice",
+	Severity:  35,
+	SlogLevel: slog.LevelInfo + 2,
+})
+
+var auditLevel, _ = logrus.RegisterLevel(logrus.CustomLevel{
+	Name:     "audit",
+	Severity: 15,
+})
+
+// TestCustomLevel verifies the mapping of levels registered with
+// logrus.RegisterLevel.
+func TestCustomLevel(t *testing.T) {
+	if got := lslog.Level(noticeLevel).Level(); got != slog.LevelInfo+2 {
+		t.Errorf("notice: got %v, want %v", got, slog.LevelInfo+2)
+	}
+	if got := lslog.Level(auditLevel).Level(); got != slog.LevelError+2 {
+		t.Errorf("audit: got %v, want %v", got, slog.LevelError+2)
+	}
+	if got := lslog.SlogLevel(slog.LevelInfo + 2).Level(); got != noticeLevel {
+		t.Errorf("slog info+2: got %v, want %v", got, noticeLevel)
+	}
+	if got := lslog.SlogLevel(slog.LevelInfo + 3).Level(); got != logrus.InfoLevel {
+		t.Errorf("slog info+3: got %v, want %v", got, logrus.InfoLevel)
+	}
+}
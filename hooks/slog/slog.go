@@ -92,7 +92,7 @@ func (h *Hook) toSlogLevel(level logrus.Level) slog.Level {
 // Levels always returns all levels, since slog allows controlling level
 // enabling based on context.
 func (h *Hook) Levels() []logrus.Level {
-	return logrus.AllLevels
+	return logrus.Levels()
 }
 
 // Fire forwards the provided logrus Entry to the underlying slog.Logger's
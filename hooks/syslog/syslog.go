@@ -4,8 +4,8 @@ package syslog
 
 import (
 	"fmt"
+	"io"
 	"log/syslog"
-	"os"
 
 	"github.com/sirupsen/logrus"
 )
@@ -17,7 +17,58 @@ type SyslogHook struct {
 	SyslogRaddr   string
 }
 
-var _ logrus.Hook = (*SyslogHook)(nil)
+var (
+	_ logrus.Hook = (*SyslogHook)(nil)
+	_ io.Closer   = (*SyslogHook)(nil)
+)
+
+func init() {
+	logrus.RegisterHookFactory("syslog", newFromOptions)
+}
+
+// facilities are the names of the syslog facilities accepted by the
+// "syslog" hook factory.
+var facilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL,
+	"daemon": syslog.LOG_DAEMON, "auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG,
+	"lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS, "uucp": syslog.LOG_UUCP,
+	"cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3, "local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+// newFromOptions creates a SyslogHook from the options of a
+// [logrus.HookConfig] of type "syslog":
+//
+//	{"type": "syslog", "options": {"network": "udp", "address": "localhost:514", "tag": "app", "facility": "local0"}}
+//
+// Without network and address, the hook connects to the local syslog
+// server. The facility defaults to "user".
+func newFromOptions(options map[string]any) (logrus.Hook, error) {
+	var opts struct {
+		Network  string `json:"network"`
+		Address  string `json:"address"`
+		Tag      string `json:"tag"`
+		Facility string `json:"facility"`
+	}
+	if err := logrus.DecodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	facility := syslog.LOG_USER
+	if opts.Facility != "" {
+		f, ok := facilities[opts.Facility]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility %q", opts.Facility)
+		}
+		facility = f
+	}
+	hook, err := NewSyslogHook(opts.Network, opts.Address, facility|syslog.LOG_INFO, opts.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
 
 // NewSyslogHook creates a hook to be added to an instance of logger.
 //
@@ -35,28 +86,63 @@ func NewSyslogHook(network, raddr string, priority syslog.Priority, tag string)
 func (hook *SyslogHook) Fire(entry *logrus.Entry) error {
 	line, err := entry.String()
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Unable to read entry, %v", err)
+		_, _ = fmt.Fprintf(logrus.Stderr(), "Unable to read entry, %v", err)
 		return err
 	}
 
-	switch entry.Level {
-	case logrus.PanicLevel:
+	switch severity(entry.Level) {
+	case syslog.LOG_ALERT:
+		return hook.Writer.Alert(line)
+	case syslog.LOG_CRIT:
 		return hook.Writer.Crit(line)
-	case logrus.FatalLevel:
-		return hook.Writer.Crit(line)
-	case logrus.ErrorLevel:
+	case syslog.LOG_ERR:
 		return hook.Writer.Err(line)
-	case logrus.WarnLevel:
+	case syslog.LOG_WARNING:
 		return hook.Writer.Warning(line)
-	case logrus.InfoLevel:
+	case syslog.LOG_NOTICE:
+		return hook.Writer.Notice(line)
+	case syslog.LOG_INFO:
 		return hook.Writer.Info(line)
-	case logrus.DebugLevel, logrus.TraceLevel:
+	case syslog.LOG_DEBUG:
 		return hook.Writer.Debug(line)
 	default:
 		return nil
 	}
 }
 
+// severity returns the syslog severity of level, and -1 for unknown levels.
+// Levels registered with [logrus.RegisterLevel] use their
+// [logrus.CustomLevel.SyslogSeverity].
+func severity(level logrus.Level) syslog.Priority {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return syslog.LOG_CRIT
+	case logrus.ErrorLevel:
+		return syslog.LOG_ERR
+	case logrus.WarnLevel:
+		return syslog.LOG_WARNING
+	case logrus.InfoLevel:
+		return syslog.LOG_INFO
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return syslog.LOG_DEBUG
+	}
+	if c, ok := logrus.LookupLevel(level); ok {
+		if c.SyslogSeverity != 0 {
+			return syslog.Priority(c.SyslogSeverity)
+		}
+		return severity(level.Builtin())
+	}
+	return -1
+}
+
 func (hook *SyslogHook) Levels() []logrus.Level {
-	return logrus.AllLevels
+	return logrus.Levels()
+}
+
+// Close closes the connection to the syslog server.
+func (hook *SyslogHook) Close() error {
+	if hook.Writer == nil {
+		return nil
+	}
+	return hook.Writer.Close()
 }
@@ -7,7 +7,10 @@ package syslog_test
 import (
 	"io"
 	"log/syslog"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	lsyslog "github.com/sirupsen/logrus/hooks/syslog"
@@ -31,3 +34,87 @@ func TestLocalhostAddAndPrint(t *testing.T) {
 
 	log.Info("Congratulations!")
 }
+
+func TestCustomLevel(t *testing.T) {
+	notice, err := logrus.RegisterLevel(logrus.CustomLevel{
+		Name:           "notice",
+		Severity:       35,
+		SyslogSeverity: int(syslog.LOG_NOTICE),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err := logrus.RegisterLevel(logrus.CustomLevel{Name: "audit", Severity: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	hook, err := lsyslog.NewSyslogHook("udp", conn.LocalAddr().String(), syslog.LOG_LOCAL0, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	log.SetLevel(notice)
+	log.Hooks.Add(hook)
+
+	for _, tc := range []struct {
+		level logrus.Level
+		want  string
+	}{
+		{notice, "<133>"}, // local0.notice
+		{audit, "<130>"},  // local0.crit, as fatal
+	} {
+		log.Log(tc.level, "message")
+		buf := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); !strings.HasPrefix(got, tc.want) {
+			t.Errorf("%v: got %q, want prefix %q", tc.level, got, tc.want)
+		}
+	}
+}
+
+func TestConfigHook(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var cfg logrus.Config
+	err = cfg.LoadJSON([]byte(`{"hooks": [{"type": "syslog", "options": {
+		"network": "udp", "address": "` + conn.LocalAddr().String() + `", "tag": "app", "facility": "local0"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	if err := cfg.Apply(log); err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	log.Error("message")
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); !strings.HasPrefix(got, "<131>") || !strings.Contains(got, "app") {
+		t.Errorf("got %q, want local0.err from app", got)
+	}
+
+	cfg.Hooks[0].Options["facility"] = "nope"
+	if err := cfg.Apply(logrus.New()); err == nil {
+		t.Error("expected an error for an unknown facility")
+	}
+}
@@ -52,7 +52,7 @@ func (t *Hook) Fire(e *logrus.Entry) error {
 }
 
 func (t *Hook) Levels() []logrus.Level {
-	return logrus.AllLevels
+	return logrus.Levels()
 }
 
 // LastEntry returns the last entry that was logged or nil.
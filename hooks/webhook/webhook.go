@@ -0,0 +1,620 @@
+// Package webhook provides a hook posting alerts for severe entries to
+// chat incoming webhooks, such as those of Slack, Mattermost or Microsoft
+// Teams.
+//
+// Each alert is rendered into the JSON payload of a destination by a
+// template. Identical entries logged within a window are grouped into a
+// single alert, and the number of alerts per key is limited:
+//
+//	hook, err := webhook.New(&webhook.Options{
+//		Service: "payments",
+//		Link:    "https://grafana.example.com/explore?service={{.Service}}",
+//		Destinations: []webhook.Destination{
+//			{URL: slackURL, Template: webhook.SlackTemplate},
+//			{URL: pagerURL, Match: filter.LevelAtLeast(logrus.FatalLevel)},
+//		},
+//		GroupWindow: time.Minute,
+//		MaxAlerts:   10,
+//		RatePeriod:  time.Hour,
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer hook.Close(context.Background())
+//	logger.AddHook(hook)
+//
+// Alerts are posted by a background goroutine, so that logging does not
+// wait for the webhooks. Fatal and Panic entries are the exception: they are
+// never grouped, and their alerts are posted before logging returns, as the
+// program is about to exit or panic. The hooks also register an exit
+// handler with [logrus.RegisterExitHandler], so that open groups and queued
+// alerts are posted when the program exits through [logrus.Exit] or a Fatal
+// entry.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/filter"
+)
+
+// Templates for common incoming webhooks. They render the alert's Text and,
+// if set, its Link.
+const (
+	SlackTemplate = `{"text": {{if .Link}}{{json (printf "%s <%s|Details>" .Text .Link)}}{{else}}{{json .Text}}{{end}}}`
+
+	MattermostTemplate = `{"text": {{if .Link}}{{json (printf "%s [Details](%s)" .Text .Link)}}{{else}}{{json .Text}}{{end}}}`
+
+	TeamsTemplate = `{"@type": "MessageCard", "@context": "https://schema.org/extensions", ` +
+		`"summary": {{json .Text}}, "text": {{json .Text}}` +
+		`{{if .Link}}, "potentialAction": [{"@type": "OpenUri", "name": "Details", ` +
+		`"targets": [{"os": "default", "uri": {{json .Link}}}]}]{{end}}}`
+)
+
+const (
+	// DefaultQueueSize is the number of alerts waiting to be posted if
+	// Options.QueueSize is 0.
+	DefaultQueueSize = 100
+	// DefaultTimeout is the timeout of a request if Options.Client is nil.
+	DefaultTimeout = 10 * time.Second
+
+	// flushTimeout bounds the time Fatal and Panic entries and the exit
+	// handler wait for the alerts to be posted.
+	flushTimeout = 10 * time.Second
+)
+
+var (
+	// ErrClosed is returned by Fire after the hook has been closed.
+	ErrClosed = errors.New("webhook: hook closed")
+	// ErrQueueFull is passed to the error handler for alerts dropped
+	// because too many alerts are waiting to be posted.
+	ErrQueueFull = errors.New("webhook: queue full")
+)
+
+// Destination is a webhook alerts are posted to.
+type Destination struct {
+	// URL is the URL of the webhook.
+	URL string
+
+	// Match selects the alerts posted to the destination by their first
+	// entry. If nil, every alert is posted. Alerts are posted to every
+	// matching destination.
+	Match filter.Predicate
+
+	// Template is a text/template rendering an [Alert] into the request
+	// body. A "json" function encodes its argument as JSON. If empty,
+	// SlackTemplate is used.
+	Template string
+
+	// Headers are added to the requests.
+	Headers http.Header
+}
+
+// Options are options for a [Hook].
+type Options struct {
+	// Destinations are the webhooks alerts are posted to.
+	Destinations []Destination
+
+	// Levels are the levels of the entries alerted on. If nil, Error,
+	// Fatal and Panic entries are alerted on.
+	Levels []logrus.Level
+
+	// Service is the name of the originating service, available to
+	// templates as .Service and included in the alert's Text.
+	Service string
+
+	// Link is a text/template rendering an [Alert] into a link to more
+	// information, such as a dashboard or log search for the service.
+	// It is available to templates as .Link.
+	Link string
+
+	// Key returns the key of an entry. Entries with the same key are
+	// grouped and throttled together. If nil, the key consists of the
+	// level, the message and the value of the logrus.ErrorKey field.
+	Key func(entry *logrus.Entry) string
+
+	// GroupWindow is the time identical entries are collected after the
+	// first one before an alert with their count is posted. If 0, an alert
+	// is posted for every entry. Fatal and Panic entries are not grouped.
+	GroupWindow time.Duration
+
+	// MaxAlerts limits the number of alerts per key within RatePeriod,
+	// which must then be positive. Suppressed alerts are counted in the
+	// next alert for the key. If 0, alerts are not limited.
+	MaxAlerts  int
+	RatePeriod time.Duration
+
+	// QueueSize is the maximum number of alerts waiting to be posted. If 0,
+	// DefaultQueueSize is used.
+	QueueSize int
+
+	// Client sends the requests. If nil, a client with a timeout of
+	// DefaultTimeout is used.
+	Client *http.Client
+
+	// ErrorHandler is called when an alert cannot be posted. If nil,
+	// errors are printed to stderr.
+	ErrorHandler func(err error)
+}
+
+// Alert is the data passed to the templates.
+type Alert struct {
+	// Key is the key of the grouped entries.
+	Key string
+	// Level, Message and Data are those of the first entry.
+	Level   logrus.Level
+	Message string
+	Data    logrus.Fields
+	// Time is the time of the first entry and LastTime of the last one.
+	Time     time.Time
+	LastTime time.Time
+	// Count is the number of grouped entries.
+	Count int
+	// Suppressed is the number of entries with the same key whose alerts
+	// were suppressed by the rate limit since the previous alert.
+	Suppressed int
+	// Service is Options.Service and Link the rendered Options.Link.
+	Service string
+	Link    string
+	// Text is a one-line summary of the alert, such as
+	// "[ERROR] payments: charge failed error=timeout (3 times)".
+	Text string
+
+	entry *logrus.Entry
+}
+
+type destination struct {
+	Destination
+	tmpl *template.Template
+}
+
+// group collects identical entries during the group window.
+type group struct {
+	alert *Alert
+	timer *time.Timer
+}
+
+// limit counts the alerts of a key in the current rate period.
+type limit struct {
+	start      time.Time
+	alerts     int
+	suppressed int
+}
+
+// item is an alert to post, or a request to be notified once the alerts
+// queued before it have been posted.
+type item struct {
+	alert   *Alert
+	flushed chan struct{}
+}
+
+// maxLimits is the number of keys whose rate limits are kept before
+// expired ones are removed.
+const maxLimits = 1024
+
+// Hook posts alerts for entries to webhooks.
+type Hook struct {
+	opts  Options
+	dests []*destination
+	link  *template.Template
+
+	mu     sync.Mutex
+	closed bool
+	groups map[string]*group
+	limits map[string]*limit
+
+	queue chan item
+	done  chan struct{}
+
+	// ctx is canceled by Close to abort the request in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var _ logrus.Hook = (*Hook)(nil)
+
+var (
+	// hooks are the open hooks, flushed by the exit handler.
+	hooksMu      sync.Mutex
+	hooks        = make(map[*Hook]struct{})
+	registerExit sync.Once
+)
+
+// flushAll posts the pending alerts of the open hooks before the program
+// exits.
+func flushAll() {
+	hooksMu.Lock()
+	open := make([]*Hook, 0, len(hooks))
+	for h := range hooks {
+		open = append(open, h)
+	}
+	hooksMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	for _, h := range open {
+		if err := h.Flush(ctx); err != nil && !errors.Is(err, ErrClosed) {
+			h.opts.ErrorHandler(err)
+		}
+	}
+}
+
+// New returns a Hook and starts its background goroutine. New returns an
+// error if a template cannot be parsed or MaxAlerts is set without a
+// positive RatePeriod.
+//
+// New panics if opts is nil.
+func New(opts *Options) (*Hook, error) {
+	if opts == nil {
+		panic("cannot create webhook hook from nil options")
+	}
+	h := &Hook{
+		opts:   *opts,
+		groups: make(map[string]*group),
+		limits: make(map[string]*limit),
+		done:   make(chan struct{}),
+	}
+	if h.opts.MaxAlerts > 0 && h.opts.RatePeriod <= 0 {
+		return nil, errors.New("webhook: MaxAlerts requires a positive RatePeriod")
+	}
+	if h.opts.Levels == nil {
+		h.opts.Levels = []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+	}
+	if h.opts.Key == nil {
+		h.opts.Key = defaultKey
+	}
+	if h.opts.QueueSize <= 0 {
+		h.opts.QueueSize = DefaultQueueSize
+	}
+	if h.opts.Client == nil {
+		h.opts.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+	if h.opts.ErrorHandler == nil {
+		h.opts.ErrorHandler = func(err error) {
+			_, _ = fmt.Fprintln(logrus.Stderr(), "Failed to fire hook:", err)
+		}
+	}
+
+	for i, d := range h.opts.Destinations {
+		text := d.Template
+		if text == "" {
+			text = SlackTemplate
+		}
+		tmpl, err := parse(fmt.Sprintf("destination %d", i), text)
+		if err != nil {
+			return nil, err
+		}
+		h.dests = append(h.dests, &destination{Destination: d, tmpl: tmpl})
+	}
+	if h.opts.Link != "" {
+		tmpl, err := parse("link", h.opts.Link)
+		if err != nil {
+			return nil, err
+		}
+		h.link = tmpl
+	}
+
+	h.queue = make(chan item, h.opts.QueueSize)
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	go h.run()
+
+	registerExit.Do(func() { logrus.RegisterExitHandler(flushAll) })
+	hooksMu.Lock()
+	hooks[h] = struct{}{}
+	hooksMu.Unlock()
+	return h, nil
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: %w", err)
+	}
+	return tmpl, nil
+}
+
+func defaultKey(entry *logrus.Entry) string {
+	key := entry.Level.String() + "\x00" + entry.Message
+	if err, ok := entry.Data[logrus.ErrorKey]; ok {
+		key += "\x00" + fmt.Sprint(err)
+	}
+	return key
+}
+
+// Levels returns Options.Levels.
+func (h *Hook) Levels() []logrus.Level {
+	return h.opts.Levels
+}
+
+// Fire groups entry with identical entries, or queues an alert for it.
+// The alerts of Fatal and Panic entries are posted before Fire returns.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	key := h.opts.Key(entry)
+	urgent := entry.Level.AtLeast(logrus.FatalLevel)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrClosed
+	}
+	if g, ok := h.groups[key]; ok && !urgent {
+		g.alert.Count++
+		g.alert.LastTime = entry.Time
+		h.mu.Unlock()
+		return nil
+	}
+
+	alert := newAlert(key, entry)
+	if h.opts.GroupWindow > 0 && !urgent {
+		h.groups[key] = &group{
+			alert: alert,
+			timer: time.AfterFunc(h.opts.GroupWindow, func() {
+				h.release(key)
+			}),
+		}
+		h.mu.Unlock()
+		return nil
+	}
+	err := h.emit(alert)
+	h.mu.Unlock()
+	if err != nil || !urgent {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	return h.Flush(ctx)
+}
+
+func newAlert(key string, entry *logrus.Entry) *Alert {
+	dup := entry.Dup()
+	dup.Level = entry.Level
+	dup.Message = entry.Message
+	return &Alert{
+		Key:      key,
+		Level:    entry.Level,
+		Message:  entry.Message,
+		Data:     dup.Data,
+		Time:     entry.Time,
+		LastTime: entry.Time,
+		Count:    1,
+		entry:    dup,
+	}
+}
+
+// release queues the alert of a group when its window ends.
+func (h *Hook) release(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.groups[key]
+	if !ok {
+		return
+	}
+	delete(h.groups, key)
+	if err := h.emit(g.alert); err != nil {
+		go h.opts.ErrorHandler(err)
+	}
+}
+
+// emit applies the rate limit and queues alert. It must be called with
+// h.mu held.
+func (h *Hook) emit(alert *Alert) error {
+	if h.opts.MaxAlerts > 0 {
+		now := time.Now()
+		l, ok := h.limits[alert.Key]
+		if !ok {
+			if len(h.limits) >= maxLimits {
+				h.pruneLimits(now)
+			}
+			l = &limit{start: now}
+			h.limits[alert.Key] = l
+		} else if now.Sub(l.start) >= h.opts.RatePeriod {
+			l.start, l.alerts = now, 0
+		}
+		if l.alerts >= h.opts.MaxAlerts {
+			l.suppressed += alert.Count
+			return nil
+		}
+		l.alerts++
+		alert.Suppressed, l.suppressed = l.suppressed, 0
+	}
+
+	select {
+	case h.queue <- item{alert: alert}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// emitGroups queues the alerts of the open groups. It must be called with
+// h.mu held.
+func (h *Hook) emitGroups() {
+	for key, g := range h.groups {
+		g.timer.Stop()
+		delete(h.groups, key)
+		if err := h.emit(g.alert); err != nil {
+			h.opts.ErrorHandler(err)
+		}
+	}
+}
+
+// pruneLimits removes the limits of expired rate periods. Suppressed
+// counts of removed keys are lost.
+func (h *Hook) pruneLimits(now time.Time) {
+	for key, l := range h.limits {
+		if now.Sub(l.start) >= h.opts.RatePeriod {
+			delete(h.limits, key)
+		}
+	}
+}
+
+// run posts the queued alerts.
+func (h *Hook) run() {
+	defer close(h.done)
+	for it := range h.queue {
+		if it.flushed != nil {
+			close(it.flushed)
+			continue
+		}
+		alert := it.alert
+		h.complete(alert)
+		for _, d := range h.dests {
+			if !d.Match.Match(alert.entry) {
+				continue
+			}
+			if err := h.post(d, alert); err != nil {
+				h.opts.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// complete sets the Service, Link and Text of alert.
+func (h *Hook) complete(alert *Alert) {
+	alert.Service = h.opts.Service
+	if h.link != nil {
+		var b strings.Builder
+		if err := h.link.Execute(&b, alert); err != nil {
+			h.opts.ErrorHandler(fmt.Errorf("webhook: rendering link: %w", err))
+		} else {
+			alert.Link = b.String()
+		}
+	}
+	alert.Text = text(alert)
+}
+
+// text returns the summary of alert.
+func text(alert *Alert) string {
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(strings.ToUpper(alert.Level.String()))
+	b.WriteString("] ")
+	if alert.Service != "" {
+		b.WriteString(alert.Service)
+		b.WriteString(": ")
+	}
+	b.WriteString(alert.Message)
+
+	keys := make([]string, 0, len(alert.Data))
+	for k := range alert.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, alert.Data[k])
+	}
+
+	var notes []string
+	if alert.Count > 1 {
+		notes = append(notes, fmt.Sprintf("%d times in %s", alert.Count, alert.LastTime.Sub(alert.Time).Round(time.Second)))
+	}
+	if alert.Suppressed > 0 {
+		notes = append(notes, fmt.Sprintf("%d more suppressed", alert.Suppressed))
+	}
+	if len(notes) > 0 {
+		b.WriteString(" (")
+		b.WriteString(strings.Join(notes, ", "))
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+func (h *Hook) post(d *destination, alert *Alert) error {
+	var body bytes.Buffer
+	if err := d.tmpl.Execute(&body, alert); err != nil {
+		return fmt.Errorf("webhook: rendering alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodPost, d.URL, &body)
+	if err != nil {
+		return err
+	}
+	for k, v := range d.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Flush queues the alerts of the open groups and waits until the queued
+// alerts have been posted. It returns ctx.Err() if ctx is done first.
+func (h *Hook) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrClosed
+	}
+	h.emitGroups()
+	select {
+	case h.queue <- item{flushed: flushed}:
+	case <-ctx.Done():
+		h.mu.Unlock()
+		return ctx.Err()
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting entries, queues the alerts of the open groups and
+// waits until the queued alerts have been posted. If ctx is done first, it
+// aborts the request in flight, drops the remaining alerts and returns
+// ctx.Err().
+func (h *Hook) Close(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		h.emitGroups()
+		close(h.queue)
+
+		hooksMu.Lock()
+		delete(hooks, h)
+		hooksMu.Unlock()
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-h.done:
+		h.cancel()
+		return nil
+	case <-ctx.Done():
+		h.cancel()
+		return ctx.Err()
+	}
+}
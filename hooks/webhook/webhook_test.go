@@ -0,0 +1,233 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/filter"
+	"github.com/sirupsen/logrus/hooks/webhook"
+)
+
+// receiver records the JSON payloads posted to it.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	payloads []map[string]any
+}
+
+func newReceiver(t *testing.T) *receiver {
+	r := &receiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload map[string]any
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		r.payloads = append(r.payloads, payload)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.payloads
+}
+
+func newHook(t *testing.T, opts *webhook.Options) (*webhook.Hook, *logrus.Logger) {
+	t.Helper()
+	opts.ErrorHandler = func(err error) { t.Error(err) }
+	hook, err := webhook.New(opts)
+	require.NoError(t, err)
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+	return hook, logger
+}
+
+func closeHook(t *testing.T, hook *webhook.Hook) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, hook.Close(ctx))
+}
+
+func TestSlack(t *testing.T) {
+	r := newReceiver(t)
+	hook, logger := newHook(t, &webhook.Options{
+		Service:      "payments",
+		Link:         "https://logs.example.com/?service={{.Service}}",
+		Destinations: []webhook.Destination{{URL: r.URL}},
+	})
+	logger.Info("not alerted")
+	logger.WithError(errors.New("timeout")).Error("charge failed")
+	closeHook(t, hook)
+
+	payloads := r.received()
+	require.Len(t, payloads, 1)
+	assert.Equal(t,
+		"[ERROR] payments: charge failed error=timeout <https://logs.example.com/?service=payments|Details>",
+		payloads[0]["text"])
+}
+
+func TestTemplates(t *testing.T) {
+	teams, mattermost, custom := newReceiver(t), newReceiver(t), newReceiver(t)
+	hook, logger := newHook(t, &webhook.Options{
+		Link: "https://example.com",
+		Destinations: []webhook.Destination{
+			{URL: teams.URL, Template: webhook.TeamsTemplate},
+			{URL: mattermost.URL, Template: webhook.MattermostTemplate},
+			{URL: custom.URL, Template: `{"msg": {{json .Message}}, "user": {{json .Data.user}}, "count": {{.Count}}}`},
+		},
+	})
+	logger.WithField("user", `al"ice`).Error("denied")
+	closeHook(t, hook)
+
+	require.Len(t, teams.received(), 1)
+	assert.Equal(t, "MessageCard", teams.received()[0]["@type"])
+	assert.Equal(t, `[ERROR] denied user=al"ice`, teams.received()[0]["text"])
+	require.Len(t, mattermost.received(), 1)
+	assert.Equal(t, `[ERROR] denied user=al"ice [Details](https://example.com)`, mattermost.received()[0]["text"])
+	assert.Equal(t, []map[string]any{{"msg": "denied", "user": `al"ice`, "count": 1.0}}, custom.received())
+}
+
+func TestDestinationMatch(t *testing.T) {
+	all, fatal, payments := newReceiver(t), newReceiver(t), newReceiver(t)
+	hook, logger := newHook(t, &webhook.Options{
+		Destinations: []webhook.Destination{
+			{URL: all.URL},
+			{URL: fatal.URL, Match: filter.LevelAtLeast(logrus.FatalLevel)},
+			{URL: payments.URL, Match: filter.FieldEquals("component", "payments")},
+		},
+	})
+	logger.Error("one")
+	logger.WithField("component", "payments").Error("two")
+	require.NoError(t, hook.Fire(&logrus.Entry{Logger: logger, Level: logrus.FatalLevel, Message: "three"}))
+	closeHook(t, hook)
+
+	assert.Len(t, all.received(), 3)
+	assert.Len(t, fatal.received(), 1)
+	assert.Len(t, payments.received(), 1)
+}
+
+func TestGroupWindow(t *testing.T) {
+	r := newReceiver(t)
+	hook, logger := newHook(t, &webhook.Options{
+		Destinations: []webhook.Destination{{URL: r.URL, Template: `{"msg": {{json .Message}}, "count": {{.Count}}}`}},
+		GroupWindow:  50 * time.Millisecond,
+	})
+	for range 3 {
+		logger.Error("disk full")
+	}
+	logger.Error("other")
+	require.Eventually(t, func() bool { return len(r.received()) == 2 }, 5*time.Second, 5*time.Millisecond)
+
+	logger.Error("disk full")
+	closeHook(t, hook)
+
+	assert.ElementsMatch(t, []map[string]any{
+		{"msg": "disk full", "count": 3.0},
+		{"msg": "other", "count": 1.0},
+		{"msg": "disk full", "count": 1.0},
+	}, r.received())
+}
+
+func TestRateLimit(t *testing.T) {
+	r := newReceiver(t)
+	hook, logger := newHook(t, &webhook.Options{
+		Destinations: []webhook.Destination{{URL: r.URL, Template: `{"msg": {{json .Message}}, "suppressed": {{.Suppressed}}}`}},
+		MaxAlerts:    2,
+		RatePeriod:   50 * time.Millisecond,
+	})
+	for range 5 {
+		logger.Error("flapping")
+	}
+	logger.Error("other")
+	time.Sleep(60 * time.Millisecond)
+	logger.Error("flapping")
+	closeHook(t, hook)
+
+	assert.Equal(t, []map[string]any{
+		{"msg": "flapping", "suppressed": 0.0},
+		{"msg": "flapping", "suppressed": 0.0},
+		{"msg": "other", "suppressed": 0.0},
+		{"msg": "flapping", "suppressed": 3.0},
+	}, r.received())
+}
+
+func TestInvalidOptions(t *testing.T) {
+	_, err := webhook.New(&webhook.Options{
+		Destinations: []webhook.Destination{{URL: "http://localhost", Template: "{{.Message"}},
+	})
+	assert.Error(t, err)
+
+	_, err = webhook.New(&webhook.Options{MaxAlerts: 1})
+	assert.Error(t, err, "MaxAlerts requires RatePeriod")
+}
+
+func TestFatal(t *testing.T) {
+	r := newReceiver(t)
+	hook, logger := newHook(t, &webhook.Options{
+		Destinations: []webhook.Destination{{URL: r.URL, Template: `{"msg": {{json .Message}}}`}},
+		GroupWindow:  time.Hour,
+	})
+	defer closeHook(t, hook)
+	logger.ExitFunc = func(int) {}
+
+	// The grouped error is posted by the exit handler, and the fatal entry
+	// before logging returns.
+	logger.Error("disk full")
+	logger.Fatal("giving up")
+	assert.ElementsMatch(t, []map[string]any{{"msg": "disk full"}, {"msg": "giving up"}}, r.received())
+}
+
+func TestCloseAbortsRequest(t *testing.T) {
+	unblock := make(chan struct{})
+	r := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.Copy(io.Discard, req.Body)
+		<-unblock
+	}))
+	defer r.Close()
+	defer close(unblock)
+
+	errs := make(chan error, 1)
+	hook, err := webhook.New(&webhook.Options{
+		Destinations: []webhook.Destination{{URL: r.URL}},
+		ErrorHandler: func(err error) { errs <- err },
+	})
+	require.NoError(t, err)
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+	logger.Error("stuck")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, hook.Close(ctx), context.DeadlineExceeded)
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request was not aborted")
+	}
+}
+
+func TestFireAfterClose(t *testing.T) {
+	hook, logger := newHook(t, &webhook.Options{})
+	closeHook(t, hook)
+	assert.ErrorIs(t, hook.Fire(logrus.NewEntry(logger)), webhook.ErrClosed)
+}
@@ -0,0 +1,138 @@
+package httplog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// clfTimeFormat is the timestamp layout of the Common Log Format.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// defaultFallback formats entries that are not access log lines when
+// CLFFormatter.Fallback is nil.
+var defaultFallback logrus.Formatter = new(logrus.TextFormatter)
+
+// CLFFormatter formats access log lines written by [Handler] in the Apache
+// Common Log Format:
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+//
+// or, when Combined is set, in the Combined Log Format, which appends the
+// quoted referer and user agent.
+//
+// Entries that do not carry a status field, such as messages logged through
+// the request-scoped entry, are formatted with Fallback instead.
+type CLFFormatter struct {
+	// Combined selects the Combined Log Format.
+	Combined bool
+
+	// Fallback formats entries that are not access log lines. If nil, a
+	// [logrus.TextFormatter] is used.
+	Fallback logrus.Formatter
+}
+
+var _ logrus.Formatter = (*CLFFormatter)(nil)
+
+// Format renders a single log entry.
+func (f *CLFFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	status, ok := entry.Data[FieldKeyStatus].(int)
+	if !ok {
+		fallback := f.Fallback
+		if fallback == nil {
+			fallback = defaultFallback
+		}
+		return fallback.Format(entry)
+	}
+
+	b := entry.Buffer
+	if b == nil {
+		b = new(bytes.Buffer)
+	}
+
+	// The Common Log Format records when the request was received.
+	start := entry.Time
+	if d, ok := entry.Data[FieldKeyDuration].(time.Duration); ok {
+		start = start.Add(-d)
+	}
+
+	writeCLFField(b, stringField(entry, FieldKeyRemoteIP))
+	b.WriteString(" - ")
+	writeCLFField(b, stringField(entry, FieldKeyUser))
+	b.WriteString(" [")
+	b.WriteString(start.Format(clfTimeFormat))
+	b.WriteString("] \"")
+	writeCLFEscaped(b, stringField(entry, FieldKeyMethod))
+	b.WriteByte(' ')
+	uri := stringField(entry, FieldKeyURI)
+	if uri == "" {
+		uri = stringField(entry, FieldKeyPath)
+	}
+	writeCLFEscaped(b, uri)
+	b.WriteByte(' ')
+	writeCLFEscaped(b, stringField(entry, FieldKeyProto))
+	b.WriteString("\" ")
+	b.WriteString(strconv.Itoa(status))
+	b.WriteByte(' ')
+	if n, _ := entry.Data[FieldKeyBytes].(int64); n > 0 {
+		b.WriteString(strconv.FormatInt(n, 10))
+	} else {
+		b.WriteByte('-')
+	}
+
+	if f.Combined {
+		b.WriteString(" \"")
+		writeCLFEscaped(b, stringField(entry, FieldKeyReferer))
+		b.WriteString("\" \"")
+		writeCLFEscaped(b, stringField(entry, FieldKeyUserAgent))
+		b.WriteByte('"')
+	}
+
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// stringField returns the string value of the field key of entry, or the
+// empty string if it is missing.
+func stringField(entry *logrus.Entry, key string) string {
+	switch v := entry.Data[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeCLFField writes s, or "-" if s is empty.
+func writeCLFField(b *bytes.Buffer, s string) {
+	if s == "" {
+		b.WriteByte('-')
+		return
+	}
+	writeCLFEscaped(b, s)
+}
+
+// writeCLFEscaped writes s escaping quotes, backslashes and non-printable
+// bytes the way Apache does.
+func writeCLFEscaped(b *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	for i := range len(s) {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			b.WriteString(`\x`)
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package httplog_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/httplog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLFFormatter(t *testing.T) {
+	entry := &logrus.Entry{
+		Time: time.Date(2000, time.October, 10, 13, 55, 37, 0, time.FixedZone("", -7*60*60)),
+		Data: logrus.Fields{
+			httplog.FieldKeyRemoteIP:  "127.0.0.1",
+			httplog.FieldKeyUser:      "frank",
+			httplog.FieldKeyMethod:    "GET",
+			httplog.FieldKeyURI:       "/apache_pb.gif",
+			httplog.FieldKeyProto:     "HTTP/1.0",
+			httplog.FieldKeyStatus:    200,
+			httplog.FieldKeyBytes:     int64(2326),
+			httplog.FieldKeyDuration:  time.Second,
+			httplog.FieldKeyReferer:   "http://www.example.com/start.html",
+			httplog.FieldKeyUserAgent: "Mozilla/4.08",
+		},
+	}
+
+	b, err := (&httplog.CLFFormatter{}).Format(entry)
+	require.NoError(t, err)
+	assert.Equal(t, `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`+"\n", string(b))
+
+	b, err = (&httplog.CLFFormatter{Combined: true}).Format(entry)
+	require.NoError(t, err)
+	assert.Equal(t, `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`+"\n", string(b))
+}
+
+func TestCLFFormatterEmptyValues(t *testing.T) {
+	entry := &logrus.Entry{
+		Time: time.Date(2000, time.October, 10, 13, 55, 36, 0, time.UTC),
+		Data: logrus.Fields{
+			httplog.FieldKeyMethod: "HEAD",
+			httplog.FieldKeyPath:   "/",
+			httplog.FieldKeyProto:  "HTTP/1.1",
+			httplog.FieldKeyStatus: 304,
+			httplog.FieldKeyBytes:  int64(0),
+		},
+	}
+
+	b, err := (&httplog.CLFFormatter{Combined: true}).Format(entry)
+	require.NoError(t, err)
+	assert.Equal(t, `- - - [10/Oct/2000:13:55:36 +0000] "HEAD / HTTP/1.1" 304 - "" ""`+"\n", string(b))
+}
+
+func TestCLFFormatterFallback(t *testing.T) {
+	entry := &logrus.Entry{
+		Logger:  logrus.New(),
+		Message: "not an access line",
+		Level:   logrus.InfoLevel,
+	}
+
+	b, err := (&httplog.CLFFormatter{Fallback: &logrus.JSONFormatter{DisableTimestamp: true}}).Format(entry)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"level":"info","msg":"not an access line"}`, string(b))
+}
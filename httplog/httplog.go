@@ -0,0 +1,61 @@
+// Package httplog provides net/http integration for Logrus.
+//
+// [NewHandler] wraps an [http.Handler] so that every request gets a
+// request-scoped [logrus.Entry] stored in its context and an access log line
+// is written once the handler returns. Handlers retrieve the request-scoped
+// entry with [FromContext] or [FromRequest].
+//
+// [NewTransport] wraps an [http.RoundTripper] so that outgoing requests are
+// logged, using the request-scoped entry when the request context carries
+// one.
+package httplog
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/internal/entrycontext"
+)
+
+// Field keys used for request-scoped and access log fields.
+const (
+	FieldKeyMethod    = "method"
+	FieldKeyPath      = "path"
+	FieldKeyURI       = "uri"
+	FieldKeyProto     = "proto"
+	FieldKeyRemoteIP  = "remote_ip"
+	FieldKeyRequestID = "request_id"
+	FieldKeyUserAgent = "user_agent"
+	FieldKeyReferer   = "referer"
+	FieldKeyUser      = "user"
+	FieldKeyStatus    = "status"
+	FieldKeyBytes     = "bytes"
+	FieldKeyDuration  = "duration"
+	FieldKeyPanic     = "panic"
+	FieldKeyStack     = "stack"
+)
+
+// DefaultRequestIDHeader is the header used to read and propagate request IDs
+// when no header is configured.
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// NewContext returns a copy of ctx that carries entry.
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return entrycontext.New(ctx, entry)
+}
+
+// FromContext returns the entry stored in ctx by [NewContext], if any.
+func FromContext(ctx context.Context) (*logrus.Entry, bool) {
+	return entrycontext.From(ctx)
+}
+
+// FromRequest returns the request-scoped entry of r. If r carries no entry,
+// a new entry for fallback is returned instead, with its context set to the
+// request context.
+func FromRequest(r *http.Request, fallback *logrus.Logger) *logrus.Entry {
+	if entry, ok := FromContext(r.Context()); ok {
+		return entry
+	}
+	return fallback.WithContext(r.Context())
+}
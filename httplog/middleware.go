@@ -0,0 +1,334 @@
+package httplog
+
+import (
+	"bufio"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Options are options for a [Handler].
+// A zero Options consists entirely of default values.
+type Options struct {
+	// RequestIDHeader is the header the request ID is read from. If the
+	// request does not carry one, a new ID is generated. The ID is also set
+	// on the response. Defaults to [DefaultRequestIDHeader].
+	RequestIDHeader string
+
+	// GenerateRequestID returns a new request ID. If nil, a random 128-bit
+	// hex-encoded ID is used.
+	GenerateRequestID func() string
+
+	// TrustProxyHeaders makes the remote IP honor the X-Forwarded-For and
+	// X-Real-IP headers. Only enable it behind a trusted reverse proxy.
+	TrustProxyHeaders bool
+
+	// LevelFunc returns the level of the access log line for a response
+	// status. If nil, 5xx responses are logged at [logrus.ErrorLevel], 4xx
+	// responses at [logrus.WarnLevel] and everything else at
+	// [logrus.InfoLevel]. Recovered panics are always logged at
+	// [logrus.ErrorLevel].
+	LevelFunc func(status int) logrus.Level
+
+	// SkipPaths lists request paths, such as health checks, for which no
+	// access log line is written. The request-scoped entry is still
+	// available to the handler.
+	SkipPaths []string
+
+	// Skip reports whether the access log line for r should be omitted.
+	// It is consulted in addition to SkipPaths.
+	Skip func(r *http.Request) bool
+
+	// SampleRate is the fraction, between 0 and 1, of access log lines
+	// logged at a level less severe than [logrus.WarnLevel] that are
+	// written. Warnings and errors are never sampled. Zero disables
+	// sampling, which writes every line.
+	SampleRate float64
+
+	// AccessLogger receives the access log lines. If nil, the logger given
+	// to [NewHandler] is used. Set it to a logger using [CLFFormatter] to
+	// write Apache Common or Combined Log Format lines instead of
+	// structured fields.
+	AccessLogger *logrus.Logger
+
+	// Message is the message of the access log line. Defaults to
+	// "request completed".
+	Message string
+}
+
+// Handler is an [http.Handler] middleware that attaches a request-scoped
+// [logrus.Entry] to every request and writes an access log line after the
+// wrapped handler returns.
+//
+// The request-scoped entry carries the method, path, remote IP, request ID
+// and user agent of the request. The access log line adds the response
+// status, the number of body bytes written and the request duration.
+type Handler struct {
+	next   http.Handler
+	logger *logrus.Logger
+	opts   Options
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// NewHandler returns a [Handler] that logs requests to next through logger.
+//
+// The provided logger must not be nil. NewHandler panics if logger is nil.
+// If opts is nil, the default options are used.
+func NewHandler(logger *logrus.Logger, next http.Handler, opts *Options) *Handler {
+	if logger == nil {
+		panic("cannot create handler from nil logger")
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	h := &Handler{
+		next:   next,
+		logger: logger,
+		opts:   *opts,
+	}
+	if h.opts.RequestIDHeader == "" {
+		h.opts.RequestIDHeader = DefaultRequestIDHeader
+	}
+	if h.opts.GenerateRequestID == nil {
+		h.opts.GenerateRequestID = newRequestID
+	}
+	if h.opts.LevelFunc == nil {
+		h.opts.LevelFunc = statusLevel
+	}
+	if h.opts.AccessLogger == nil {
+		h.opts.AccessLogger = logger
+	}
+	if h.opts.Message == "" {
+		h.opts.Message = "request completed"
+	}
+	return h
+}
+
+// Middleware returns a function that wraps handlers with [NewHandler].
+func Middleware(logger *logrus.Logger, opts *Options) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return NewHandler(logger, next, opts)
+	}
+}
+
+// ServeHTTP implements [http.Handler].
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	requestID := r.Header.Get(h.opts.RequestIDHeader)
+	if requestID == "" {
+		requestID = h.opts.GenerateRequestID()
+	}
+	w.Header().Set(h.opts.RequestIDHeader, requestID)
+
+	fields := logrus.Fields{
+		FieldKeyMethod:    r.Method,
+		FieldKeyPath:      r.URL.Path,
+		FieldKeyRemoteIP:  h.remoteIP(r),
+		FieldKeyRequestID: requestID,
+	}
+	if ua := r.UserAgent(); ua != "" {
+		fields[FieldKeyUserAgent] = ua
+	}
+	entry := h.logger.WithContext(r.Context()).WithFields(fields)
+	r = r.WithContext(NewContext(r.Context(), entry))
+
+	rw := &responseWriter{ResponseWriter: w}
+	defer func() {
+		rec := recover()
+		if rec != nil && rec == http.ErrAbortHandler { //nolint:errorlint // sentinel panic value
+			panic(rec)
+		}
+		if rec != nil && !rw.wroteHeader {
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		h.logAccess(entry, r, rw, time.Since(start), rec)
+	}()
+	h.next.ServeHTTP(rw, r)
+}
+
+// logAccess writes the access log line for a finished request. rec is the
+// value recovered from a panicking handler, if any.
+func (h *Handler) logAccess(entry *logrus.Entry, r *http.Request, rw *responseWriter, d time.Duration, rec any) {
+	status := rw.Status()
+	level := h.opts.LevelFunc(status)
+
+	if rec == nil {
+		if h.skip(r) || !h.sampled(level) {
+			return
+		}
+	} else {
+		level = logrus.ErrorLevel
+	}
+
+	if entry.Logger != h.opts.AccessLogger {
+		entry = h.opts.AccessLogger.WithContext(entry.Context).WithFields(entry.Data)
+	}
+	if !entry.Logger.IsLevelEnabled(level) {
+		return
+	}
+
+	fields := logrus.Fields{
+		FieldKeyStatus:   status,
+		FieldKeyBytes:    rw.bytes,
+		FieldKeyDuration: d,
+		FieldKeyProto:    r.Proto,
+		FieldKeyURI:      r.RequestURI,
+	}
+	if referer := r.Referer(); referer != "" {
+		fields[FieldKeyReferer] = referer
+	}
+	if user := requestUser(r); user != "" {
+		fields[FieldKeyUser] = user
+	}
+	if rec != nil {
+		fields[FieldKeyPanic] = fmt.Sprint(rec)
+		fields[FieldKeyStack] = string(debug.Stack())
+	}
+	entry.WithFields(fields).Log(level, h.opts.Message)
+}
+
+func (h *Handler) skip(r *http.Request) bool {
+	if slices.Contains(h.opts.SkipPaths, r.URL.Path) {
+		return true
+	}
+	return h.opts.Skip != nil && h.opts.Skip(r)
+}
+
+func (h *Handler) sampled(level logrus.Level) bool {
+	if h.opts.SampleRate <= 0 || h.opts.SampleRate >= 1 || level.AtLeast(logrus.WarnLevel) {
+		return true
+	}
+	return rand.Float64() < h.opts.SampleRate
+}
+
+// remoteIP returns the client IP of r, honoring proxy headers if configured.
+func (h *Handler) remoteIP(r *http.Request) string {
+	if h.opts.TrustProxyHeaders {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			ip, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(ip)
+		}
+		if ip := r.Header.Get("X-Real-Ip"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestUser returns the user name from basic auth or the URL, if any.
+func requestUser(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+	if r.URL.User != nil {
+		return r.URL.User.Username()
+	}
+	return ""
+}
+
+// statusLevel is the default [Options.LevelFunc].
+func statusLevel(status int) logrus.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return logrus.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return logrus.WarnLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
+
+// newRequestID returns a random 128-bit hex-encoded ID.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = crand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// responseWriter records the status and body size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// Status returns the response status, defaulting to 200 if the handler
+// never wrote a header.
+func (rw *responseWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		// Informational responses are not final.
+		rw.wroteHeader = status >= http.StatusOK || status == http.StatusSwitchingProtocols
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom lets io.Copy use the underlying writer's optimized path.
+func (rw *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := io.Copy(rw.ResponseWriter, r)
+	rw.bytes += n
+	return n, err
+}
+
+// Flush implements [http.Flusher] if the underlying writer supports it.
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Hijack implements [http.Hijacker] if the underlying writer supports it.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("httplog: underlying ResponseWriter does not implement http.Hijacker")
+	}
+	if !rw.wroteHeader {
+		rw.status = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+	return hj.Hijack()
+}
+
+// Unwrap returns the underlying writer for [http.ResponseController].
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,192 @@
+package httplog_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/sirupsen/logrus/httplog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerLogsAccess(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+
+	var scoped *logrus.Entry
+	h := httplog.NewHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		scoped, ok = httplog.FromContext(r.Context())
+		require.True(t, ok)
+		scoped.Info("handling")
+		_, _ = io.WriteString(w, "hello")
+	}), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/things?id=1", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set(httplog.DefaultRequestIDHeader, "abc")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "abc", rec.Header().Get(httplog.DefaultRequestIDHeader))
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "handling", entries[0].Message)
+	assert.Equal(t, "abc", entries[0].Data[httplog.FieldKeyRequestID])
+	assert.Equal(t, "/things", entries[0].Data[httplog.FieldKeyPath])
+	assert.Equal(t, "192.0.2.1", entries[0].Data[httplog.FieldKeyRemoteIP])
+	assert.Equal(t, "test-agent", entries[0].Data[httplog.FieldKeyUserAgent])
+	assert.NotNil(t, entries[0].Context)
+
+	access := entries[1]
+	assert.Equal(t, logrus.InfoLevel, access.Level)
+	assert.Equal(t, "request completed", access.Message)
+	assert.Equal(t, http.StatusOK, access.Data[httplog.FieldKeyStatus])
+	assert.Equal(t, int64(5), access.Data[httplog.FieldKeyBytes])
+	assert.Equal(t, "/things?id=1", access.Data[httplog.FieldKeyURI])
+	assert.Equal(t, "abc", access.Data[httplog.FieldKeyRequestID])
+	assert.IsType(t, time.Duration(0), access.Data[httplog.FieldKeyDuration])
+}
+
+func TestHandlerGeneratesRequestID(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	h := httplog.NewHandler(logger, http.NotFoundHandler(), &httplog.Options{
+		RequestIDHeader:   "X-Trace",
+		GenerateRequestID: func() string { return "generated" },
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, "generated", rec.Header().Get("X-Trace"))
+	require.NotNil(t, hook.LastEntry())
+	assert.Equal(t, "generated", hook.LastEntry().Data[httplog.FieldKeyRequestID])
+}
+
+func TestHandlerLevelByStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		level  logrus.Level
+	}{
+		{http.StatusOK, logrus.InfoLevel},
+		{http.StatusFound, logrus.InfoLevel},
+		{http.StatusNotFound, logrus.WarnLevel},
+		{http.StatusServiceUnavailable, logrus.ErrorLevel},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			h := httplog.NewHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}), nil)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			require.NotNil(t, hook.LastEntry())
+			assert.Equal(t, tt.level, hook.LastEntry().Level)
+			assert.Equal(t, tt.status, hook.LastEntry().Data[httplog.FieldKeyStatus])
+		})
+	}
+}
+
+func TestHandlerRecoversPanics(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	h := httplog.NewHandler(logger, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}), nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, logrus.ErrorLevel, entry.Level)
+	assert.Equal(t, "boom", entry.Data[httplog.FieldKeyPanic])
+	assert.Contains(t, entry.Data[httplog.FieldKeyStack], "TestHandlerRecoversPanics")
+}
+
+func TestHandlerSkipPaths(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	h := httplog.NewHandler(logger, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), &httplog.Options{
+		SkipPaths: []string{"/healthz"},
+		Skip: func(r *http.Request) bool {
+			return r.Method == http.MethodOptions
+		},
+	})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodOptions, "/", nil))
+	assert.Empty(t, hook.AllEntries())
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Len(t, hook.AllEntries(), 1)
+}
+
+func TestHandlerSampling(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	status := http.StatusOK
+	h := httplog.NewHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}), &httplog.Options{SampleRate: 0.000001})
+
+	for range 100 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	assert.Empty(t, hook.AllEntries(), "successful requests should be sampled")
+
+	status = http.StatusInternalServerError
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Len(t, hook.AllEntries(), 1, "errors must never be sampled")
+}
+
+func TestHandlerTrustProxyHeaders(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	h := httplog.NewHandler(logger, http.NotFoundHandler(), &httplog.Options{TrustProxyHeaders: true})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NotNil(t, hook.LastEntry())
+	assert.Equal(t, "203.0.113.7", hook.LastEntry().Data[httplog.FieldKeyRemoteIP])
+}
+
+func TestHandlerAccessLoggerCLF(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+
+	var buf bytes.Buffer
+	access := logrus.New()
+	access.SetOutput(&buf)
+	access.SetFormatter(&httplog.CLFFormatter{Combined: true})
+
+	srv := httptest.NewServer(httplog.NewHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httplog.FromRequest(r, logger).Info("app log")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, "created")
+	}), &httplog.Options{AccessLogger: access}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/items?x=1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Referer", "http://example.com/")
+	req.Header.Set("User-Agent", `agent "quoted"`)
+	req.SetBasicAuth("frank", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	require.Len(t, hook.AllEntries(), 1, "access line must go to the access logger only")
+	assert.Equal(t, "app log", hook.LastEntry().Message)
+
+	line := buf.String()
+	assert.Regexp(t, `^127\.0\.0\.1 - frank \[[^\]]+\] "POST /items\?x=1 HTTP/1\.1" 201 7 "http://example.com/" "agent \\"quoted\\""\n$`, line)
+	assert.False(t, strings.Contains(line, "secret"))
+}
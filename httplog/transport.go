@@ -0,0 +1,423 @@
+package httplog
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Field keys used by [Transport] in addition to the request-scoped keys.
+const (
+	FieldKeyHost            = "host"
+	FieldKeyRetries         = "retries"
+	FieldKeyRequestHeaders  = "request_headers"
+	FieldKeyResponseHeaders = "response_headers"
+	FieldKeyRequestBody     = "request_body"
+	FieldKeyResponseBody    = "response_body"
+)
+
+// DefaultMaxBodySize is the number of body bytes captured when
+// [TransportOptions.MaxBodySize] is zero.
+const DefaultMaxBodySize = 4 << 10
+
+// redacted replaces the value of redacted headers.
+const redacted = "[REDACTED]"
+
+// truncatedSuffix marks captured bodies that were cut at the size limit.
+const truncatedSuffix = "...(truncated)"
+
+// maxRetryBackoff caps the doubling delay between retries, unless
+// TransportOptions.RetryBackoff is larger.
+const maxRetryBackoff = 30 * time.Second
+
+// defaultRedactHeaders are the headers redacted when
+// TransportOptions.RedactHeaders is nil.
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// TransportOptions are options for a [Transport].
+// A zero TransportOptions consists entirely of default values.
+type TransportOptions struct {
+	// LevelFunc returns the level of the log line for a round trip. resp is
+	// nil if err is not. If nil, errors and 5xx responses are logged at
+	// [logrus.ErrorLevel], 4xx responses at [logrus.WarnLevel] and
+	// everything else at [logrus.InfoLevel].
+	LevelFunc func(resp *http.Response, err error) logrus.Level
+
+	// RequestIDHeader is the header the request ID of the logging entry is
+	// propagated in, unless the outgoing request already sets it. Defaults
+	// to [DefaultRequestIDHeader].
+	RequestIDHeader string
+
+	// LogHeaders adds the request and response headers to the log line.
+	LogHeaders bool
+
+	// RedactHeaders lists headers whose values are replaced before logging.
+	// If nil, Authorization, Proxy-Authorization, Cookie and Set-Cookie are
+	// redacted. Set it to an empty, non-nil slice to log all headers.
+	RedactHeaders []string
+
+	// LogRequestBody and LogResponseBody add up to MaxBodySize bytes of the
+	// respective body to the log line. The response body is captured as
+	// the caller reads it, and the round trip is logged when the caller
+	// closes the body rather than when the response is returned, so that
+	// streaming responses are not delayed.
+	LogRequestBody  bool
+	LogResponseBody bool
+
+	// MaxBodySize caps the number of body bytes captured. Defaults to
+	// [DefaultMaxBodySize].
+	MaxBodySize int
+
+	// RedactBody, if set, is applied to captured bodies before logging.
+	// header holds the headers of the request or response the body
+	// belongs to.
+	RedactBody func(header http.Header, body []byte) []byte
+
+	// MaxRetries is the number of times a failed request is retried. Only
+	// requests with an idempotent method whose body can be replayed are
+	// retried, and only after a transport error or a 502, 503 or 504
+	// response. Zero disables retries.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry; it doubles with
+	// every further retry, up to 30s or RetryBackoff if that is larger.
+	// Defaults to 100ms.
+	RetryBackoff time.Duration
+
+	// Message is the message of the log line. Defaults to
+	// "outgoing request".
+	Message string
+}
+
+// Transport is an [http.RoundTripper] that logs outgoing requests.
+//
+// Each round trip is logged once with the method, host, path, status,
+// duration, number of retries and error, if any. The logging entry is taken
+// from the request context when it carries one (see [FromContext]), so
+// fields of the incoming request such as its request ID flow into logs of
+// outgoing calls; otherwise the entry given to [NewTransport] is used.
+type Transport struct {
+	base  http.RoundTripper
+	entry *logrus.Entry
+	opts  TransportOptions
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// NewTransport returns a [Transport] that sends requests through base and
+// logs them to entry. If base is nil, [http.DefaultTransport] is used.
+//
+// The provided entry must not be nil. NewTransport panics if entry is nil.
+// If opts is nil, the default options are used.
+func NewTransport(base http.RoundTripper, entry *logrus.Entry, opts *TransportOptions) *Transport {
+	if entry == nil {
+		panic("cannot create transport from nil entry")
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if opts == nil {
+		opts = &TransportOptions{}
+	}
+	t := &Transport{
+		base:  base,
+		entry: entry,
+		opts:  *opts,
+	}
+	if t.opts.LevelFunc == nil {
+		t.opts.LevelFunc = roundTripLevel
+	}
+	if t.opts.RequestIDHeader == "" {
+		t.opts.RequestIDHeader = DefaultRequestIDHeader
+	}
+	if t.opts.RedactHeaders == nil {
+		t.opts.RedactHeaders = defaultRedactHeaders
+	}
+	if t.opts.MaxBodySize <= 0 {
+		t.opts.MaxBodySize = DefaultMaxBodySize
+	}
+	if t.opts.RetryBackoff <= 0 {
+		t.opts.RetryBackoff = 100 * time.Millisecond
+	}
+	if t.opts.Message == "" {
+		t.opts.Message = "outgoing request"
+	}
+	return t
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	entry, ok := FromContext(req.Context())
+	if !ok {
+		entry = t.entry
+	}
+	entry = entry.WithContext(req.Context())
+
+	if id, ok := entry.Data[FieldKeyRequestID].(string); ok && id != "" && req.Header.Get(t.opts.RequestIDHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(t.opts.RequestIDHeader, id)
+	}
+
+	var reqBody *capture
+	if t.opts.LogRequestBody && req.Body != nil && req.Body != http.NoBody {
+		reqBody = &capture{limit: t.opts.MaxBodySize}
+		req = t.teeBody(req, reqBody)
+	}
+
+	resp, retries, err := t.roundTrip(req)
+
+	level := t.opts.LevelFunc(resp, err)
+	if !entry.Logger.IsLevelEnabled(level) {
+		return resp, err
+	}
+
+	fields := logrus.Fields{
+		FieldKeyMethod:   req.Method,
+		FieldKeyHost:     req.URL.Host,
+		FieldKeyPath:     req.URL.Path,
+		FieldKeyDuration: time.Since(start),
+		FieldKeyRetries:  retries,
+	}
+	if resp != nil {
+		fields[FieldKeyStatus] = resp.StatusCode
+	}
+	if err != nil {
+		fields[logrus.ErrorKey] = err
+	}
+	if t.opts.LogHeaders {
+		fields[FieldKeyRequestHeaders] = t.headers(req.Header)
+		if resp != nil {
+			fields[FieldKeyResponseHeaders] = t.headers(resp.Header)
+		}
+	}
+	if reqBody != nil {
+		fields[FieldKeyRequestBody] = t.body(req.Header, reqBody.bytes(), reqBody.truncated())
+	}
+	// The body of a 101 response is writable and must not be wrapped.
+	if t.opts.LogResponseBody && resp != nil && resp.Body != nil && resp.Body != http.NoBody &&
+		resp.StatusCode != http.StatusSwitchingProtocols {
+		c := &capture{limit: t.opts.MaxBodySize}
+		header := resp.Header
+		resp.Body = &loggingBody{
+			teeReadCloser: teeReadCloser{ReadCloser: resp.Body, c: c},
+			log: func() {
+				fields[FieldKeyResponseBody] = t.body(header, c.bytes(), c.truncated())
+				entry.WithFields(fields).Log(level, t.opts.Message)
+			},
+		}
+		return resp, err
+	}
+	entry.WithFields(fields).Log(level, t.opts.Message)
+
+	return resp, err
+}
+
+// roundTrip sends req, retrying it as configured, and reports the number of
+// retries performed.
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, int, error) {
+	retries := 0
+	for {
+		resp, err := t.base.RoundTrip(req)
+		if retries >= t.opts.MaxRetries || !retryable(req, resp, err) {
+			return resp, retries, err
+		}
+
+		next, gerr := rewind(req)
+		if gerr != nil {
+			return resp, retries, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(t.retryDelay(retries))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, retries, req.Context().Err()
+		case <-timer.C:
+		}
+		req = next
+		retries++
+	}
+}
+
+// retryDelay returns the delay before the retry following the given number
+// of retries.
+func (t *Transport) retryDelay(retries int) time.Duration {
+	limit := max(t.opts.RetryBackoff, maxRetryBackoff)
+	delay := t.opts.RetryBackoff
+	for range retries {
+		if delay >= limit/2 {
+			return limit
+		}
+		delay *= 2
+	}
+	return delay
+}
+
+// retryable reports whether a request that produced resp or err may be
+// retried.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if !isIdempotent(req.Method) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// rewind returns a copy of req with a fresh body for another attempt.
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	next := req.Clone(req.Context())
+	next.Body = body
+	return next, nil
+}
+
+// teeBody returns a copy of req whose body, and the bodies produced by its
+// GetBody, are recorded into c as they are read.
+func (t *Transport) teeBody(req *http.Request, c *capture) *http.Request {
+	req = req.Clone(req.Context())
+	req.Body = &teeReadCloser{ReadCloser: req.Body, c: c}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			c.reset()
+			return &teeReadCloser{ReadCloser: body, c: c}, nil
+		}
+	}
+	return req
+}
+
+// headers returns h flattened for logging with redacted values replaced.
+func (t *Transport) headers(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if slices.ContainsFunc(t.opts.RedactHeaders, func(r string) bool { return strings.EqualFold(r, k) }) {
+			out[k] = redacted
+			continue
+		}
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
+
+// body returns a captured body prepared for logging.
+func (t *Transport) body(h http.Header, b []byte, truncated bool) string {
+	if t.opts.RedactBody != nil {
+		b = t.opts.RedactBody(h, b)
+	}
+	if truncated {
+		return string(b) + truncatedSuffix
+	}
+	return string(b)
+}
+
+// roundTripLevel is the default [TransportOptions.LevelFunc].
+func roundTripLevel(resp *http.Response, err error) logrus.Level {
+	if err != nil {
+		return logrus.ErrorLevel
+	}
+	return statusLevel(resp.StatusCode)
+}
+
+// capture records up to limit bytes written to it.
+type capture struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+	total int
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total += len(p)
+	if room := c.limit - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (c *capture) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf = c.buf[:0]
+	c.total = 0
+}
+
+func (c *capture) bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.buf)
+}
+
+func (c *capture) truncated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total > c.limit
+}
+
+// teeReadCloser records everything read from ReadCloser into c.
+type teeReadCloser struct {
+	io.ReadCloser
+	c *capture
+}
+
+func (r *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = r.c.Write(p[:n])
+	}
+	return n, err
+}
+
+// loggingBody is a response body that is captured as it is read and calls
+// log when it is closed for the first time.
+type loggingBody struct {
+	teeReadCloser
+	once sync.Once
+	log  func()
+}
+
+func (b *loggingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.log)
+	return err
+}
@@ -0,0 +1,213 @@
+package httplog_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/sirupsen/logrus/httplog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportLogsRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	logger, hook := test.NewNullLogger()
+	client := &http.Client{Transport: httplog.NewTransport(nil, logrus.NewEntry(logger), nil)}
+
+	resp, err := client.Get(srv.URL + "/brew")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, logrus.WarnLevel, entry.Level)
+	assert.Equal(t, "outgoing request", entry.Message)
+	assert.Equal(t, http.MethodGet, entry.Data[httplog.FieldKeyMethod])
+	assert.Equal(t, strings.TrimPrefix(srv.URL, "http://"), entry.Data[httplog.FieldKeyHost])
+	assert.Equal(t, "/brew", entry.Data[httplog.FieldKeyPath])
+	assert.Equal(t, http.StatusTeapot, entry.Data[httplog.FieldKeyStatus])
+	assert.Equal(t, 0, entry.Data[httplog.FieldKeyRetries])
+	assert.IsType(t, time.Duration(0), entry.Data[httplog.FieldKeyDuration])
+}
+
+func TestTransportLogsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	logger, hook := test.NewNullLogger()
+	client := &http.Client{Transport: httplog.NewTransport(nil, logrus.NewEntry(logger), nil)}
+
+	_, err := client.Get(url)
+	require.Error(t, err)
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, logrus.ErrorLevel, entry.Level)
+	assert.Error(t, entry.Data[logrus.ErrorKey].(error))
+	assert.NotContains(t, entry.Data, httplog.FieldKeyStatus)
+}
+
+func TestTransportPropagatesRequestID(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(httplog.DefaultRequestIDHeader)
+	}))
+	defer srv.Close()
+
+	logger, hook := test.NewNullLogger()
+	client := &http.Client{Transport: httplog.NewTransport(nil, logrus.NewEntry(logger), nil)}
+
+	// An incoming request handled by the middleware calls a dependency
+	// with the request context.
+	h := httplog.NewHandler(logger, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}), nil)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(httplog.DefaultRequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "req-1", got)
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "outgoing request", entries[0].Message)
+	assert.Equal(t, "req-1", entries[0].Data[httplog.FieldKeyRequestID])
+}
+
+func TestTransportCapturesHeadersAndBodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 20))
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	logger, hook := test.NewNullLogger()
+	client := &http.Client{Transport: httplog.NewTransport(nil, logrus.NewEntry(logger), &httplog.TransportOptions{
+		LogHeaders:      true,
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		MaxBodySize:     10,
+		RedactBody: func(_ http.Header, b []byte) []byte {
+			return bytes.ReplaceAll(b, []byte("pw"), []byte("**"))
+		},
+	})}
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("user=a&pw"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, strings.Repeat("x", 20)+"user=a&pw", string(body), "caller must receive the complete body")
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, "user=a&**", entry.Data[httplog.FieldKeyRequestBody])
+	assert.Equal(t, "xxxxxxxxxx...(truncated)", entry.Data[httplog.FieldKeyResponseBody])
+	assert.Equal(t, "[REDACTED]", entry.Data[httplog.FieldKeyRequestHeaders].(map[string]string)["Authorization"])
+	assert.Equal(t, "[REDACTED]", entry.Data[httplog.FieldKeyResponseHeaders].(map[string]string)["Set-Cookie"])
+}
+
+func TestTransportStreamingResponseBody(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "first ")
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = io.WriteString(w, "second")
+	}))
+	defer srv.Close()
+
+	logger, hook := test.NewNullLogger()
+	client := &http.Client{Transport: httplog.NewTransport(nil, logrus.NewEntry(logger), &httplog.TransportOptions{
+		LogResponseBody: true,
+	})}
+
+	// The response is returned before the server finishes the body, and
+	// logged once the body is closed.
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	assert.Empty(t, hook.AllEntries())
+	close(release)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "first second", string(body))
+	require.NoError(t, resp.Body.Close())
+	require.NoError(t, resp.Body.Close())
+
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "first second", hook.LastEntry().Data[httplog.FieldKeyResponseBody])
+}
+
+func TestTransportRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "payload", string(body))
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	logger, hook := test.NewNullLogger()
+	client := &http.Client{Transport: httplog.NewTransport(nil, logrus.NewEntry(logger), &httplog.TransportOptions{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})}
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, 2, hook.LastEntry().Data[httplog.FieldKeyRetries])
+	assert.Equal(t, logrus.InfoLevel, hook.LastEntry().Level)
+}
+
+func TestTransportDoesNotRetryNonIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	logger, hook := test.NewNullLogger()
+	client := &http.Client{Transport: httplog.NewTransport(nil, logrus.NewEntry(logger), &httplog.TransportOptions{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})}
+
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("x"))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+}
@@ -0,0 +1,24 @@
+// Package entrycontext stores a request-scoped entry in a context. It is
+// shared by the integration packages, so that for example sqllog picks up
+// the entry stored by httplog without depending on it.
+package entrycontext
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// key is the context key under which the entry is stored.
+type key struct{}
+
+// New returns a copy of ctx that carries entry.
+func New(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, key{}, entry)
+}
+
+// From returns the entry stored in ctx by New, if any.
+func From(ctx context.Context) (*logrus.Entry, bool) {
+	entry, ok := ctx.Value(key{}).(*logrus.Entry)
+	return entry, ok && entry != nil
+}
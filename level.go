@@ -2,7 +2,7 @@ package logrus
 
 import (
 	"strings"
-	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -22,6 +22,12 @@ type lvlPrefix struct {
 
 func colorize(level Level, s string) string {
 	color := ansiCyan
+	if l, ok := LookupLevel(level); ok {
+		if l.Color != "" {
+			return l.Color + s + ansiReset
+		}
+		level = level.Builtin()
+	}
 	switch level {
 	case TraceLevel:
 		color = ansiDimWhite
@@ -51,10 +57,26 @@ func formatLevel(level Level, disableTrunc, pad bool, maxLen int) string {
 	return colorize(level, upper)
 }
 
-var levelPrefixOnce = sync.OnceValues(func() (map[Level]lvlPrefix, lvlPrefix) {
+type lvlPrefixes struct {
+	prefix  map[Level]lvlPrefix
+	unknown lvlPrefix
+	// reg is the level registry the prefixes were computed from.
+	reg *levelRegistry
+}
+
+// levelPrefixes caches the prefixes of the levels returned by Levels. It is reset when a level
+// is registered, and prefixes computed from an outdated registry are never cached.
+var levelPrefixes atomic.Pointer[lvlPrefixes]
+
+func newLevelPrefixes() *lvlPrefixes {
+	reg := customLevels.Load()
+	levels := AllLevels
+	if reg != nil {
+		levels = reg.all
+	}
 	var maxLevel Level
 	maxLen := 0
-	for _, lvl := range AllLevels {
+	for _, lvl := range levels {
 		if lvl > maxLevel {
 			maxLevel = lvl
 		}
@@ -63,8 +85,8 @@ var levelPrefixOnce = sync.OnceValues(func() (map[Level]lvlPrefix, lvlPrefix) {
 		}
 	}
 
-	prefix := make(map[Level]lvlPrefix, len(AllLevels))
-	for _, lvl := range AllLevels {
+	prefix := make(map[Level]lvlPrefix, len(levels))
+	for _, lvl := range levels {
 		prefix[lvl] = lvlPrefix{
 			full:      formatLevel(lvl, true, false, maxLen),
 			truncated: formatLevel(lvl, false, false, maxLen),
@@ -79,15 +101,22 @@ var levelPrefixOnce = sync.OnceValues(func() (map[Level]lvlPrefix, lvlPrefix) {
 		padded:    formatLevel(unknownLevel, true, true, maxLen),
 	}
 
-	return prefix, unknown
-})
+	return &lvlPrefixes{prefix: prefix, unknown: unknown, reg: reg}
+}
 
 func levelPrefix(level Level, disableTrunc, pad bool) string {
-	prefix, unknown := levelPrefixOnce()
+	prefixes := levelPrefixes.Load()
+	if prefixes == nil || prefixes.reg != customLevels.Load() {
+		// Replace only the loaded value, so that a reset by a concurrent
+		// RegisterLevel is not overwritten with outdated prefixes.
+		old := prefixes
+		prefixes = newLevelPrefixes()
+		levelPrefixes.CompareAndSwap(old, prefixes)
+	}
 
-	p, ok := prefix[level]
+	p, ok := prefixes.prefix[level]
 	if !ok {
-		p = unknown
+		p = prefixes.unknown
 	}
 
 	switch {
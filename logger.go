@@ -2,8 +2,10 @@ package logrus
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,9 +19,14 @@ type LogFunction func() []any
 type Logger struct {
 	// The logs are `io.Copy`'d to this in a mutex. It's common to set this to a
 	// file, or leave it default which is `os.Stderr`. You can also set this to
-	// something more adventurous, such as logging to Kafka.
+	// something more adventurous, such as logging to Kafka. If it is nil,
+	// entries are only written to the Sinks.
 	Out io.Writer
 
+	// Additional outputs, each with its own formatter, level and filter. Use
+	// AddSink and RemoveSink to change them safely.
+	Sinks []*Sink
+
 	// Hooks for the logger instance. These allow firing events based on logging
 	// levels and log entries. For example, to send errors to an error tracking
 	// service, log to StatsD or dump the core on fatal errors.
@@ -47,12 +54,43 @@ type Logger struct {
 	// Reusable empty entry
 	entryPool sync.Pool
 
+	// The entries returned by At for disabled levels, created on first use.
+	disabled [maxStatsLevel]atomic.Pointer[Entry]
+
 	// Function to exit the application, defaults to `os.Exit()`
 	ExitFunc func(int)
 
 	// The buffer pool used to format the log. If it is nil, the default global
 	// buffer pool will be used.
 	BufferPool BufferPool
+
+	// HookErrorPolicy controls whether the remaining hooks for an entry are
+	// fired after a hook fails. Defaults to HookErrorStop.
+	HookErrorPolicy HookErrorPolicy
+
+	// MaxHookFailures is the number of consecutive failures after which a
+	// hook is disabled under HookErrorDisable. Values below one are treated
+	// as one.
+	MaxHookFailures int
+
+	// HookErrorHandler is called for every failed or panicking hook. If it
+	// is nil, the error is printed to os.Stderr.
+	HookErrorHandler HookErrorHandler
+
+	// Failures of the hooks, see HookFailures.
+	hookFailures hookFailures
+
+	// Counters, see Stats.
+	stats loggerStats
+
+	// The least severe level of the CaptureHooks, see IsLevelEnabled.
+	captureLevel atomic.Uint32
+
+	// configMu serializes Config.Apply and Close. The output and hooks
+	// added by a Config are guarded by mu.
+	configMu    sync.Mutex
+	configOut   *configOutput
+	configHooks []Hook
 }
 
 // MutexWrap is the mutex implementation used by [Logger].
@@ -117,6 +155,29 @@ func (logger *Logger) releaseEntry(entry *Entry) {
 	logger.entryPool.Put(entry)
 }
 
+// disabledEntry returns the entry returned by At for the disabled level.
+func (logger *Logger) disabledEntry(level Level) *Entry {
+	if level >= maxStatsLevel {
+		return &Entry{Logger: logger, disabled: true, disabledAt: level}
+	}
+	p := &logger.disabled[level]
+	if entry := p.Load(); entry != nil {
+		return entry
+	}
+	p.CompareAndSwap(nil, &Entry{Logger: logger, disabled: true, disabledAt: level})
+	return p.Load()
+}
+
+// At returns a new entry if logging at level is enabled, and otherwise a
+// disabled entry on which adding fields and logging at level do nothing.
+// See [Entry.At].
+func (logger *Logger) At(level Level) *Entry {
+	if logger.IsLevelEnabled(level) {
+		return NewEntry(logger)
+	}
+	return logger.disabledEntry(level)
+}
+
 // WithField allocates a new entry and adds a field to it.
 // Debug, Print, Info, Warn, Error, Fatal or Panic must be then applied to
 // this new returned entry.
@@ -415,26 +476,123 @@ func (logger *Logger) AddHook(hook Hook) {
 	logger.mu.Lock()
 	defer logger.mu.Unlock()
 	logger.Hooks.Add(hook)
+	logger.updateCaptureLevel()
+}
+
+// RegisterHook adds a hook to the logger hooks like [Logger.AddHook] and
+// returns a handle to remove it again.
+func (logger *Logger) RegisterHook(hook Hook) *HookHandle {
+	logger.AddHook(hook)
+	return &HookHandle{logger: logger, hook: hook}
+}
+
+// RemoveHook removes every registration of hook from the logger hooks and
+// reports whether it was registered. The failure statistics of the hook
+// are cleared. See [LevelHooks.Remove] for how hooks are compared.
+func (logger *Logger) RemoveHook(hook Hook) bool {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	removed := logger.Hooks.Remove(hook)
+	if removed {
+		logger.hookFailures.reset(hook)
+		logger.updateCaptureLevel()
+	}
+	return removed
+}
+
+// ReplaceHook replaces every registration of oldHook with newHook and
+// reports whether oldHook was registered. See [LevelHooks.Replace].
+func (logger *Logger) ReplaceHook(oldHook, newHook Hook) bool {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	replaced := logger.Hooks.Replace(oldHook, newHook)
+	if replaced {
+		logger.hookFailures.reset(oldHook)
+		logger.updateCaptureLevel()
+	}
+	return replaced
+}
+
+// updateCaptureLevel recomputes the capture level after the hooks changed.
+// It must be called with logger.mu held.
+func (logger *Logger) updateCaptureLevel() {
+	logger.captureLevel.Store(uint32(logger.Hooks.captureLevel()))
 }
 
 // hooksForLevel returns a snapshot of the hooks registered for the given level.
 // The returned slice is a shallow copy and may be used without holding logger.mu.
+// It must be called with logger.mu held.
 func (logger *Logger) hooksForLevel(level Level) []Hook {
-	logger.mu.Lock()
 	hooks := logger.Hooks[level]
 	if len(hooks) == 0 {
-		logger.mu.Unlock()
 		return nil
 	}
 	out := make([]Hook, len(hooks))
 	copy(out, hooks)
-	logger.mu.Unlock()
 	return out
 }
 
+// SetHookErrorPolicy sets the hook error policy and, for HookErrorDisable,
+// the number of consecutive failures after which a hook is disabled.
+func (logger *Logger) SetHookErrorPolicy(policy HookErrorPolicy, maxFailures int) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.HookErrorPolicy = policy
+	logger.MaxHookFailures = maxFailures
+}
+
+// SetHookErrorHandler sets the function called for every failed or
+// panicking hook.
+func (logger *Logger) SetHookErrorHandler(handler HookErrorHandler) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.HookErrorHandler = handler
+}
+
+// HookFailures returns the failure statistics of every hook that failed at
+// least once since it was last reset, for example to report them in a
+// health check. Failures of hooks whose dynamic type is not comparable are
+// reported to the HookErrorHandler but not tracked.
+func (logger *Logger) HookFailures() []HookFailure {
+	return logger.hookFailures.snapshot()
+}
+
+// ResetHookFailures clears the failure statistics of hook and enables it
+// again if it was disabled.
+func (logger *Logger) ResetHookFailures(hook Hook) {
+	logger.hookFailures.reset(hook)
+}
+
 // IsLevelEnabled checks if logging for the given level is enabled.
+//
+// A level less severe than the logger's level is enabled if a [CaptureHook]
+// receives it.
 func (logger *Logger) IsLevelEnabled(level Level) bool {
-	return logger.level() >= level
+	return level.AtLeast(logger.level()) || level.AtLeast(Level(logger.captureLevel.Load()))
+}
+
+// WriteEntry writes entry to the logger's Out and sinks as it is, without
+// checking its level or firing hooks, for example to replay entries that
+// were captured earlier.
+func (logger *Logger) WriteEntry(entry *Entry) {
+	e := *entry
+	e.Logger = logger
+	bufPool := e.getBufferPool()
+	buffer := bufPool.Get()
+	defer func() {
+		buffer.Reset()
+		bufPool.Put(buffer)
+	}()
+	buffer.Reset()
+	e.Buffer = buffer
+	logger.mu.Lock()
+	formatter, out, sinks := logger.Formatter, logger.Out, logger.Sinks
+	if o, ok := out.(*configOutput); ok {
+		o.acquire()
+		defer o.release()
+	}
+	logger.mu.Unlock()
+	e.write(formatter, out, sinks)
 }
 
 // SetFormatter sets the logger formatter.
@@ -464,9 +622,51 @@ func (logger *Logger) ReplaceHooks(hooks LevelHooks) LevelHooks {
 	defer logger.mu.Unlock()
 	oldHooks := logger.Hooks
 	logger.Hooks = hooks
+	logger.updateCaptureLevel()
 	return oldHooks
 }
 
+// Close closes the hooks of the logger that implement [io.Closer], such as
+// hooks writing to files, and the files opened by [Config.Apply], and
+// returns their joined errors. A hook registered for several levels is
+// closed once. If Out is the output of Config.Apply, it is reset to
+// [os.Stderr]; files still written to by log calls in progress are closed
+// once they finish. Other outputs and sinks are not closed, since they are
+// usually shared, like os.Stderr.
+func (logger *Logger) Close() error {
+	logger.configMu.Lock()
+	defer logger.configMu.Unlock()
+	logger.mu.Lock()
+	out := logger.configOut
+	logger.configOut = nil
+	if out != nil && logger.Out == out {
+		logger.Out = os.Stderr
+	}
+	var closers []Hook
+	for _, level := range Levels() {
+		for _, hook := range logger.Hooks[level] {
+			if _, ok := hook.(io.Closer); !ok {
+				continue
+			}
+			if !slices.ContainsFunc(closers, func(h Hook) bool { return sameHook(h, hook) }) {
+				closers = append(closers, hook)
+			}
+		}
+	}
+	logger.mu.Unlock()
+
+	var errs []error
+	for _, hook := range closers {
+		if err := hook.(io.Closer).Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if out != nil {
+		errs = append(errs, out.release())
+	}
+	return errors.Join(errs...)
+}
+
 // SetBufferPool sets the logger buffer pool.
 func (logger *Logger) SetBufferPool(pool BufferPool) {
 	logger.mu.Lock()
@@ -4,6 +4,7 @@
 package logrus_test
 
 import (
+	"errors"
 	"io"
 	"os"
 	"testing"
@@ -89,6 +90,35 @@ func BenchmarkLoggerLog(b *testing.B) {
 	})
 }
 
+// BenchmarkLoggerDisabled measures the cost of log calls whose level is
+// disabled. Only chains starting with At skip copying their fields.
+func BenchmarkLoggerDisabled(b *testing.B) {
+	logger := logrus.New()
+	logger.SetFormatter(nopFormatter{})
+	logger.SetLevel(logrus.InfoLevel)
+	logger.SetOutput(io.Discard)
+	errBoom := errors.New("boom")
+
+	b.Run("Debugf", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			logger.Debugf("retrying %s after %v", "request", errBoom)
+		}
+	})
+	b.Run("WithFields_Copies", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			logger.WithFields(logrus.Fields{"k0": 0, "k1": "one"}).WithError(errBoom).Debugf("retrying %s", "request")
+		}
+	})
+	b.Run("At_WithFields", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			logger.At(logrus.DebugLevel).WithFields(logrus.Fields{"k0": 0, "k1": "one"}).WithError(errBoom).Debugf("retrying %s", "request")
+		}
+	})
+}
+
 func BenchmarkLoggerJSONFormatter(b *testing.B) {
 	doLoggerBenchmarkWithFormatter(b, &logrus.JSONFormatter{})
 }
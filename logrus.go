@@ -2,6 +2,7 @@ package logrus
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"log"
 )
@@ -32,6 +33,9 @@ func (level Level) String() string {
 	case PanicLevel:
 		return "panic"
 	default:
+		if l, ok := LookupLevel(level); ok {
+			return l.Name
+		}
 		return "unknown"
 	}
 }
@@ -59,6 +63,9 @@ func parseLevel(b []byte) (Level, error) {
 	case bytes.EqualFold(b, []byte("trace")):
 		return TraceLevel, nil
 	default:
+		if l, ok := lookupLevelName(string(b)); ok {
+			return l, nil
+		}
 		return 0, fmt.Errorf("not a valid logrus Level: %q", b)
 	}
 }
@@ -75,16 +82,26 @@ func (level *Level) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// Set implements [flag.Value], so that a Level can be used with [flag.Var].
+// See also [LevelFlag].
+func (level *Level) Set(s string) error {
+	return level.UnmarshalText([]byte(s))
+}
+
 func (level Level) MarshalText() ([]byte, error) {
 	switch level {
 	case TraceLevel, DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel, PanicLevel:
 		return []byte(level.String()), nil
 	default:
+		if _, ok := LookupLevel(level); ok {
+			return []byte(level.String()), nil
+		}
 		return nil, fmt.Errorf("not a valid logrus level %d", level)
 	}
 }
 
-// AllLevels exposing all logging levels.
+// AllLevels exposing all built-in logging levels, from the most to the least
+// severe. Use [Levels] to include the levels registered with [RegisterLevel].
 var AllLevels = []Level{
 	PanicLevel,
 	FatalLevel,
@@ -128,6 +145,8 @@ var (
 	_ FieldLogger = (*Entry)(nil)
 	_ FieldLogger = Ext1FieldLogger(nil)
 
+	_ flag.Value = (*Level)(nil)
+
 	_ DebugLogger = (*Logger)(nil)
 	_ InfoLogger  = (*Logger)(nil)
 	_ WarnLogger  = (*Logger)(nil)
@@ -0,0 +1,292 @@
+// Package metrics exposes the counters of Logrus loggers and asynchronous
+// hooks in the Prometheus text exposition format and via expvar, without
+// depending on the Prometheus client library.
+//
+// A [Collector] is an [http.Handler] serving the metrics:
+//
+//	c := metrics.NewCollector()
+//	c.AddLogger("app", logger)
+//	http.Handle("/metrics/logging", c)
+//
+// The following metrics are exported, each labeled with the name of the
+// logger or hook:
+//
+//	logrus_entries_total{logger,level}  entries logged
+//	logrus_bytes_written_total{logger}  bytes written to Out and sinks
+//	logrus_format_errors_total{logger}  formatter failures
+//	logrus_write_errors_total{logger}   failed writes
+//	logrus_hook_failures_total{logger}  failed or panicking hook calls
+//	logrus_hooks_disabled{logger}       hooks disabled by HookErrorDisable
+//	logrus_async_queued{hook}           entries queued in an async hook
+//	logrus_async_delivered_total{hook}  entries delivered by an async hook
+//	logrus_async_failed_total{hook}     entries an async hook failed to deliver
+//	logrus_async_dropped_total{hook}    entries dropped by an async hook
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/async"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector collects the metrics of loggers and asynchronous hooks.
+type Collector struct {
+	mu      sync.Mutex
+	loggers map[string]*logrus.Logger
+	hooks   map[string]*async.Hook
+}
+
+var _ http.Handler = (*Collector)(nil)
+
+// NewCollector returns an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{
+		loggers: make(map[string]*logrus.Logger),
+		hooks:   make(map[string]*async.Hook),
+	}
+}
+
+// AddLogger adds the counters of logger, labeled with name. Adding another
+// logger with the same name replaces it.
+//
+// AddLogger panics if logger is nil.
+func (c *Collector) AddLogger(name string, logger *logrus.Logger) {
+	if logger == nil {
+		panic("cannot collect metrics of nil logger")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loggers[name] = logger
+}
+
+// AddAsyncHook adds the counters of hook, labeled with name. Adding
+// another hook with the same name replaces it.
+//
+// AddAsyncHook panics if hook is nil.
+func (c *Collector) AddAsyncHook(name string, hook *async.Hook) {
+	if hook == nil {
+		panic("cannot collect metrics of nil hook")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks[name] = hook
+}
+
+// LoggerMetrics are the metrics of a logger.
+type LoggerMetrics struct {
+	logrus.LoggerStats
+	// HooksDisabled is the number of hooks disabled by
+	// logrus.HookErrorDisable.
+	HooksDisabled int
+}
+
+// Snapshot is the state of the metrics at a point in time.
+type Snapshot struct {
+	Loggers    map[string]LoggerMetrics
+	AsyncHooks map[string]async.Stats
+}
+
+// Snapshot returns the current metrics.
+func (c *Collector) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Snapshot{
+		Loggers:    make(map[string]LoggerMetrics, len(c.loggers)),
+		AsyncHooks: make(map[string]async.Stats, len(c.hooks)),
+	}
+	for name, logger := range c.loggers {
+		m := LoggerMetrics{LoggerStats: logger.Stats()}
+		for _, f := range logger.HookFailures() {
+			if f.Disabled {
+				m.HooksDisabled++
+			}
+		}
+		s.Loggers[name] = m
+	}
+	for name, hook := range c.hooks {
+		s.AsyncHooks[name] = hook.Stats()
+	}
+	return s
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = c.WriteTo(w)
+}
+
+// metric is a single metric family being written.
+type metric struct {
+	name, typ, help string
+	samples         []sample
+}
+
+type sample struct {
+	labels [][2]string
+	value  float64
+}
+
+// WriteTo writes the metrics to w in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	s := c.Snapshot()
+	loggers := sortedKeys(s.Loggers)
+	hooks := sortedKeys(s.AsyncHooks)
+
+	loggerMetric := func(name, typ, help string, value func(LoggerMetrics) float64) metric {
+		m := metric{name: name, typ: typ, help: help}
+		for _, logger := range loggers {
+			m.samples = append(m.samples, sample{
+				labels: [][2]string{{"logger", logger}},
+				value:  value(s.Loggers[logger]),
+			})
+		}
+		return m
+	}
+	hookMetric := func(name, typ, help string, value func(async.Stats) float64) metric {
+		m := metric{name: name, typ: typ, help: help}
+		for _, hook := range hooks {
+			m.samples = append(m.samples, sample{
+				labels: [][2]string{{"hook", hook}},
+				value:  value(s.AsyncHooks[hook]),
+			})
+		}
+		return m
+	}
+
+	entries := metric{name: "logrus_entries_total", typ: "counter", help: "Number of log entries by level."}
+	for _, logger := range loggers {
+		counts := s.Loggers[logger].Entries
+		for _, level := range sortedKeys(counts) {
+			entries.samples = append(entries.samples, sample{
+				labels: [][2]string{{"logger", logger}, {"level", level.String()}},
+				value:  float64(counts[level]),
+			})
+		}
+	}
+
+	families := []metric{
+		entries,
+		loggerMetric("logrus_bytes_written_total", "counter", "Number of bytes written to the outputs.",
+			func(m LoggerMetrics) float64 { return float64(m.BytesWritten) }),
+		loggerMetric("logrus_format_errors_total", "counter", "Number of entries that failed to format.",
+			func(m LoggerMetrics) float64 { return float64(m.FormatErrors) }),
+		loggerMetric("logrus_write_errors_total", "counter", "Number of failed writes to the outputs.",
+			func(m LoggerMetrics) float64 { return float64(m.WriteErrors) }),
+		loggerMetric("logrus_hook_failures_total", "counter", "Number of failed or panicking hook calls.",
+			func(m LoggerMetrics) float64 { return float64(m.HookFailures) }),
+		loggerMetric("logrus_hooks_disabled", "gauge", "Number of hooks disabled after repeated failures.",
+			func(m LoggerMetrics) float64 { return float64(m.HooksDisabled) }),
+		hookMetric("logrus_async_queued", "gauge", "Number of entries queued in an async hook.",
+			func(s async.Stats) float64 { return float64(s.Queued) }),
+		hookMetric("logrus_async_delivered_total", "counter", "Number of entries delivered by an async hook.",
+			func(s async.Stats) float64 { return float64(s.Delivered) }),
+		hookMetric("logrus_async_failed_total", "counter", "Number of entries an async hook failed to deliver.",
+			func(s async.Stats) float64 { return float64(s.Failed) }),
+		hookMetric("logrus_async_dropped_total", "counter", "Number of entries dropped by a full async hook queue.",
+			func(s async.Stats) float64 { return float64(s.Dropped) }),
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range families {
+		if len(m.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for _, s := range m.samples {
+			bw.WriteString(m.name)
+			bw.WriteByte('{')
+			for i, l := range s.labels {
+				if i > 0 {
+					bw.WriteByte(',')
+				}
+				fmt.Fprintf(bw, "%s=\"%s\"", l[0], labelEscaper.Replace(l[1]))
+			}
+			bw.WriteString("} ")
+			bw.WriteString(strconv.FormatFloat(s.value, 'f', -1, 64))
+			bw.WriteByte('\n')
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// labelEscaper escapes label values as required by the text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// PublishExpvar publishes the metrics as an [expvar] variable with the
+// given name. The variable is a JSON object with the fields "loggers" and
+// "async_hooks", holding the counters by name.
+//
+// Like [expvar.Publish], PublishExpvar panics if the name is already
+// registered.
+func (c *Collector) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return c.expvarValue()
+	}))
+}
+
+func (c *Collector) expvarValue() map[string]any {
+	s := c.Snapshot()
+	loggers := make(map[string]any, len(s.Loggers))
+	for name, m := range s.Loggers {
+		entries := make(map[string]uint64, len(m.Entries))
+		for level, n := range m.Entries {
+			entries[level.String()] = n
+		}
+		loggers[name] = map[string]any{
+			"entries":        entries,
+			"bytes_written":  m.BytesWritten,
+			"format_errors":  m.FormatErrors,
+			"write_errors":   m.WriteErrors,
+			"hook_failures":  m.HookFailures,
+			"hooks_disabled": m.HooksDisabled,
+		}
+	}
+	hooks := make(map[string]any, len(s.AsyncHooks))
+	for name, st := range s.AsyncHooks {
+		hooks[name] = map[string]any{
+			"queued":    st.Queued,
+			"delivered": st.Delivered,
+			"failed":    st.Failed,
+			"dropped":   st.Dropped,
+		}
+	}
+	return map[string]any{
+		"loggers":     loggers,
+		"async_hooks": hooks,
+	}
+}
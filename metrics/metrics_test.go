@@ -0,0 +1,128 @@
+package metrics_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/async"
+	"github.com/sirupsen/logrus/metrics"
+)
+
+type errorFormatter struct{}
+
+func (errorFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, errors.New("format failed")
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+type errorHook struct{}
+
+func (errorHook) Levels() []logrus.Level   { return []logrus.Level{logrus.ErrorLevel} }
+func (errorHook) Fire(*logrus.Entry) error { return errors.New("hook failed") }
+
+func newLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableColors: true})
+	logger.SetHookErrorPolicy(logrus.HookErrorDisable, 1)
+	logger.SetHookErrorHandler(func(logrus.Hook, *logrus.Entry, error) {})
+	logger.AddHook(errorHook{})
+	return logger
+}
+
+func TestCollector(t *testing.T) {
+	logger := newLogger()
+	logger.Info("one")
+	logger.Info("two")
+	logger.Error("three")
+	logger.AddSink(&logrus.Sink{Out: errorWriter{}})
+	logger.AddSink(&logrus.Sink{Out: io.Discard, Formatter: errorFormatter{}})
+	logger.Warn("four")
+
+	hook := async.New(&errorHook{}, &async.Options{ErrorHandler: func(error, []*logrus.Entry) {}})
+	require.NoError(t, hook.Fire(logrus.NewEntry(logger)))
+	require.NoError(t, hook.Close(context.Background()))
+
+	c := metrics.NewCollector()
+	c.AddLogger(`app "main"`, logger)
+	c.AddAsyncHook("shipper", hook)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, metrics.ContentType, rec.Header().Get("Content-Type"))
+
+	written := len("level=info msg=one\n") + len("level=info msg=two\n") +
+		len("level=error msg=three\n") + len("level=warning msg=four\n")
+	assert.Equal(t, `# HELP logrus_entries_total Number of log entries by level.
+# TYPE logrus_entries_total counter
+logrus_entries_total{logger="app \"main\"",level="error"} 1
+logrus_entries_total{logger="app \"main\"",level="warning"} 1
+logrus_entries_total{logger="app \"main\"",level="info"} 2
+# HELP logrus_bytes_written_total Number of bytes written to the outputs.
+# TYPE logrus_bytes_written_total counter
+logrus_bytes_written_total{logger="app \"main\""} `+itoa(written)+`
+# HELP logrus_format_errors_total Number of entries that failed to format.
+# TYPE logrus_format_errors_total counter
+logrus_format_errors_total{logger="app \"main\""} 1
+# HELP logrus_write_errors_total Number of failed writes to the outputs.
+# TYPE logrus_write_errors_total counter
+logrus_write_errors_total{logger="app \"main\""} 1
+# HELP logrus_hook_failures_total Number of failed or panicking hook calls.
+# TYPE logrus_hook_failures_total counter
+logrus_hook_failures_total{logger="app \"main\""} 1
+# HELP logrus_hooks_disabled Number of hooks disabled after repeated failures.
+# TYPE logrus_hooks_disabled gauge
+logrus_hooks_disabled{logger="app \"main\""} 1
+# HELP logrus_async_queued Number of entries queued in an async hook.
+# TYPE logrus_async_queued gauge
+logrus_async_queued{hook="shipper"} 0
+# HELP logrus_async_delivered_total Number of entries delivered by an async hook.
+# TYPE logrus_async_delivered_total counter
+logrus_async_delivered_total{hook="shipper"} 0
+# HELP logrus_async_failed_total Number of entries an async hook failed to deliver.
+# TYPE logrus_async_failed_total counter
+logrus_async_failed_total{hook="shipper"} 1
+# HELP logrus_async_dropped_total Number of entries dropped by a full async hook queue.
+# TYPE logrus_async_dropped_total counter
+logrus_async_dropped_total{hook="shipper"} 0
+`, rec.Body.String())
+}
+
+func itoa(n int) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
+
+func TestPublishExpvar(t *testing.T) {
+	logger := newLogger()
+	logger.Info("one")
+
+	c := metrics.NewCollector()
+	c.AddLogger("app", logger)
+	c.PublishExpvar("logrus_test")
+
+	var v struct {
+		Loggers map[string]struct {
+			Entries      map[string]uint64 `json:"entries"`
+			BytesWritten uint64            `json:"bytes_written"`
+		} `json:"loggers"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("logrus_test").String()), &v))
+	assert.Equal(t, map[string]uint64{"info": 1}, v.Loggers["app"].Entries)
+	assert.Equal(t, uint64(len("level=info msg=one\n")), v.Loggers["app"].BytesWritten)
+}
@@ -0,0 +1,14 @@
+//go:build !plan9
+
+package netwriter
+
+import (
+	"errors"
+	"syscall"
+)
+
+// undeliverable reports whether err means that the record can never be
+// sent, such as a datagram exceeding the maximum size.
+func undeliverable(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
@@ -0,0 +1,7 @@
+package netwriter
+
+// undeliverable reports whether err means that the record can never be
+// sent.
+func undeliverable(err error) bool {
+	return false
+}
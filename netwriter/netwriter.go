@@ -0,0 +1,452 @@
+// Package netwriter provides an [io.Writer] that sends log entries over the
+// network, for example to a local log shipping agent, and keeps them while
+// the connection is down.
+//
+// A [Writer] never blocks logging on the network. Entries are queued in
+// memory and sent by a background goroutine, which reconnects with
+// exponential backoff when the connection fails. While the connection is
+// down, entries are kept in memory up to a limit and, if a spool directory
+// is configured, on disk beyond that. Queued entries are sent in order once
+// the connection returns:
+//
+//	w, err := netwriter.New("tcp", "localhost:24224", &netwriter.Options{
+//		SpoolDir: "/var/spool/myapp/logs",
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer w.Close(context.Background())
+//	logger.SetOutput(w)
+//
+// Every Write is sent as one record: one datagram for datagram sockets, or
+// the written bytes as they are for stream sockets. Records that can never
+// be sent, such as datagrams exceeding the maximum size, are dropped and
+// counted in [Stats].Rejected. Records are delivered at least once: a
+// record interrupted by a broken connection is sent again in full on the
+// next one.
+package netwriter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBufferSize is the maximum number of bytes queued in memory if
+	// Options.BufferSize is 0.
+	DefaultBufferSize = 1 << 20
+	// DefaultMaxSpoolSize is the maximum size of the spool if
+	// Options.MaxSpoolSize is 0.
+	DefaultMaxSpoolSize = 64 << 20
+	// DefaultTimeout is the dial and write timeout if Options.DialTimeout
+	// or Options.WriteTimeout is 0.
+	DefaultTimeout = 5 * time.Second
+	// DefaultMinBackoff and DefaultMaxBackoff bound the delay between
+	// reconnection attempts if Options.MinBackoff or Options.MaxBackoff is 0.
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// ErrClosed is returned by Write after the writer has been closed.
+var ErrClosed = errors.New("netwriter: writer closed")
+
+// Options are options for a [Writer].
+// A zero Options consists entirely of default values.
+type Options struct {
+	// TLSConfig is the TLS configuration for the "tls" network. If nil,
+	// the default configuration is used.
+	TLSConfig *tls.Config
+
+	// Dial, if set, replaces the dialing of network and address, for
+	// example to use a proxy.
+	Dial func(ctx context.Context) (net.Conn, error)
+
+	// DialTimeout and WriteTimeout limit connecting and sending a record.
+	// If 0, DefaultTimeout is used.
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// MinBackoff is the delay before the first reconnection attempt after
+	// a failure. It doubles after every failed attempt, up to MaxBackoff.
+	// If 0, DefaultMinBackoff and DefaultMaxBackoff are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// BufferSize is the maximum number of bytes queued in memory. Records
+	// that do not fit are spooled to disk if SpoolDir is set, and dropped
+	// otherwise. If 0, DefaultBufferSize is used.
+	BufferSize int
+
+	// SpoolDir is the directory of the on-disk spool. If empty, there is
+	// no spool. Records still spooled when the program exits are sent by
+	// the next Writer using the same directory.
+	SpoolDir string
+
+	// MaxSpoolSize is the maximum size of the spool in bytes. Records that
+	// do not fit are dropped. If 0, DefaultMaxSpoolSize is used.
+	MaxSpoolSize int64
+}
+
+// Stats are the delivery statistics of a [Writer].
+type Stats struct {
+	// Sent is the number of records sent, and SentBytes their size.
+	Sent      uint64
+	SentBytes uint64
+	// Spooled is the number of records written to the spool.
+	Spooled uint64
+	// Dropped is the number of records dropped because the memory buffer
+	// and the spool were full.
+	Dropped uint64
+	// Rejected is the number of records dropped because they can never be
+	// sent, such as datagrams exceeding the maximum size.
+	Rejected uint64
+	// Reconnects is the number of connections established after the
+	// first one.
+	Reconnects uint64
+	// Buffered is the number of bytes queued in memory, and SpoolBytes the
+	// number of bytes in the spool waiting to be sent.
+	Buffered   int
+	SpoolBytes int64
+	// Connected reports whether the writer is currently connected.
+	Connected bool
+	// LastError is the most recent connection or write error.
+	LastError error
+}
+
+// Writer is an [io.Writer] sending records over the network.
+type Writer struct {
+	opts Options
+	dial func(ctx context.Context) (net.Conn, error)
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   [][]byte
+	queued  int // bytes in queue
+	spool   *spool
+	closing bool
+	stats   Stats
+	dials   uint64
+
+	// abort is closed when Close gives up waiting, and done when the
+	// sending goroutine exits.
+	abort     chan struct{}
+	abortOnce sync.Once
+	done      chan struct{}
+}
+
+// New returns a Writer sending to address on network, which is one of
+// "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram" or
+// "tls" for TLS over TCP. The connection is established in the background.
+// If opts is nil, the default options are used.
+//
+// New only returns an error if the spool cannot be opened.
+func New(network, address string, opts *Options) (*Writer, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	w := &Writer{
+		opts:  *opts,
+		abort: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	if w.opts.DialTimeout <= 0 {
+		w.opts.DialTimeout = DefaultTimeout
+	}
+	if w.opts.WriteTimeout <= 0 {
+		w.opts.WriteTimeout = DefaultTimeout
+	}
+	if w.opts.MinBackoff <= 0 {
+		w.opts.MinBackoff = DefaultMinBackoff
+	}
+	if w.opts.MaxBackoff <= 0 {
+		w.opts.MaxBackoff = max(DefaultMaxBackoff, w.opts.MinBackoff)
+	}
+	if w.opts.BufferSize <= 0 {
+		w.opts.BufferSize = DefaultBufferSize
+	}
+	if w.opts.MaxSpoolSize <= 0 {
+		w.opts.MaxSpoolSize = DefaultMaxSpoolSize
+	}
+
+	w.dial = w.opts.Dial
+	if w.dial == nil {
+		dialer := &net.Dialer{Timeout: w.opts.DialTimeout}
+		if network == "tls" {
+			td := &tls.Dialer{NetDialer: dialer, Config: w.opts.TLSConfig}
+			w.dial = func(ctx context.Context) (net.Conn, error) {
+				return td.DialContext(ctx, "tcp", address)
+			}
+		} else {
+			w.dial = func(ctx context.Context) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			}
+		}
+	}
+
+	if w.opts.SpoolDir != "" {
+		s, err := openSpool(w.opts.SpoolDir, w.opts.MaxSpoolSize)
+		if err != nil {
+			return nil, err
+		}
+		w.spool = s
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Write queues a copy of p to be sent as one record. It does not block on
+// the network and only fails after the writer has been closed; records
+// that do not fit into the buffer or the spool are counted as dropped.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closing {
+		return 0, ErrClosed
+	}
+	w.enqueue(bytes.Clone(p))
+	return len(p), nil
+}
+
+// enqueue must be called with w.mu held.
+func (w *Writer) enqueue(rec []byte) {
+	// Once records are spooled, new records are spooled as well until the
+	// spool is empty, so that records are sent in order.
+	if w.spool != nil && (w.spool.pending > 0 || w.queued+len(rec) > w.opts.BufferSize) {
+		if err := w.spool.append(rec); err != nil {
+			w.stats.Dropped++
+			if !errors.Is(err, errSpoolFull) {
+				w.stats.LastError = err
+			}
+			return
+		}
+		w.stats.Spooled++
+	} else if w.queued+len(rec) > w.opts.BufferSize {
+		w.stats.Dropped++
+		return
+	} else {
+		w.queue = append(w.queue, rec)
+		w.queued += len(rec)
+	}
+	w.cond.Signal()
+}
+
+// next waits for the next record to send. It returns false once the
+// writer is closing and nothing is left to send, or sending was aborted.
+func (w *Writer) next() (rec []byte, spooled bool, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		if w.aborted() {
+			return nil, false, false
+		}
+		if len(w.queue) > 0 {
+			return w.queue[0], false, true
+		}
+		if w.spool != nil && w.spool.pending > 0 {
+			rec, err := w.spool.peek()
+			if err != nil {
+				// The spool is unreadable; give up on it rather than
+				// retrying forever.
+				w.stats.LastError = err
+				_ = w.spool.close()
+				w.spool = nil
+				continue
+			}
+			if rec != nil {
+				return rec, true, true
+			}
+		}
+		if w.closing {
+			return nil, false, false
+		}
+		w.cond.Wait()
+	}
+}
+
+// commit removes the record returned by next after it was sent, or
+// rejected if it can never be sent.
+func (w *Writer) commit(rec []byte, spooled, rejected bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if rejected {
+		w.stats.Rejected++
+	} else {
+		w.stats.Sent++
+		w.stats.SentBytes += uint64(len(rec))
+	}
+	if spooled {
+		if err := w.spool.advance(rec); err != nil {
+			w.stats.LastError = err
+		}
+		return
+	}
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	w.queued -= len(rec)
+}
+
+func (w *Writer) aborted() bool {
+	select {
+	case <-w.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// run sends the queued records until the writer is closed.
+func (w *Writer) run() {
+	defer close(w.done)
+
+	var conn net.Conn
+	// written is the number of bytes of the current record written to conn
+	// by a write that timed out.
+	var written int
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+		w.setConnected(false, nil)
+	}()
+
+	backoff := w.opts.MinBackoff
+	fail := func(err error) bool {
+		if conn != nil {
+			_ = conn.Close()
+			conn = nil
+		}
+		written = 0
+		w.setConnected(false, err)
+		timer := time.NewTimer(backoff)
+		defer timer.Stop()
+		backoff = min(backoff*2, w.opts.MaxBackoff)
+		select {
+		case <-timer.C:
+			return true
+		case <-w.abort:
+			return false
+		}
+	}
+
+	for {
+		rec, spooled, ok := w.next()
+		if !ok {
+			return
+		}
+
+		if conn == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), w.opts.DialTimeout)
+			c, err := w.dial(ctx)
+			cancel()
+			if err != nil {
+				if !fail(err) {
+					return
+				}
+				continue
+			}
+			conn = c
+			w.setConnected(true, nil)
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(w.opts.WriteTimeout))
+		n, err := conn.Write(rec[written:])
+		if err != nil {
+			var ne net.Error
+			switch {
+			case undeliverable(err):
+				w.setConnected(true, err)
+				w.commit(rec, spooled, true)
+			case n > 0 && errors.As(err, &ne) && ne.Timeout():
+				// Resending the record in full would corrupt the stream;
+				// write the rest on the same connection instead.
+				written += n
+				w.setConnected(true, err)
+			default:
+				if !fail(err) {
+					return
+				}
+			}
+			continue
+		}
+		written = 0
+		backoff = w.opts.MinBackoff
+		w.commit(rec, spooled, false)
+	}
+}
+
+func (w *Writer) setConnected(connected bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if connected && !w.stats.Connected {
+		if w.dials > 0 {
+			w.stats.Reconnects++
+		}
+		w.dials++
+	}
+	w.stats.Connected = connected
+	if err != nil {
+		w.stats.LastError = err
+	}
+}
+
+// Stats returns the current delivery statistics.
+func (w *Writer) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.stats
+	stats.Buffered = w.queued
+	if w.spool != nil {
+		stats.SpoolBytes = w.spool.pending
+	}
+	return stats
+}
+
+// Close stops accepting records and waits until the queued records have
+// been sent. If ctx is done first, sending stops; records still queued in
+// memory are then moved to the front of the spool, if any, to be sent
+// before the spooled ones by the next Writer using it, and dropped
+// otherwise. Close returns ctx.Err() in that case.
+func (w *Writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	w.closing = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	var err error
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		w.abortOnce.Do(func() {
+			close(w.abort)
+		})
+		w.mu.Lock()
+		w.cond.Broadcast()
+		w.mu.Unlock()
+		<-w.done
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.spool != nil {
+		// Records queued in memory are older than the spooled ones.
+		n, perr := w.spool.prepend(w.queue)
+		w.stats.Spooled += uint64(n)
+		w.stats.Dropped += uint64(len(w.queue) - n)
+		w.queue, w.queued = nil, 0
+		err = errors.Join(err, perr, w.spool.close())
+		// A second Close must not use the closed spool.
+		w.spool = nil
+	} else {
+		w.stats.Dropped += uint64(len(w.queue))
+		w.queue, w.queued = nil, 0
+	}
+	return err
+}
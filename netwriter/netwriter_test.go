@@ -0,0 +1,358 @@
+package netwriter_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus/netwriter"
+)
+
+// server accepts connections on a listener and collects the received lines.
+type server struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	lines []string
+	conns []net.Conn
+	done  chan struct{}
+}
+
+func listen(t *testing.T, network, address string) *server {
+	t.Helper()
+	ln, err := net.Listen(network, address)
+	require.NoError(t, err)
+	s := &server{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *server) serve() {
+	defer close(s.done)
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go func() {
+			sc := bufio.NewScanner(conn)
+			for sc.Scan() {
+				s.mu.Lock()
+				s.lines = append(s.lines, sc.Text())
+				s.mu.Unlock()
+			}
+		}()
+	}
+}
+
+func (s *server) close() {
+	_ = s.ln.Close()
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *server) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lines...)
+}
+
+func (s *server) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(s.received()) >= n }, 5*time.Second, 5*time.Millisecond)
+	return s.received()
+}
+
+func lines(from, to int) []string {
+	var out []string
+	for i := from; i < to; i++ {
+		out = append(out, fmt.Sprintf("line %d", i))
+	}
+	return out
+}
+
+func writeLines(t *testing.T, w *netwriter.Writer, from, to int) {
+	t.Helper()
+	for _, line := range lines(from, to) {
+		n, err := w.Write([]byte(line + "\n"))
+		require.NoError(t, err)
+		require.Equal(t, len(line)+1, n)
+	}
+}
+
+func closeWriter(t *testing.T, w *netwriter.Writer) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, w.Close(ctx))
+}
+
+var fastBackoff = netwriter.Options{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+func TestWriterTCP(t *testing.T) {
+	s := listen(t, "tcp", "127.0.0.1:0")
+	opts := fastBackoff
+	w, err := netwriter.New("tcp", s.ln.Addr().String(), &opts)
+	require.NoError(t, err)
+
+	writeLines(t, w, 0, 10)
+	assert.Equal(t, lines(0, 10), s.waitFor(t, 10))
+	closeWriter(t, w)
+
+	stats := w.Stats()
+	assert.EqualValues(t, 10, stats.Sent)
+	assert.EqualValues(t, 0, stats.Dropped)
+	assert.Zero(t, stats.Buffered)
+
+	_, err = w.Write([]byte("late\n"))
+	assert.ErrorIs(t, err, netwriter.ErrClosed)
+}
+
+func TestWriterReconnect(t *testing.T) {
+	s := listen(t, "tcp", "127.0.0.1:0")
+	addr := s.ln.Addr().String()
+	opts := fastBackoff
+	w, err := netwriter.New("tcp", addr, &opts)
+	require.NoError(t, err)
+	defer closeWriter(t, w)
+
+	writeLines(t, w, 0, 5)
+	s.waitFor(t, 5)
+	s.close()
+
+	// Writes during the outage are buffered. The first write after the
+	// peer went away may still succeed locally, so keep writing until the
+	// writer notices.
+	writeLines(t, w, 5, 10)
+	require.Eventually(t, func() bool { return !w.Stats().Connected }, 5*time.Second, time.Millisecond)
+	writeLines(t, w, 10, 20)
+
+	s2 := listen(t, "tcp", addr)
+	got := s2.waitFor(t, 10)
+	assert.Equal(t, lines(10, 20), got[len(got)-10:])
+
+	stats := w.Stats()
+	assert.EqualValues(t, 1, stats.Reconnects)
+	assert.Error(t, stats.LastError)
+}
+
+func TestWriterSpool(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	dir := t.TempDir()
+	opts := fastBackoff
+	opts.BufferSize = 32
+	opts.SpoolDir = dir
+	w, err := netwriter.New("tcp", addr, &opts)
+	require.NoError(t, err)
+
+	writeLines(t, w, 0, 20)
+	stats := w.Stats()
+	assert.NotZero(t, stats.Spooled)
+	assert.NotZero(t, stats.SpoolBytes)
+	assert.Zero(t, stats.Dropped)
+
+	s := listen(t, "tcp", addr)
+	assert.Equal(t, lines(0, 20), s.waitFor(t, 20))
+	closeWriter(t, w)
+
+	stats = w.Stats()
+	assert.EqualValues(t, 20, stats.Sent)
+	assert.Zero(t, stats.SpoolBytes)
+	files, err := filepath.Glob(filepath.Join(dir, "*.spool"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestWriterSpoolReplayedByNextWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	dir := t.TempDir()
+	opts := fastBackoff
+	opts.SpoolDir = dir
+	w, err := netwriter.New("tcp", addr, &opts)
+	require.NoError(t, err)
+	writeLines(t, w, 0, 10)
+
+	// Closing without a connection moves the buffered records to the spool.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(ctx), context.DeadlineExceeded)
+	assert.EqualValues(t, 10, w.Stats().Spooled)
+
+	s := listen(t, "tcp", addr)
+	w, err = netwriter.New("tcp", addr, &opts)
+	require.NoError(t, err)
+	writeLines(t, w, 10, 15)
+	assert.Equal(t, lines(0, 15), s.waitFor(t, 15))
+	closeWriter(t, w)
+}
+
+func TestWriterDropsWithoutSpool(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	opts := fastBackoff
+	opts.BufferSize = 16
+	w, err := netwriter.New("tcp", addr, &opts)
+	require.NoError(t, err)
+	writeLines(t, w, 0, 10)
+
+	stats := w.Stats()
+	assert.EqualValues(t, 8, stats.Dropped)
+	assert.Equal(t, 14, stats.Buffered)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(ctx), context.DeadlineExceeded)
+	assert.EqualValues(t, 10, w.Stats().Dropped)
+}
+
+func TestWriterUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	w, err := netwriter.New("udp", pc.LocalAddr().String(), nil)
+	require.NoError(t, err)
+	defer closeWriter(t, w)
+
+	_, err = w.Write([]byte("datagram"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 64)
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "datagram", string(buf[:n]))
+}
+
+func TestWriterUnix(t *testing.T) {
+	dir, err := os.MkdirTemp("", "netwriter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := listen(t, "unix", filepath.Join(dir, "stream.sock"))
+	w, err := netwriter.New("unix", s.ln.Addr().String(), nil)
+	require.NoError(t, err)
+	writeLines(t, w, 0, 3)
+	assert.Equal(t, lines(0, 3), s.waitFor(t, 3))
+	closeWriter(t, w)
+
+	path := filepath.Join(dir, "dgram.sock")
+	pc, err := net.ListenPacket("unixgram", path)
+	require.NoError(t, err)
+	defer pc.Close()
+	w, err = netwriter.New("unixgram", path, nil)
+	require.NoError(t, err)
+	defer closeWriter(t, w)
+	_, err = w.Write([]byte("datagram"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 64)
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "datagram", string(buf[:n]))
+}
+
+func TestWriterRejectsOversizeDatagram(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	w, err := netwriter.New("udp", pc.LocalAddr().String(), &fastBackoff)
+	require.NoError(t, err)
+	defer closeWriter(t, w)
+
+	_, err = w.Write(make([]byte, 1<<17))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("datagram"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 64)
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "datagram", string(buf[:n]))
+	assert.EqualValues(t, 1, w.Stats().Rejected)
+}
+
+func TestWriterCloseKeepsOrder(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	// The first records are buffered in memory and the later ones spooled;
+	// closing puts the buffered ones in front of the spooled ones.
+	opts := fastBackoff
+	opts.BufferSize = 32
+	opts.SpoolDir = t.TempDir()
+	w, err := netwriter.New("tcp", addr, &opts)
+	require.NoError(t, err)
+	writeLines(t, w, 0, 10)
+	require.NotZero(t, w.Stats().Buffered)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(ctx), context.DeadlineExceeded)
+	assert.Zero(t, w.Stats().Dropped)
+
+	s := listen(t, "tcp", addr)
+	w, err = netwriter.New("tcp", addr, &opts)
+	require.NoError(t, err)
+	assert.Equal(t, lines(0, 10), s.waitFor(t, 10))
+	closeWriter(t, w)
+}
+
+func TestWriterCloseTwice(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	opts := fastBackoff
+	opts.SpoolDir = t.TempDir()
+	w, err := netwriter.New("tcp", addr, &opts)
+	require.NoError(t, err)
+	writeLines(t, w, 0, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(ctx), context.DeadlineExceeded)
+
+	// A later writer may have saved its read position in the meantime; the
+	// second Close must neither overwrite it nor spool the records again.
+	position := filepath.Join(opts.SpoolDir, "position")
+	require.NoError(t, os.WriteFile(position, []byte("later\n"), 0o644))
+	assert.NoError(t, w.Close(context.Background()))
+	assert.EqualValues(t, 10, w.Stats().Spooled)
+	b, err := os.ReadFile(position)
+	require.NoError(t, err)
+	assert.Equal(t, "later\n", string(b))
+	_, err = w.Write([]byte("late\n"))
+	assert.ErrorIs(t, err, netwriter.ErrClosed)
+}
@@ -0,0 +1,344 @@
+package netwriter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// spoolSegmentSize is the size after which a new spool segment is
+	// started. Fully replayed segments are removed.
+	spoolSegmentSize = 4 << 20
+	spoolSuffix      = ".spool"
+	// spoolHeaderSize is the size of the length prefix of a record.
+	spoolHeaderSize = 4
+	// spoolPosition is the file the read position is saved to on close,
+	// so that the records already sent are not sent again.
+	spoolPosition = "position"
+	// firstSpoolSegment is the sequence number of the first segment. The
+	// segments prepended on close are numbered downwards from it.
+	firstSpoolSegment = 1 << 32
+)
+
+var errSpoolFull = errors.New("netwriter: spool full")
+
+// spool is an on-disk queue of records, stored in numbered segment files
+// as length-prefixed records. It is not safe for concurrent use.
+type spool struct {
+	dir     string
+	maxSize int64
+
+	segments []uint64 // sequence numbers of the segment files, oldest first
+	size     int64    // size of all segments
+	pending  int64    // size of the records not read yet
+
+	w     *os.File // last segment, open for appending
+	wSize int64
+
+	r       *os.File // first segment, open for reading
+	rSeq    uint64   // the sequence number of r
+	rSize   int64    // the size of r when last checked
+	rOffset int64
+
+	// start is the read position in segment startSeq, restored from the
+	// position file or saved by prepend.
+	startSeq    uint64
+	startOffset int64
+}
+
+func openSpool(dir string, maxSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, maxSize: maxSize}
+	for _, e := range names {
+		seq, ok := strings.CutSuffix(e.Name(), spoolSuffix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		n, err := strconv.ParseUint(seq, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, n)
+		s.size += info.Size()
+	}
+	slices.Sort(s.segments)
+	s.pending = s.size
+	s.readPosition()
+	return s, nil
+}
+
+// readPosition restores the read position saved by close.
+func (s *spool) readPosition() {
+	b, err := os.ReadFile(filepath.Join(s.dir, spoolPosition))
+	if err != nil {
+		return
+	}
+	var seq uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(b), "%d %d", &seq, &offset); err != nil || offset <= 0 {
+		return
+	}
+	if !slices.Contains(s.segments, seq) {
+		return
+	}
+	info, err := os.Stat(s.path(seq))
+	if err != nil || offset > info.Size() {
+		return
+	}
+	s.startSeq, s.startOffset = seq, offset
+	s.pending -= offset
+}
+
+// writePosition saves the read position, or removes the position file if
+// there is none.
+func (s *spool) writePosition() error {
+	path := filepath.Join(s.dir, spoolPosition)
+	seq, offset := s.startSeq, s.startOffset
+	if s.r != nil {
+		seq, offset = s.rSeq, s.rOffset
+	}
+	if offset <= 0 || !slices.Contains(s.segments, seq) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, fmt.Appendf(nil, "%d %d\n", seq, offset), 0o644)
+}
+
+func (s *spool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolSuffix))
+}
+
+// append adds a record to the end of the spool.
+func (s *spool) append(rec []byte) error {
+	n := int64(spoolHeaderSize + len(rec))
+	if s.size+n > s.maxSize {
+		return errSpoolFull
+	}
+	if s.w == nil || s.wSize >= spoolSegmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	buf := make([]byte, spoolHeaderSize, n)
+	binary.BigEndian.PutUint32(buf, uint32(len(rec)))
+	buf = append(buf, rec...)
+	if _, err := s.w.Write(buf); err != nil {
+		return err
+	}
+	s.wSize += n
+	s.size += n
+	s.pending += n
+	return nil
+}
+
+// rotate starts a new segment.
+func (s *spool) rotate() error {
+	if s.w != nil {
+		if err := s.w.Close(); err != nil {
+			return err
+		}
+		s.w = nil
+	}
+	var seq uint64 = firstSpoolSegment
+	if len(s.segments) > 0 {
+		seq = s.segments[len(s.segments)-1] + 1
+	}
+	f, err := os.OpenFile(s.path(seq), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.segments = append(s.segments, seq)
+	s.w, s.wSize = f, 0
+	return nil
+}
+
+// peek returns the oldest record that was not read yet, or nil if there is
+// none. The record is not consumed until advance is called.
+func (s *spool) peek() ([]byte, error) {
+	for s.pending > 0 {
+		if s.r == nil {
+			seq := s.segments[0]
+			f, err := os.Open(s.path(seq))
+			if err != nil {
+				return nil, err
+			}
+			s.r, s.rSeq, s.rSize, s.rOffset = f, seq, 0, 0
+			if seq == s.startSeq {
+				s.rOffset = s.startOffset
+			}
+		}
+
+		var header [spoolHeaderSize]byte
+		_, err := s.r.ReadAt(header[:], s.rOffset)
+		if err == nil {
+			// Check the length against the size of the segment, so that a
+			// corrupt header does not cause a huge allocation. A record
+			// past the end is treated as truncated.
+			end := s.rOffset + spoolHeaderSize + int64(binary.BigEndian.Uint32(header[:]))
+			if end > s.rSize {
+				if info, serr := s.r.Stat(); serr == nil {
+					s.rSize = info.Size()
+				}
+			}
+			if end > s.rSize {
+				err = io.EOF
+			} else {
+				rec := make([]byte, end-s.rOffset-spoolHeaderSize)
+				if _, err = s.r.ReadAt(rec, s.rOffset+spoolHeaderSize); err == nil {
+					return rec, nil
+				}
+			}
+		}
+		if !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		// The first segment was read completely, or ends with a record
+		// truncated by a crash.
+		if len(s.segments) == 1 && s.w != nil {
+			return nil, nil
+		}
+		if err := s.removeFirst(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// advance consumes the record returned by peek.
+func (s *spool) advance(rec []byte) error {
+	n := int64(spoolHeaderSize + len(rec))
+	s.rOffset += n
+	s.pending -= n
+	// Once everything was replayed, start over with an empty spool.
+	for s.pending <= 0 && len(s.segments) > 0 {
+		if err := s.removeFirst(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFirst removes the first segment.
+func (s *spool) removeFirst() error {
+	if s.r != nil {
+		_ = s.r.Close()
+		s.r = nil
+	}
+	if len(s.segments) == 1 && s.w != nil {
+		_ = s.w.Close()
+		s.w = nil
+	}
+	seq := s.segments[0]
+	path := s.path(seq)
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	offset := s.rOffset
+	if seq != s.rSeq {
+		offset = 0
+		if seq == s.startSeq {
+			offset = s.startOffset
+		}
+	}
+	if seq == s.startSeq {
+		// A new spool starts over with the same sequence numbers.
+		s.startSeq, s.startOffset = 0, 0
+	}
+	s.size -= size
+	s.pending -= max(size-offset, 0)
+	s.segments = s.segments[1:]
+	s.rOffset = 0
+	if len(s.segments) == 0 {
+		s.size, s.pending = 0, 0
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// prepend adds records before the records not read yet, in a new first
+// segment, as far as they fit. It returns the number of records added. It
+// is only used on close, as the segment being read is closed.
+func (s *spool) prepend(recs [][]byte) (int, error) {
+	if s.pending <= 0 {
+		for i, rec := range recs {
+			if err := s.append(rec); err != nil {
+				if errors.Is(err, errSpoolFull) {
+					err = nil
+				}
+				return i, err
+			}
+		}
+		return len(recs), nil
+	}
+	if len(recs) == 0 {
+		return 0, nil
+	}
+	if s.segments[0] == 0 {
+		return 0, errors.New("netwriter: no spool segment number left to prepend to")
+	}
+
+	var buf []byte
+	n := 0
+	for _, rec := range recs {
+		if s.size+int64(len(buf)+spoolHeaderSize+len(rec)) > s.maxSize {
+			break
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(rec)))
+		buf = append(buf, rec...)
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	seq := s.segments[0] - 1
+	if err := os.WriteFile(s.path(seq), buf, 0o644); err != nil {
+		return 0, err
+	}
+	if s.r != nil {
+		s.startSeq, s.startOffset = s.rSeq, s.rOffset
+		_ = s.r.Close()
+		s.r = nil
+	}
+	s.segments = slices.Insert(s.segments, 0, seq)
+	s.size += int64(len(buf))
+	s.pending += int64(len(buf))
+	return n, nil
+}
+
+// close saves the read position and closes the open segment files.
+func (s *spool) close() error {
+	errs := []error{s.writePosition()}
+	if s.r != nil {
+		errs = append(errs, s.r.Close())
+		s.r = nil
+	}
+	if s.w != nil {
+		errs = append(errs, s.w.Close())
+		s.w = nil
+	}
+	return errors.Join(errs...)
+}
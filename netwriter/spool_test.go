@@ -0,0 +1,47 @@
+package netwriter
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpoolPositionSaved(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir, DefaultMaxSpoolSize)
+	require.NoError(t, err)
+	for _, rec := range []string{"one", "two", "three"} {
+		require.NoError(t, s.append([]byte(rec)))
+	}
+	rec, err := s.peek()
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(rec))
+	require.NoError(t, s.advance(rec))
+	require.NoError(t, s.close())
+
+	// The records already read are not read again.
+	s, err = openSpool(dir, DefaultMaxSpoolSize)
+	require.NoError(t, err)
+	defer s.close()
+	assert.EqualValues(t, 2*spoolHeaderSize+len("two")+len("three"), s.pending)
+	rec, err = s.peek()
+	require.NoError(t, err)
+	assert.Equal(t, "two", string(rec))
+}
+
+func TestSpoolCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir, DefaultMaxSpoolSize)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(s.path(firstSpoolSegment), []byte{0xff, 0xff, 0xff, 0xff, 'x'}, 0o644))
+	s, err = openSpool(dir, DefaultMaxSpoolSize)
+	require.NoError(t, err)
+	defer s.close()
+
+	rec, err := s.peek()
+	require.NoError(t, err)
+	assert.Nil(t, rec, "a record longer than its segment is treated as truncated")
+	assert.Zero(t, s.pending)
+}
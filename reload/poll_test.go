@@ -0,0 +1,54 @@
+package reload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestPollHalfWritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logging.json")
+	mtime := time.Now().Add(-time.Hour)
+	write := func(data string) {
+		mtime = mtime.Add(time.Second)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	write(`{"level": "warn"}`)
+
+	logger, hook := test.NewNullLogger()
+	w, err := Watch(logger, path, &Options{Interval: -1, Signals: []os.Signal{}})
+	require.NoError(t, err)
+	defer w.Close()
+	hook.Reset()
+
+	// A file read while it is written in place is read again at the next
+	// poll rather than reported.
+	write(`{"level": "deb`)
+	w.poll()
+	assert.Empty(t, hook.AllEntries())
+	write(`{"level": "debug"}`)
+	w.poll()
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "Reloaded logging configuration", hook.LastEntry().Message)
+
+	// An invalid file is reported once it reads the same twice, and only
+	// once.
+	hook.Reset()
+	write(`{"level": "loud"}`)
+	w.poll()
+	assert.Empty(t, hook.AllEntries())
+	w.poll()
+	w.poll()
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "Failed to reload logging configuration", hook.LastEntry().Message)
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+}
@@ -0,0 +1,213 @@
+// Package reload reloads the configuration of a logger from a JSON file
+// when the file changes or the process receives SIGHUP:
+//
+//	w, err := reload.Watch(logrus.StandardLogger(), "/etc/app/logging.json", nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//
+// The file contains a [logrus.Config], for example:
+//
+//	{"level": "debug", "format": "json", "outputs": ["/var/log/app.log"]}
+//
+// Changes are detected by polling the modification time and size of the
+// file, so no file notification mechanism is needed. A configuration is
+// applied atomically: every entry is logged with either the old or the new
+// configuration. Settings missing from the file are left as they are, so
+// removing the level from the file does not reset it. Every reload is
+// logged to the logger, and so are failed reloads, which leave the current
+// configuration in place.
+package reload
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultInterval is the polling interval if Options.Interval is 0.
+const DefaultInterval = time.Second
+
+// Options are options for [Watch]. A zero Options consists entirely of
+// default values.
+type Options struct {
+	// Interval is the interval at which the file is checked for changes.
+	// If 0, DefaultInterval is used. If negative, the file is not polled.
+	Interval time.Duration
+
+	// Signals are the signals reloading the configuration. If nil, SIGHUP
+	// is used where available. If empty but not nil, signals are not
+	// handled.
+	Signals []os.Signal
+}
+
+// Watcher reloads the configuration of a logger.
+type Watcher struct {
+	logger *logrus.Logger
+	path   string
+
+	// mu serializes reloads and guards the fields below.
+	mu      sync.Mutex
+	data    []byte    // the configuration applied last
+	modTime time.Time // the modification time of the file when read last
+	size    int64     // the size of the file when read last
+	statErr string    // the last error polling the file
+	failed  []byte    // the configuration that failed to load at the last poll
+
+	signals   chan os.Signal
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Watch applies the configuration in the file at path to logger and
+// reloads it whenever the file changes or one of opts.Signals is received.
+// It returns an error if the initial configuration cannot be applied.
+func Watch(logger *logrus.Logger, path string, opts *Options) (*Watcher, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	w := &Watcher{
+		logger: logger,
+		path:   path,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := w.load(true); err != nil {
+		return nil, err
+	}
+
+	interval := opts.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	signals := opts.Signals
+	if signals == nil {
+		signals = defaultSignals
+	}
+	if len(signals) > 0 {
+		w.signals = make(chan os.Signal, 1)
+		signal.Notify(w.signals, signals...)
+	}
+	go w.run(interval)
+	return w, nil
+}
+
+func (w *Watcher) run(interval time.Duration) {
+	defer close(w.done)
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.signals:
+			_ = w.Reload()
+		case <-tick:
+			w.poll()
+		}
+	}
+}
+
+// poll reloads the configuration if the file changed.
+func (w *Watcher) poll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		// Report a missing file once rather than at every poll, for example
+		// while an editor replaces it.
+		if err.Error() != w.statErr {
+			w.statErr = err.Error()
+			w.logError(err)
+		}
+		return
+	}
+	w.statErr = ""
+	if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return
+	}
+	if err := w.load(false); err != nil {
+		w.logError(err)
+	}
+}
+
+// Reload reloads the configuration, even if the file did not change, and
+// logs the result.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.load(true)
+	if err != nil {
+		w.logError(err)
+	}
+	return err
+}
+
+// load applies the configuration in the file if force is set or it
+// changed. It must be called with w.mu held, except by Watch.
+func (w *Watcher) load(force bool) error {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if !force && bytes.Equal(data, w.data) {
+		w.modTime, w.size, w.failed = fi.ModTime(), fi.Size(), nil
+		return nil
+	}
+
+	var cfg logrus.Config
+	err = cfg.LoadJSON(data)
+	if err == nil {
+		err = cfg.Apply(w.logger)
+	}
+	if err != nil {
+		// A file written in place may have been read half written, so a
+		// poll only reports the error if the file still reads the same at
+		// the next poll. Until then, the file is not considered read.
+		if !force && !bytes.Equal(data, w.failed) {
+			w.failed = data
+			return nil
+		}
+		w.modTime, w.size, w.failed = fi.ModTime(), fi.Size(), nil
+		return err
+	}
+	w.modTime, w.size, w.failed = fi.ModTime(), fi.Size(), nil
+	msg := "Reloaded logging configuration"
+	if w.data == nil {
+		msg = "Loaded logging configuration"
+	}
+	w.data = data
+	w.logger.WithField("path", w.path).Info(msg)
+	return nil
+}
+
+func (w *Watcher) logError(err error) {
+	w.logger.WithError(err).WithField("path", w.path).Error("Failed to reload logging configuration")
+}
+
+// Close stops watching the file. The configuration of the logger is not
+// changed.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		if w.signals != nil {
+			signal.Stop(w.signals)
+		}
+		close(w.stop)
+	})
+	<-w.done
+	return nil
+}
@@ -0,0 +1,91 @@
+package reload_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/sirupsen/logrus/reload"
+)
+
+// writeConfig writes the configuration file with a modification time
+// different from the previous one.
+func writeConfig(t *testing.T, path, data string, mtime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func messages(hook *test.Hook) []string {
+	var msgs []string
+	for _, e := range hook.AllEntries() {
+		msgs = append(msgs, e.Level.String()+": "+e.Message)
+	}
+	return msgs
+}
+
+func TestWatchPolling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logging.json")
+	start := time.Now().Add(-time.Hour)
+	writeConfig(t, path, `{"level": "warn"}`, start)
+
+	logger, hook := test.NewNullLogger()
+	w, err := reload.Watch(logger, path, &reload.Options{Interval: 5 * time.Millisecond, Signals: []os.Signal{}})
+	require.NoError(t, err)
+	defer w.Close()
+	assert.Equal(t, logrus.WarnLevel, logger.GetLevel())
+	assert.Empty(t, messages(hook), "the result is logged with the new level")
+
+	writeConfig(t, path, `{"level": "debug", "report_caller": true}`, start.Add(time.Second))
+	require.Eventually(t, func() bool { return logger.GetLevel() == logrus.DebugLevel }, 5*time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return len(hook.AllEntries()) == 1 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"info: Reloaded logging configuration"}, messages(hook))
+	assert.Equal(t, path, hook.LastEntry().Data["path"])
+	assert.True(t, logger.ReportCaller)
+
+	// An invalid configuration is reported once and leaves the current one
+	// in place.
+	hook.Reset()
+	writeConfig(t, path, `{"level": "loud"}`, start.Add(2*time.Second))
+	require.Eventually(t, func() bool { return len(hook.AllEntries()) == 1 }, 5*time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, []string{"error: Failed to reload logging configuration"}, messages(hook))
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+
+	// Touching the file without changing it does not reload it.
+	hook.Reset()
+	writeConfig(t, path, `{"level": "debug"}`, start.Add(3*time.Second))
+	require.Eventually(t, func() bool { return len(hook.AllEntries()) == 1 }, 5*time.Second, time.Millisecond)
+	writeConfig(t, path, `{"level": "debug"}`, start.Add(4*time.Second))
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, hook.AllEntries(), 1)
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logging.json")
+	writeConfig(t, path, `{"level": "info"}`, time.Now())
+
+	logger, hook := test.NewNullLogger()
+	w, err := reload.Watch(logger, path, &reload.Options{Interval: -1, Signals: []os.Signal{}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"info: Loaded logging configuration"}, messages(hook))
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, w.Reload())
+	assert.Equal(t, "error: Failed to reload logging configuration", messages(hook)[1])
+
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+}
+
+func TestWatchInitialError(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	_, err := reload.Watch(logger, filepath.Join(t.TempDir(), "missing.json"), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package reload_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/sirupsen/logrus/reload"
+)
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logging.json")
+	mtime := time.Now()
+	writeConfig(t, path, `{"level": "info"}`, mtime)
+
+	logger, hook := test.NewNullLogger()
+	w, err := reload.Watch(logger, path, &reload.Options{Interval: -1})
+	require.NoError(t, err)
+	defer w.Close()
+
+	// Keep the modification time, which polling would rely on.
+	writeConfig(t, path, `{"level": "trace"}`, mtime)
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	require.Eventually(t, func() bool { return logger.GetLevel() == logrus.TraceLevel }, 5*time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return len(hook.AllEntries()) == 2 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, "Reloaded logging configuration", hook.LastEntry().Message)
+}
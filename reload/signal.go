@@ -0,0 +1,10 @@
+//go:build !js
+
+package reload
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultSignals = []os.Signal{syscall.SIGHUP}
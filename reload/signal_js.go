@@ -0,0 +1,6 @@
+package reload
+
+import "os"
+
+// There are no signals in JavaScript environments.
+var defaultSignals []os.Signal
@@ -0,0 +1,222 @@
+package logrus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// Sink is an additional output of a [Logger], with its own formatter,
+// level and filter. Entries are written to the sinks of a logger in
+// addition to its Out, so that, for example, JSON can be written to a file
+// while colored text is written to the terminal:
+//
+//	logger.AddSink(&logrus.Sink{Out: file, Formatter: &logrus.JSONFormatter{}})
+//
+// A sink only receives entries the logger logs, so its Level cannot be more
+// verbose than the logger's. To only write to sinks, set the logger's Out
+// to nil.
+type Sink struct {
+	// Out receives the formatted entries.
+	Out io.Writer
+
+	// Formatter formats the entries written to Out. If nil, the logger's
+	// Formatter is used. Each entry is formatted only once per distinct
+	// formatter and coloring, however many sinks use it. A TextFormatter
+	// colors the entries of a sink if the sink's Out is a terminal, rather
+	// than the logger's Out.
+	Formatter Formatter
+
+	// Level is the least severe level written to the sink. The zero
+	// value, PanicLevel, does not restrict the levels.
+	Level Level
+
+	// Filter, if set, selects the entries written to the sink.
+	Filter func(entry *Entry) bool
+
+	terminalOnce sync.Once
+	terminal     bool
+}
+
+// isTerminal reports whether the sink writes to a terminal.
+func (sink *Sink) isTerminal() bool {
+	sink.terminalOnce.Do(func() {
+		out := sink.Out
+		if o, ok := out.(*configOutput); ok {
+			out = o.terminal
+		}
+		sink.terminal = checkIfTerminal(out)
+	})
+	return sink.terminal
+}
+
+// enabled reports whether entry is written to the sink.
+func (sink *Sink) enabled(entry *Entry) bool {
+	if sink.Out == nil {
+		return false
+	}
+	if sink.Level != PanicLevel && !entry.Level.AtLeast(sink.Level) {
+		return false
+	}
+	return sink.Filter == nil || sink.Filter(entry)
+}
+
+// AddSink adds a sink to the logger.
+func (logger *Logger) AddSink(sink *Sink) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	// Never append in place, log calls may hold a snapshot of Sinks.
+	logger.Sinks = append(slices.Clip(logger.Sinks), sink)
+}
+
+// RemoveSink removes a sink from the logger and reports whether it was
+// added.
+func (logger *Logger) RemoveSink(sink *Sink) bool {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	i := slices.Index(logger.Sinks, sink)
+	if i < 0 {
+		return false
+	}
+	logger.Sinks = slices.Delete(slices.Clone(logger.Sinks), i, i+1)
+	return true
+}
+
+// output is a writer and the formatter of the entries written to it.
+type output struct {
+	out       io.Writer
+	formatter Formatter
+	// sink is the sink of out, or nil for the logger's Out.
+	sink *Sink
+}
+
+// coloring is whether a TextFormatter colors the entries of an output.
+type coloring int8
+
+const (
+	notText coloring = iota // the formatter is not a TextFormatter
+	plain
+	colored
+)
+
+// coloring returns whether o's formatter colors the entries written to o.
+func (o output) coloring(entry *Entry) coloring {
+	tf, ok := o.formatter.(*TextFormatter)
+	if !ok {
+		return notText
+	}
+	var terminal bool
+	if o.sink != nil {
+		terminal = o.sink.isTerminal()
+	} else {
+		terminal = tf.isTerminal(entry)
+	}
+	if tf.isColored(terminal) {
+		return colored
+	}
+	return plain
+}
+
+// formatted is the result of formatting an entry with a formatter.
+type formatted struct {
+	formatter Formatter
+	coloring  coloring
+	data      []byte
+	err       error
+}
+
+// writeOutputs writes the entry to the logger's Out and its sinks. A failure
+// to format or write an entry for one output does not affect the others.
+func (entry *Entry) writeOutputs(formatter Formatter, out io.Writer, sinks []*Sink) {
+	outputs := make([]output, 0, len(sinks)+1)
+	if out != nil {
+		outputs = append(outputs, output{out: out, formatter: formatter})
+	}
+	for _, sink := range sinks {
+		if !sink.enabled(entry) {
+			continue
+		}
+		f := sink.Formatter
+		if f == nil {
+			f = formatter
+		}
+		outputs = append(outputs, output{out: sink.Out, formatter: f, sink: sink})
+	}
+	if len(outputs) == 0 {
+		return
+	}
+
+	// Formatters append to entry.Buffer, so every formatter after the first
+	// one gets a buffer of its own.
+	pool := entry.getBufferPool()
+	buffer := entry.Buffer
+	var extra []*bytes.Buffer
+	defer func() {
+		entry.Buffer = buffer
+		for _, b := range extra {
+			b.Reset()
+			pool.Put(b)
+		}
+	}()
+
+	results := make([]formatted, 0, 1)
+	lookup := func(f Formatter, c coloring) *formatted {
+		for i := range results {
+			if sameFormatter(results[i].formatter, f) && results[i].coloring == c {
+				return &results[i]
+			}
+		}
+		if len(results) > 0 && buffer != nil {
+			b := pool.Get()
+			b.Reset()
+			extra = append(extra, b)
+			entry.Buffer = b
+		}
+		var (
+			data []byte
+			err  error
+		)
+		if c == notText {
+			data, err = f.Format(entry)
+		} else {
+			data, err = f.(*TextFormatter).format(entry, c == colored)
+		}
+		if err != nil {
+			entry.Logger.stats.formatErrors.Add(1)
+			_, _ = fmt.Fprintln(Stderr(), "Failed to format entry:", err)
+		}
+		results = append(results, formatted{formatter: f, coloring: c, data: data, err: err})
+		return &results[len(results)-1]
+	}
+
+	serialized := make([][]byte, len(outputs))
+	for i, o := range outputs {
+		if r := lookup(o.formatter, o.coloring(entry)); r.err == nil {
+			serialized[i] = r.data
+		}
+	}
+
+	// Serialize writes to the underlying io.Writers.
+	entry.Logger.mu.Lock()
+	defer entry.Logger.mu.Unlock()
+	for i, o := range outputs {
+		if serialized[i] == nil {
+			continue
+		}
+		n, err := writeLevel(o.out, entry.Level, serialized[i])
+		entry.Logger.stats.written(n, err)
+		if err != nil {
+			_, _ = fmt.Fprintln(Stderr(), "Failed to write to log:", err)
+		}
+	}
+}
+
+// sameFormatter reports whether a and b are the same formatter. Formatters
+// of incomparable types are never the same.
+func sameFormatter(a, b Formatter) bool {
+	t := reflect.TypeOf(a)
+	return t != nil && t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
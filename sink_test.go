@@ -0,0 +1,73 @@
+package logrus_test
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/sirupsen/logrus"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+type countingFormatter struct {
+	Formatter
+	calls atomic.Int64
+}
+
+func (f *countingFormatter) Format(entry *Entry) ([]byte, error) {
+	f.calls.Add(1)
+	return f.Formatter.Format(entry)
+}
+
+func TestSinks(t *testing.T) {
+	var out, jsonOut, errOut, infoOut bytes.Buffer
+	text := &countingFormatter{Formatter: &TextFormatter{DisableColors: true, DisableTimestamp: true}}
+	json := &countingFormatter{Formatter: &JSONFormatter{DisableTimestamp: true}}
+
+	logger := New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(text)
+	logger.AddSink(&Sink{Out: &jsonOut, Formatter: json})
+	logger.AddSink(&Sink{Out: &errOut, Level: ErrorLevel})
+	logger.AddSink(&Sink{Out: &infoOut, Filter: func(entry *Entry) bool {
+		return entry.Level == InfoLevel
+	}})
+	logger.AddSink(&Sink{Out: failingWriter{}})
+
+	logger.WithField("key", "value").Info("info message")
+	logger.Error("error message")
+
+	assert.Equal(t, "level=info msg=\"info message\" key=value\nlevel=error msg=\"error message\"\n", out.String())
+	assert.Equal(t, "{\"key\":\"value\",\"level\":\"info\",\"msg\":\"info message\"}\n{\"level\":\"error\",\"msg\":\"error message\"}\n", jsonOut.String())
+	assert.Equal(t, "level=error msg=\"error message\"\n", errOut.String())
+	assert.Equal(t, "level=info msg=\"info message\" key=value\n", infoOut.String())
+
+	// Each entry is formatted once per distinct formatter.
+	assert.Equal(t, int64(2), text.calls.Load())
+	assert.Equal(t, int64(2), json.calls.Load())
+}
+
+func TestSinksWithoutOut(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New()
+	logger.SetOutput(nil)
+	logger.SetFormatter(&TextFormatter{DisableColors: true, DisableTimestamp: true})
+
+	logger.Info("dropped")
+	sink := &Sink{Out: &buf}
+	logger.AddSink(sink)
+	logger.Info("written")
+	assert.True(t, logger.RemoveSink(sink))
+	assert.False(t, logger.RemoveSink(sink))
+	logger.Info("dropped again")
+
+	assert.Equal(t, "level=info msg=written\n", buf.String())
+}
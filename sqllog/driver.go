@@ -0,0 +1,332 @@
+package sqllog
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+)
+
+type wrappedDriver struct {
+	parent driver.Driver
+	log    *queryLogger
+}
+
+var _ driver.Driver = (*wrappedDriver)(nil)
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{parent: c, log: d.log}, nil
+}
+
+type wrappedDriverContext struct {
+	wrappedDriver
+	dc driver.DriverContext
+}
+
+var _ driver.DriverContext = (*wrappedDriverContext)(nil)
+
+func (d *wrappedDriverContext) OpenConnector(name string) (driver.Connector, error) {
+	c, err := d.dc.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConnector{parent: c, driver: &d.wrappedDriver, log: d.log}, nil
+}
+
+type wrappedConnector struct {
+	parent driver.Connector
+	driver driver.Driver
+	log    *queryLogger
+}
+
+var _ driver.Connector = (*wrappedConnector)(nil)
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.parent.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{parent: conn, log: c.log}, nil
+}
+
+func (c *wrappedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// wrappedConn implements all optional connection interfaces and falls back
+// to the behavior database/sql uses when the parent lacks one.
+type wrappedConn struct {
+	parent driver.Conn
+	log    *queryLogger
+}
+
+var (
+	_ driver.Conn               = (*wrappedConn)(nil)
+	_ driver.ConnPrepareContext = (*wrappedConn)(nil)
+	_ driver.ConnBeginTx        = (*wrappedConn)(nil)
+	_ driver.ExecerContext      = (*wrappedConn)(nil)
+	_ driver.QueryerContext     = (*wrappedConn)(nil)
+	_ driver.Pinger             = (*wrappedConn)(nil)
+	_ driver.SessionResetter    = (*wrappedConn)(nil)
+	_ driver.Validator          = (*wrappedConn)(nil)
+	_ driver.NamedValueChecker  = (*wrappedConn)(nil)
+)
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if pc, ok := c.parent.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.parent.Prepare(query)
+		if err == nil && ctx.Err() != nil {
+			_ = stmt.Close()
+			stmt, err = nil, ctx.Err()
+		}
+	}
+	c.log.log(ctx, OpPrepare, query, nil, start, nil, err)
+	if err != nil {
+		return nil, err
+	}
+	ws := &wrappedStmt{parent: stmt, query: query, log: c.log}
+	if _, ok := stmt.(driver.ColumnConverter); ok { //nolint:staticcheck // forwarded for drivers still using it
+		return &wrappedConverterStmt{ws}, nil
+	}
+	return ws, nil
+}
+
+func (c *wrappedConn) Close() error {
+	return c.parent.Close()
+}
+
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if bt, ok := c.parent.(driver.ConnBeginTx); ok {
+		tx, err = bt.BeginTx(ctx, opts)
+	} else if opts.Isolation != driver.IsolationLevel(0) || opts.ReadOnly {
+		err = errors.New("sqllog: driver does not support non-default transaction options")
+	} else {
+		tx, err = c.parent.Begin() //nolint:staticcheck // fallback for drivers without ConnBeginTx
+	}
+	c.log.log(ctx, OpBegin, "", nil, start, nil, err)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedTx{parent: tx, ctx: ctx, log: c.log}, nil
+}
+
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	switch ec := c.parent.(type) {
+	case driver.ExecerContext:
+		res, err = ec.ExecContext(ctx, query, args)
+	case driver.Execer: //nolint:staticcheck // fallback for drivers without ExecerContext
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			res, err = ec.Exec(query, values)
+		}
+	default:
+		return nil, driver.ErrSkip
+	}
+	c.log.log(ctx, OpExec, query, args, start, res, err)
+	return res, err
+}
+
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	switch qc := c.parent.(type) {
+	case driver.QueryerContext:
+		rows, err = qc.QueryContext(ctx, query, args)
+	case driver.Queryer: //nolint:staticcheck // fallback for drivers without QueryerContext
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = qc.Query(query, values)
+		}
+	default:
+		return nil, driver.ErrSkip
+	}
+	c.log.log(ctx, OpQuery, query, args, start, nil, err)
+	return rows, err
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if p, ok := c.parent.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.parent.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if v, ok := c.parent.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.parent.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type wrappedStmt struct {
+	parent driver.Stmt
+	query  string
+	log    *queryLogger
+}
+
+var (
+	_ driver.Stmt              = (*wrappedStmt)(nil)
+	_ driver.StmtExecContext   = (*wrappedStmt)(nil)
+	_ driver.StmtQueryContext  = (*wrappedStmt)(nil)
+	_ driver.NamedValueChecker = (*wrappedStmt)(nil)
+)
+
+func (s *wrappedStmt) Close() error {
+	return s.parent.Close()
+}
+
+func (s *wrappedStmt) NumInput() int {
+	return s.parent.NumInput()
+}
+
+func (s *wrappedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *wrappedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	if ec, ok := s.parent.(driver.StmtExecContext); ok {
+		res, err = ec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			res, err = s.parent.Exec(values) //nolint:staticcheck // fallback for drivers without StmtExecContext
+		}
+	}
+	s.log.log(ctx, OpExec, s.query, args, start, res, err)
+	return res, err
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if qc, ok := s.parent.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = s.parent.Query(values) //nolint:staticcheck // fallback for drivers without StmtQueryContext
+		}
+	}
+	s.log.log(ctx, OpQuery, s.query, args, start, nil, err)
+	return rows, err
+}
+
+func (s *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := s.parent.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// wrappedConverterStmt is a wrappedStmt whose parent implements
+// driver.ColumnConverter, which database/sql uses to convert the arguments
+// CheckNamedValue skips. It is a separate type so that statements of other
+// drivers keep the default conversion.
+type wrappedConverterStmt struct {
+	*wrappedStmt
+}
+
+var _ driver.ColumnConverter = (*wrappedConverterStmt)(nil) //nolint:staticcheck // forwarded for drivers still using it
+
+func (s *wrappedConverterStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return s.parent.(driver.ColumnConverter).ColumnConverter(idx) //nolint:staticcheck // forwarded for drivers still using it
+}
+
+type wrappedTx struct {
+	parent driver.Tx
+	ctx    context.Context
+	log    *queryLogger
+}
+
+var _ driver.Tx = (*wrappedTx)(nil)
+
+func (t *wrappedTx) Commit() error {
+	start := time.Now()
+	err := t.parent.Commit()
+	t.log.log(t.ctx, OpCommit, "", nil, start, nil, err)
+	return err
+}
+
+func (t *wrappedTx) Rollback() error {
+	start := time.Now()
+	err := t.parent.Rollback()
+	t.log.log(t.ctx, OpRollback, "", nil, start, nil, err)
+	return err
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sqllog: driver does not support the use of named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func valuesToNamedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
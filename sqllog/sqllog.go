@@ -0,0 +1,202 @@
+// Package sqllog provides a database/sql driver wrapper that logs queries
+// through Logrus.
+//
+// [Wrap] wraps any [driver.Driver] so that statements executed through it
+// are logged with their query, arguments, rows affected, duration and error.
+// [Register] registers such a wrapper for an already registered driver:
+//
+//	if err := sqllog.Register("logged-postgres", "postgres", logger, nil); err != nil {
+//		return err
+//	}
+//	db, err := sql.Open("logged-postgres", dsn)
+//
+// Entries get their context from the query context, so a request-scoped
+// entry stored with [github.com/sirupsen/logrus/httplog.NewContext]
+// contributes its fields.
+package sqllog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/internal/entrycontext"
+)
+
+// Field keys used for query log fields.
+const (
+	FieldKeyOp           = "op"
+	FieldKeyQuery        = "query"
+	FieldKeyArgs         = "args"
+	FieldKeyDuration     = "duration"
+	FieldKeyRowsAffected = "rows_affected"
+)
+
+// Operations reported in the [FieldKeyOp] field.
+const (
+	OpExec     = "exec"
+	OpQuery    = "query"
+	OpPrepare  = "prepare"
+	OpBegin    = "begin"
+	OpCommit   = "commit"
+	OpRollback = "rollback"
+)
+
+// Options are options for a wrapped driver.
+// A zero Options consists entirely of default values.
+type Options struct {
+	// SlowThreshold promotes operations taking at least this long to
+	// [logrus.WarnLevel]. Zero disables slow query detection.
+	SlowThreshold time.Duration
+
+	// LevelFunc returns the level an operation is logged at. If nil,
+	// failed operations are logged at [logrus.ErrorLevel], slow ones at
+	// [logrus.WarnLevel] and everything else at [logrus.DebugLevel].
+	LevelFunc func(op string, d time.Duration, err error) logrus.Level
+
+	// LogArgs adds the query arguments to the log line.
+	LogArgs bool
+
+	// RedactArg, if set, is applied to every argument before it is logged.
+	// It can be used to mask passwords or tokens. The returned value is
+	// logged in place of the argument.
+	RedactArg func(query string, arg driver.NamedValue) any
+
+	// Message is the message of the log line. Defaults to "sql".
+	Message string
+}
+
+// Register registers a driver named name that wraps the driver already
+// registered as driverName and logs to logger.
+//
+// The provided logger must not be nil. Register panics if logger is nil, or,
+// like [sql.Register], if a driver named name is already registered.
+// If opts is nil, the default options are used.
+func Register(name, driverName string, logger *logrus.Logger, opts *Options) error {
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return err
+	}
+	d := db.Driver()
+	if err := db.Close(); err != nil {
+		return err
+	}
+	sql.Register(name, Wrap(d, logger, opts))
+	return nil
+}
+
+// Wrap returns a driver that forwards to d and logs to logger.
+//
+// The provided logger must not be nil. Wrap panics if logger is nil.
+// If opts is nil, the default options are used.
+func Wrap(d driver.Driver, logger *logrus.Logger, opts *Options) driver.Driver {
+	l := newQueryLogger(logger, opts)
+	if dc, ok := d.(driver.DriverContext); ok {
+		return &wrappedDriverContext{wrappedDriver{parent: d, log: l}, dc}
+	}
+	return &wrappedDriver{parent: d, log: l}
+}
+
+// WrapConnector returns a connector that forwards to c and logs to logger,
+// for use with [sql.OpenDB].
+//
+// The provided logger must not be nil. WrapConnector panics if logger is nil.
+// If opts is nil, the default options are used.
+func WrapConnector(c driver.Connector, logger *logrus.Logger, opts *Options) driver.Connector {
+	l := newQueryLogger(logger, opts)
+	return &wrappedConnector{parent: c, driver: &wrappedDriver{parent: c.Driver(), log: l}, log: l}
+}
+
+// queryLogger holds the logger and options shared by all wrappers of one driver.
+type queryLogger struct {
+	logger *logrus.Logger
+	opts   Options
+}
+
+func newQueryLogger(logger *logrus.Logger, opts *Options) *queryLogger {
+	if logger == nil {
+		panic("cannot create driver from nil logger")
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	l := &queryLogger{logger: logger, opts: *opts}
+	if l.opts.LevelFunc == nil {
+		l.opts.LevelFunc = l.defaultLevel
+	}
+	if l.opts.Message == "" {
+		l.opts.Message = "sql"
+	}
+	return l
+}
+
+// defaultLevel is the default [Options.LevelFunc].
+func (l *queryLogger) defaultLevel(_ string, d time.Duration, err error) logrus.Level {
+	switch {
+	case err != nil:
+		return logrus.ErrorLevel
+	case l.opts.SlowThreshold > 0 && d >= l.opts.SlowThreshold:
+		return logrus.WarnLevel
+	default:
+		return logrus.DebugLevel
+	}
+}
+
+// log logs an operation that started at start. result is consulted for the
+// number of affected rows if it is not nil. Fallbacks signalled with
+// [driver.ErrSkip] are not logged.
+func (l *queryLogger) log(ctx context.Context, op, query string, args []driver.NamedValue, start time.Time, result driver.Result, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	d := time.Since(start)
+	level := l.opts.LevelFunc(op, d, err)
+	if !l.logger.IsLevelEnabled(level) {
+		return
+	}
+
+	var entry *logrus.Entry
+	if ctx == nil {
+		entry = logrus.NewEntry(l.logger)
+	} else if scoped, ok := entrycontext.From(ctx); ok && scoped.Logger == l.logger {
+		entry = scoped.WithContext(ctx)
+	} else {
+		entry = l.logger.WithContext(ctx)
+	}
+
+	fields := logrus.Fields{
+		FieldKeyOp:       op,
+		FieldKeyDuration: d,
+	}
+	if query != "" {
+		fields[FieldKeyQuery] = query
+	}
+	if l.opts.LogArgs && len(args) > 0 {
+		fields[FieldKeyArgs] = l.args(query, args)
+	}
+	if result != nil {
+		if n, rerr := result.RowsAffected(); rerr == nil {
+			fields[FieldKeyRowsAffected] = n
+		}
+	}
+	if err != nil {
+		fields[logrus.ErrorKey] = err
+	}
+	entry.WithFields(fields).Log(level, l.opts.Message)
+}
+
+// args returns the loggable, possibly redacted, values of args.
+func (l *queryLogger) args(query string, args []driver.NamedValue) []any {
+	out := make([]any, len(args))
+	for i, arg := range args {
+		if l.opts.RedactArg != nil {
+			out[i] = l.opts.RedactArg(query, arg)
+		} else {
+			out[i] = arg.Value
+		}
+	}
+	return out
+}
@@ -0,0 +1,346 @@
+package sqllog_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/sirupsen/logrus/httplog"
+	"github.com/sirupsen/logrus/sqllog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver is an in-memory driver that understands a tiny set of
+// statements:
+//
+//	INSERT <value>   appends value to the table
+//	SELECT           returns all values
+//	SLEEP            sleeps for fakeSleep
+//	FAIL             returns errFake
+type fakeDriver struct {
+	mu   sync.Mutex
+	rows []string
+	// legacy disables the context-aware fast paths on connections.
+	legacy bool
+	// convert makes statements implement driver.ColumnConverter,
+	// converting integers to strings.
+	convert bool
+}
+
+var (
+	errFake   = errors.New("fake failure")
+	fakeSleep = 20 * time.Millisecond
+)
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	if d.legacy {
+		return &fakeLegacyConn{d: d}, nil
+	}
+	return &fakeConn{fakeLegacyConn{d: d}}, nil
+}
+
+// fakeLegacyConn implements only the mandatory driver.Conn methods, so
+// database/sql has to prepare every statement.
+type fakeLegacyConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeLegacyConn) Prepare(query string) (driver.Stmt, error) {
+	if query == "BAD" {
+		return nil, errFake
+	}
+	if c.d.convert {
+		return &fakeConverterStmt{fakeStmt{d: c.d, query: query}}, nil
+	}
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+
+func (c *fakeLegacyConn) Close() error { return nil }
+
+func (c *fakeLegacyConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+// fakeConn adds the context-aware fast paths.
+type fakeConn struct {
+	fakeLegacyConn
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.d.exec(query, args)
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.d.query(query)
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return s.d.exec(s.query, named)
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return s.d.query(s.query)
+}
+
+type fakeConverterStmt struct {
+	fakeStmt
+}
+
+func (s *fakeConverterStmt) ColumnConverter(int) driver.ValueConverter {
+	return intToString{}
+}
+
+type intToString struct{}
+
+func (intToString) ConvertValue(v any) (driver.Value, error) {
+	if i, ok := v.(int); ok {
+		return strconv.Itoa(i), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func (d *fakeDriver) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case query == "FAIL":
+		return nil, errFake
+	case query == "SLEEP":
+		time.Sleep(fakeSleep)
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "INSERT"):
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for _, arg := range args {
+			d.rows = append(d.rows, arg.Value.(string))
+		}
+		return driver.RowsAffected(len(args)), nil
+	default:
+		return nil, errors.New("unknown statement")
+	}
+}
+
+func (d *fakeDriver) query(query string) (driver.Rows, error) {
+	if query != "SELECT" {
+		return nil, errFake
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &fakeRows{values: append([]string(nil), d.rows...)}, nil
+}
+
+type fakeRows struct {
+	values []string
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return errFake }
+
+func openDB(t *testing.T, d *fakeDriver, opts *sqllog.Options) (*sql.DB, *test.Hook) {
+	t.Helper()
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	db := sql.OpenDB(connector{sqllog.Wrap(d, logger, opts)})
+	t.Cleanup(func() { _ = db.Close() })
+	return db, hook
+}
+
+// connector adapts a driver to sql.OpenDB without registering it globally.
+type connector struct {
+	d driver.Driver
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c connector) Driver() driver.Driver                        { return c.d }
+
+func TestExecAndQuery(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		name := "context"
+		if legacy {
+			name = "legacy"
+		}
+		t.Run(name, func(t *testing.T) {
+			db, hook := openDB(t, &fakeDriver{legacy: legacy}, &sqllog.Options{LogArgs: true})
+
+			res, err := db.Exec("INSERT", "a")
+			require.NoError(t, err)
+			n, err := res.RowsAffected()
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), n)
+
+			rows, err := db.Query("SELECT")
+			require.NoError(t, err)
+			var got []string
+			for rows.Next() {
+				var v string
+				require.NoError(t, rows.Scan(&v))
+				got = append(got, v)
+			}
+			require.NoError(t, rows.Close())
+			assert.Equal(t, []string{"a"}, got)
+
+			var execs, queries []*logrus.Entry
+			for _, e := range hook.AllEntries() {
+				switch e.Data[sqllog.FieldKeyOp] {
+				case sqllog.OpExec:
+					execs = append(execs, e)
+				case sqllog.OpQuery:
+					queries = append(queries, e)
+				}
+			}
+			require.Len(t, execs, 1)
+			require.Len(t, queries, 1)
+
+			assert.Equal(t, logrus.DebugLevel, execs[0].Level)
+			assert.Equal(t, "sql", execs[0].Message)
+			assert.Equal(t, "INSERT", execs[0].Data[sqllog.FieldKeyQuery])
+			assert.Equal(t, []any{"a"}, execs[0].Data[sqllog.FieldKeyArgs])
+			assert.Equal(t, int64(1), execs[0].Data[sqllog.FieldKeyRowsAffected])
+			assert.IsType(t, time.Duration(0), execs[0].Data[sqllog.FieldKeyDuration])
+			assert.Equal(t, "SELECT", queries[0].Data[sqllog.FieldKeyQuery])
+		})
+	}
+}
+
+func TestErrorsAndSlowQueries(t *testing.T) {
+	db, hook := openDB(t, &fakeDriver{}, &sqllog.Options{SlowThreshold: fakeSleep / 2})
+
+	_, err := db.Exec("FAIL")
+	require.ErrorIs(t, err, errFake)
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, logrus.ErrorLevel, entry.Level)
+	assert.ErrorIs(t, entry.Data[logrus.ErrorKey].(error), errFake)
+
+	_, err = db.Exec("SLEEP")
+	require.NoError(t, err)
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+
+	_, err = db.Prepare("BAD")
+	require.ErrorIs(t, err, errFake)
+	assert.Equal(t, sqllog.OpPrepare, hook.LastEntry().Data[sqllog.FieldKeyOp])
+	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+}
+
+func TestRedactArgs(t *testing.T) {
+	db, hook := openDB(t, &fakeDriver{}, &sqllog.Options{
+		LogArgs: true,
+		RedactArg: func(_ string, arg driver.NamedValue) any {
+			if arg.Ordinal == 2 {
+				return "***"
+			}
+			return arg.Value
+		},
+	})
+
+	_, err := db.Exec("INSERT", "user", "password")
+	require.NoError(t, err)
+	assert.Equal(t, []any{"user", "***"}, hook.LastEntry().Data[sqllog.FieldKeyArgs])
+}
+
+func TestArgsNotLoggedByDefault(t *testing.T) {
+	db, hook := openDB(t, &fakeDriver{}, nil)
+
+	_, err := db.Exec("INSERT", "secret")
+	require.NoError(t, err)
+	assert.NotContains(t, hook.LastEntry().Data, sqllog.FieldKeyArgs)
+}
+
+func TestTransactions(t *testing.T) {
+	db, hook := openDB(t, &fakeDriver{}, nil)
+
+	ctx := context.WithValue(context.Background(), struct{}{}, "tx")
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT", "a")
+	require.NoError(t, err)
+	require.ErrorIs(t, tx.Rollback(), errFake)
+
+	var ops []string
+	for _, e := range hook.AllEntries() {
+		ops = append(ops, e.Data[sqllog.FieldKeyOp].(string))
+		assert.Equal(t, ctx, e.Context)
+	}
+	assert.Equal(t, []string{sqllog.OpBegin, sqllog.OpExec, sqllog.OpRollback}, ops)
+	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+}
+
+func TestContextFieldsFlowThrough(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	db := sql.OpenDB(connector{sqllog.Wrap(&fakeDriver{}, logger, nil)})
+	defer db.Close()
+
+	h := httplog.NewHandler(logger, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, err := db.ExecContext(r.Context(), "INSERT", "a")
+		require.NoError(t, err)
+	}), nil)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(httplog.DefaultRequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, sqllog.OpExec, entries[0].Data[sqllog.FieldKeyOp])
+	assert.Equal(t, "req-1", entries[0].Data[httplog.FieldKeyRequestID])
+	assert.NotNil(t, entries[0].Context)
+}
+
+func TestColumnConverter(t *testing.T) {
+	d := &fakeDriver{legacy: true, convert: true}
+	db, hook := openDB(t, d, nil)
+
+	_, err := db.Exec("INSERT", 42)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"42"}, d.rows, "the statement's converter is used")
+	assert.Equal(t, "INSERT", hook.LastEntry().Data[sqllog.FieldKeyQuery])
+}
+
+func TestRegister(t *testing.T) {
+	sql.Register("sqllog-fake", &fakeDriver{})
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	require.NoError(t, sqllog.Register("sqllog-fake-logged", "sqllog-fake", logger, nil))
+
+	db, err := sql.Open("sqllog-fake-logged", "")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("INSERT", "a")
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT", hook.LastEntry().Data[sqllog.FieldKeyQuery])
+
+	assert.Error(t, sqllog.Register("other", "does-not-exist", logger, nil))
+}
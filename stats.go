@@ -0,0 +1,64 @@
+package logrus
+
+import "sync/atomic"
+
+// maxStatsLevel bounds the levels counted by level in [LoggerStats].
+const maxStatsLevel = 32
+
+// LoggerStats are the counters of a [Logger], as returned by
+// [Logger.Stats]. They are meant to monitor the health of logging, for
+// example with the metrics package.
+type LoggerStats struct {
+	// Entries is the number of entries logged, by level. Levels without
+	// entries are omitted.
+	Entries map[Level]uint64
+	// BytesWritten is the number of bytes written to the logger's Out
+	// and sinks.
+	BytesWritten uint64
+	// FormatErrors is the number of times a formatter failed.
+	FormatErrors uint64
+	// WriteErrors is the number of failed writes to the logger's Out and
+	// sinks.
+	WriteErrors uint64
+	// HookFailures is the number of times a hook failed or panicked.
+	HookFailures uint64
+}
+
+// loggerStats holds the counters of a Logger.
+type loggerStats struct {
+	entries      [maxStatsLevel]atomic.Uint64
+	bytesWritten atomic.Uint64
+	formatErrors atomic.Uint64
+	writeErrors  atomic.Uint64
+	hookFailures atomic.Uint64
+}
+
+func (s *loggerStats) entry(level Level) {
+	if level < maxStatsLevel {
+		s.entries[level].Add(1)
+	}
+}
+
+func (s *loggerStats) written(n int, err error) {
+	s.bytesWritten.Add(uint64(n))
+	if err != nil {
+		s.writeErrors.Add(1)
+	}
+}
+
+// Stats returns the counters of the logger since it was created.
+func (logger *Logger) Stats() LoggerStats {
+	stats := LoggerStats{
+		Entries:      make(map[Level]uint64),
+		BytesWritten: logger.stats.bytesWritten.Load(),
+		FormatErrors: logger.stats.formatErrors.Load(),
+		WriteErrors:  logger.stats.writeErrors.Load(),
+		HookFailures: logger.stats.hookFailures.Load(),
+	}
+	for level := range logger.stats.entries {
+		if n := logger.stats.entries[level].Load(); n > 0 {
+			stats.Entries[Level(level)] = n
+		}
+	}
+	return stats
+}
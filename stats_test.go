@@ -0,0 +1,45 @@
+package logrus_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/sirupsen/logrus"
+)
+
+type errorFormatter struct{}
+
+func (errorFormatter) Format(*Entry) ([]byte, error) {
+	return nil, errors.New("format failed")
+}
+
+func TestLoggerStats(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New()
+	logger.SetOutput(&buf)
+	logger.SetLevel(DebugLevel)
+	logger.SetHookErrorHandler(func(Hook, *Entry, error) {})
+	logger.AddHook(&FailingHook{Err: errors.New("boom")})
+
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Info("info")
+	logger.Trace("not logged")
+
+	logger.SetFormatter(errorFormatter{})
+	logger.Error("not formatted")
+	logger.SetOutput(failingWriter{})
+	logger.SetFormatter(&TextFormatter{})
+	logger.Warn("not written")
+
+	assert.Equal(t, LoggerStats{
+		Entries:      map[Level]uint64{DebugLevel: 1, InfoLevel: 2, ErrorLevel: 1, WarnLevel: 1},
+		BytesWritten: uint64(buf.Len()),
+		FormatErrors: 1,
+		WriteErrors:  1,
+		HookFailures: 5,
+	}, logger.Stats())
+}
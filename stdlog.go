@@ -0,0 +1,344 @@
+package logrus
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// StdLogOptions are options for [RedirectStdLog].
+// A zero StdLogOptions consists entirely of default values.
+type StdLogOptions struct {
+	// Logger is the standard library logger to redirect. If nil,
+	// [log.Default] is used.
+	Logger *log.Logger
+
+	// Level is the level of lines without a recognized level hint.
+	// Redirected lines never panic, so the zero value, [PanicLevel],
+	// selects [InfoLevel].
+	Level Level
+
+	// DisableLevelParsing disables the detection of level hints such as
+	// "[WARN]" or "ERROR:" at the start of a line.
+	DisableLevelParsing bool
+
+	// Fields are added to every redirected entry.
+	Fields Fields
+}
+
+// RedirectStdLog redirects the output of a standard library [log.Logger] to
+// logger and returns a function that restores its previous output.
+//
+// The prefix, timestamp and file information the [log.Logger] adds according
+// to its flags are stripped from every line. File information is kept as
+// the entry's Caller. Unless disabled, a level hint at the start of the
+// message, such as "[WARN]", "[error]" or "ERROR:", selects the level of the
+// entry and is removed from the message.
+//
+// Lines are logged with [Entry.Log], so hints such as "FATAL:" or "[panic]"
+// neither exit nor panic.
+func RedirectStdLog(logger *Logger, opts *StdLogOptions) (undo func()) {
+	if opts == nil {
+		opts = &StdLogOptions{}
+	}
+	target := opts.Logger
+	if target == nil {
+		target = log.Default()
+	}
+	level := opts.Level
+	if level == PanicLevel {
+		level = InfoLevel
+	}
+
+	w := &stdLogWriter{
+		entry:       NewEntry(logger).WithFields(opts.Fields),
+		target:      target,
+		level:       level,
+		parseLevels: !opts.DisableLevelParsing,
+	}
+
+	prevOut := target.Writer()
+	target.SetOutput(w)
+	return func() {
+		target.SetOutput(prevOut)
+	}
+}
+
+// stdLogWriter receives the output of a standard library logger. The
+// standard library writes exactly one record per Write call.
+type stdLogWriter struct {
+	entry       *Entry
+	target      *log.Logger
+	level       Level
+	parseLevels bool
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	msg, caller := stripStdLogHeader(string(p), w.target.Prefix(), w.target.Flags())
+
+	level := w.level
+	if w.parseLevels {
+		if lvl, rest, ok := parseLevelHint(msg); ok {
+			level, msg = lvl, rest
+		}
+	}
+
+	entry := w.entry
+	if caller != nil {
+		entry = entry.Dup()
+		entry.Caller = caller
+	}
+	entry.Log(level, msg)
+	return len(p), nil
+}
+
+// stripStdLogHeader removes the header a [log.Logger] with the given prefix
+// and flags writes before the message, and the trailing newline. It
+// returns the file information of the header, if any, as a frame.
+func stripStdLogHeader(line, prefix string, flags int) (string, *runtime.Frame) {
+	line = strings.TrimSuffix(line, "\n")
+	if flags&log.Lmsgprefix == 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	if flags&log.Ldate != 0 {
+		line = skipStdLogHeaderField(line, len("2009/01/23 "))
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n := len("01:23:23 ")
+		if flags&log.Lmicroseconds != 0 {
+			n += len(".123123")
+		}
+		line = skipStdLogHeaderField(line, n)
+	}
+
+	var caller *runtime.Frame
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		if header, rest, ok := strings.Cut(line, ": "); ok {
+			if i := strings.LastIndexByte(header, ':'); i >= 0 {
+				if n, err := strconv.Atoi(header[i+1:]); err == nil {
+					caller = &runtime.Frame{File: header[:i], Line: n}
+					line = rest
+				}
+			}
+		}
+	}
+
+	if flags&log.Lmsgprefix != 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	return line, caller
+}
+
+// skipStdLogHeaderField skips a fixed-width header field of n bytes
+// including its trailing space, if present.
+func skipStdLogHeaderField(line string, n int) string {
+	if len(line) < n || line[n-1] != ' ' {
+		return line
+	}
+	return line[n:]
+}
+
+// levelHints maps the level names recognized by parseLevelHint to levels.
+// The names of levels registered with RegisterLevel take precedence.
+var levelHints = map[string]Level{
+	"trace":    TraceLevel,
+	"debug":    DebugLevel,
+	"info":     InfoLevel,
+	"warn":     WarnLevel,
+	"warning":  WarnLevel,
+	"err":      ErrorLevel,
+	"error":    ErrorLevel,
+	"crit":     FatalLevel,
+	"critical": FatalLevel,
+	"fatal":    FatalLevel,
+	"panic":    PanicLevel,
+}
+
+// maxLevelHintLen is the length of the longest word that lookupLevelHint
+// considers, so that long prefixes are not converted to lower case.
+const maxLevelHintLen = 32
+
+// lookupLevelHint returns the level named by a level hint, case-insensitively.
+func lookupLevelHint(name string) (Level, bool) {
+	if len(name) > maxLevelHintLen {
+		return 0, false
+	}
+	name = strings.ToLower(name)
+	if level, ok := lookupLevelName(name); ok {
+		return level, true
+	}
+	level, ok := levelHints[name]
+	return level, ok
+}
+
+// parseLevelHint detects a level hint at the start of s, either a level name
+// in square brackets ("[WARN] msg", "[warn]: msg") or followed by a colon
+// ("ERROR: msg"). It returns the level and s without the hint.
+func parseLevelHint(s string) (Level, string, bool) {
+	var name, rest string
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return 0, s, false
+		}
+		name, rest = s[1:end], strings.TrimPrefix(s[end+1:], ":")
+	} else {
+		end := strings.IndexByte(s, ':')
+		if end < 0 {
+			return 0, s, false
+		}
+		name, rest = s[:end], s[end+1:]
+	}
+	level, ok := lookupLevelHint(strings.TrimSpace(name))
+	if !ok {
+		return 0, s, false
+	}
+	return level, strings.TrimLeft(rest, " \t"), true
+}
+
+// CaptureOptions are options for [CaptureOutput].
+// A zero CaptureOptions consists entirely of default values.
+type CaptureOptions struct {
+	// StdoutLevel and StderrLevel are the levels of lines without a
+	// recognized level hint written to the respective stream. Captured
+	// lines never panic, so the zero value, [PanicLevel], selects
+	// [InfoLevel] for stdout and [ErrorLevel] for stderr.
+	StdoutLevel Level
+	StderrLevel Level
+
+	// DisableStdout and DisableStderr leave the respective stream alone.
+	DisableStdout bool
+	DisableStderr bool
+
+	// DisableLevelParsing disables the detection of level hints such as
+	// "[WARN]" or "ERROR:" at the start of a line.
+	DisableLevelParsing bool
+}
+
+// FieldKeyStream is the field holding the name of the stream, "stdout" or
+// "stderr", a line captured by [CaptureOutput] was written to.
+const FieldKeyStream = "stream"
+
+// capturedStderr is the original os.Stderr while CaptureOutput captures it.
+var capturedStderr atomic.Pointer[os.File]
+
+// Stderr returns the file that diagnostics about logging itself, such as
+// failed hooks and writes, are printed to. It is [os.Stderr], except while
+// [CaptureOutput] captures os.Stderr, when it is the original file.
+func Stderr() *os.File {
+	if f := capturedStderr.Load(); f != nil {
+		return f
+	}
+	return os.Stderr
+}
+
+// CaptureOutput replaces [os.Stdout] and [os.Stderr] with pipes whose
+// output is logged to logger line by line, and returns a function that
+// restores the original files and waits until all captured output has been
+// logged.
+//
+// Only writes through the os.Stdout and os.Stderr variables are captured,
+// such as those of [fmt.Println]; writes to the underlying file descriptors,
+// for example by the runtime or child processes, are not. The logger must
+// not write to the replaced files, otherwise its output would be captured
+// again. A logger whose Out was set to os.Stdout or os.Stderr before
+// CaptureOutput was called keeps writing to the original file, and so do
+// the diagnostics printed to [Stderr].
+func CaptureOutput(logger *Logger, opts *CaptureOptions) (undo func(), err error) {
+	if opts == nil {
+		opts = &CaptureOptions{}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		restores []func()
+	)
+	undo = func() {
+		for _, restore := range restores {
+			restore()
+		}
+		wg.Wait()
+	}
+
+	capture := func(f **os.File, name string, level Level) error {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		orig := *f
+		*f = w
+		// Keep diagnostics about logging going to the original stderr, so
+		// that they are not captured and logged again.
+		prevStderr := capturedStderr.Load()
+		if f == &os.Stderr && prevStderr == nil {
+			capturedStderr.Store(orig)
+		}
+		restores = append(restores, func() {
+			*f = orig
+			if f == &os.Stderr {
+				capturedStderr.Store(prevStderr)
+			}
+			_ = w.Close()
+		})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer r.Close()
+			entry := NewEntry(logger).WithField(FieldKeyStream, name)
+			readLines(r, func(line string) {
+				lvl := level
+				if !opts.DisableLevelParsing {
+					if l, rest, ok := parseLevelHint(line); ok {
+						lvl, line = l, rest
+					}
+				}
+				entry.Log(lvl, line)
+			})
+		}()
+		return nil
+	}
+
+	if !opts.DisableStdout {
+		level := opts.StdoutLevel
+		if level == PanicLevel {
+			level = InfoLevel
+		}
+		if err := capture(&os.Stdout, "stdout", level); err != nil {
+			undo()
+			return nil, err
+		}
+	}
+	if !opts.DisableStderr {
+		level := opts.StderrLevel
+		if level == PanicLevel {
+			level = ErrorLevel
+		}
+		if err := capture(&os.Stderr, "stderr", level); err != nil {
+			undo()
+			return nil, err
+		}
+	}
+	return undo, nil
+}
+
+// readLines calls fn for every line read from r, without the line
+// terminator, until r returns an error. Lines are not limited in length.
+func readLines(r io.Reader, fn func(line string)) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			fn(strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package logrus_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestRedirectStdLog(t *testing.T) {
+	var prev bytes.Buffer
+	std := log.New(&prev, "app: ", log.LstdFlags|log.Lmicroseconds)
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	undo := logrus.RedirectStdLog(logger, &logrus.StdLogOptions{
+		Logger: std,
+		Fields: logrus.Fields{"source": "stdlog"},
+	})
+
+	std.Print("plain message")
+	std.Print("[WARN] disk almost full")
+	std.Print("ERROR: connection refused")
+	std.Print("[debug]: details")
+	std.Print("FATAL: does not exit")
+	std.Print("http: not a level")
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 6)
+
+	want := []struct {
+		level logrus.Level
+		msg   string
+	}{
+		{logrus.InfoLevel, "plain message"},
+		{logrus.WarnLevel, "disk almost full"},
+		{logrus.ErrorLevel, "connection refused"},
+		{logrus.DebugLevel, "details"},
+		{logrus.FatalLevel, "does not exit"},
+		{logrus.InfoLevel, "http: not a level"},
+	}
+	for i, w := range want {
+		assert.Equal(t, w.level, entries[i].Level, entries[i].Message)
+		assert.Equal(t, w.msg, entries[i].Message)
+		assert.Equal(t, "stdlog", entries[i].Data["source"])
+	}
+
+	undo()
+	std.Print("restored")
+	assert.Len(t, hook.AllEntries(), 6)
+	assert.Contains(t, prev.String(), "restored")
+}
+
+func TestRedirectStdLogFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		flags  int
+		prefix string
+	}{
+		{"no flags", 0, ""},
+		{"prefix", 0, "svc "},
+		{"date and time", log.Ldate | log.Ltime, "svc "},
+		{"utc microseconds", log.LstdFlags | log.Lmicroseconds | log.LUTC, ""},
+		{"msgprefix", log.LstdFlags | log.Lmsgprefix, "svc: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			std := log.New(nil, tt.prefix, tt.flags)
+			logger, hook := test.NewNullLogger()
+			undo := logrus.RedirectStdLog(logger, &logrus.StdLogOptions{Logger: std, Level: logrus.WarnLevel})
+			defer undo()
+
+			std.Print("hello")
+			entry := hook.LastEntry()
+			require.NotNil(t, entry)
+			assert.Equal(t, "hello", entry.Message)
+			assert.Equal(t, logrus.WarnLevel, entry.Level)
+			assert.Nil(t, entry.Caller)
+		})
+	}
+}
+
+func TestRedirectStdLogFileInfo(t *testing.T) {
+	std := log.New(nil, "", log.LstdFlags|log.Lshortfile)
+	logger, hook := test.NewNullLogger()
+	undo := logrus.RedirectStdLog(logger, &logrus.StdLogOptions{Logger: std, DisableLevelParsing: true})
+	defer undo()
+
+	std.Print("[WARN] kept")
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, "[WARN] kept", entry.Message)
+	assert.Equal(t, logrus.InfoLevel, entry.Level)
+	require.NotNil(t, entry.Caller)
+	assert.Equal(t, "stdlog_test.go", entry.Caller.File)
+	assert.Positive(t, entry.Caller.Line)
+}
+
+func TestCaptureOutput(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.TextFormatter{DisableColors: true, DisableTimestamp: true})
+
+	undo, err := logrus.CaptureOutput(logger, nil)
+	require.NoError(t, err)
+	fmt.Println("to stdout")
+	fmt.Fprintln(os.Stderr, "to stderr")
+	fmt.Fprint(os.Stdout, "[warn] no newline")
+	undo()
+
+	out := buf.String()
+	assert.Contains(t, out, "level=info msg=\"to stdout\" stream=stdout\n")
+	assert.Contains(t, out, "level=error msg=\"to stderr\" stream=stderr\n")
+	assert.Contains(t, out, "level=warning msg=\"no newline\" stream=stdout\n")
+}
+
+func TestCaptureOutputDiagnostics(t *testing.T) {
+	stderr := os.Stderr
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+
+	undo, err := logrus.CaptureOutput(logger, nil)
+	require.NoError(t, err)
+	assert.NotSame(t, stderr, os.Stderr)
+	assert.Same(t, stderr, logrus.Stderr(), "diagnostics are not captured")
+	undo()
+
+	assert.Same(t, os.Stderr, logrus.Stderr())
+	assert.Empty(t, buf.String())
+}
+
+func TestCaptureOutputRestoresFiles(t *testing.T) {
+	stdout, stderr := os.Stdout, os.Stderr
+	logger, _ := test.NewNullLogger()
+
+	undo, err := logrus.CaptureOutput(logger, &logrus.CaptureOptions{DisableStderr: true})
+	require.NoError(t, err)
+	assert.NotSame(t, stdout, os.Stdout)
+	assert.Same(t, stderr, os.Stderr)
+	undo()
+
+	assert.Same(t, stdout, os.Stdout)
+	assert.Same(t, stderr, os.Stderr)
+}
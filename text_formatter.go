@@ -118,6 +118,9 @@ func (f *TextFormatter) isTerminal(entry *Entry) bool {
 		entry.Logger.mu.Lock()
 		out := entry.Logger.Out
 		entry.Logger.mu.Unlock()
+		if o, ok := out.(*configOutput); ok {
+			out = o.terminal
+		}
 
 		f.terminal = checkIfTerminal(out)
 	})
@@ -145,9 +148,13 @@ func (f *TextFormatter) isColored(isTerminal bool) bool {
 
 // Format renders a single log entry
 func (f *TextFormatter) Format(entry *Entry) ([]byte, error) {
+	return f.format(entry, f.isColored(f.isTerminal(entry)))
+}
+
+// format renders entry, with colors if isColored is set.
+func (f *TextFormatter) format(entry *Entry, isColored bool) ([]byte, error) {
 	data := make(Fields, len(entry.Data))
 	maps.Copy(data, entry.Data)
-	isColored := f.isColored(f.isTerminal(entry))
 
 	caller := entry.Caller
 	hasCaller := caller != nil
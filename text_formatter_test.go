@@ -791,3 +791,21 @@ func TestTextFormatterPanickingValue(t *testing.T) {
 		})
 	}
 }
+
+func TestTextFormatterSinkColors(t *testing.T) {
+	t.Setenv("CLICOLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+
+	// The logger's Out is a terminal, the sink's is not.
+	tf := &TextFormatter{DisableTimestamp: true}
+	tf.terminalInitOnce.Do(func() { tf.terminal = true })
+	var out, sinkOut bytes.Buffer
+	logger := New()
+	logger.Out = &out
+	logger.Formatter = tf
+	logger.AddSink(&Sink{Out: &sinkOut})
+
+	logger.Info("hello")
+	assert.Contains(t, out.String(), "\x1b[")
+	assert.Equal(t, "level=info msg=hello\n", sinkOut.String())
+}
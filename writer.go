@@ -2,9 +2,14 @@ package logrus
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"io"
+	"regexp"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Writer at INFO level. See WriterLevel for details.
@@ -17,6 +22,9 @@ func (logger *Logger) Writer() *io.PipeWriter {
 // printed in the usual way using formatters and hooks. The writer is part of an
 // io.Pipe and it is the callers responsibility to close the writer when done.
 // This can be used to override the standard library logger easily.
+//
+// Use [Logger.LineWriter] to parse levels and structure from the written
+// lines, or to wait for all output to be logged when closing the writer.
 func (logger *Logger) WriterLevel(level Level) *io.PipeWriter {
 	return NewEntry(logger).WriterLevel(level)
 }
@@ -26,75 +34,354 @@ func (entry *Entry) Writer() *io.PipeWriter {
 	return entry.WriterLevel(InfoLevel)
 }
 
-// WriterLevel returns an io.Writer that writes to the logger at the given log level
+// WriterLevel returns an io.Writer that writes to the logger at the given
+// log level. Lines longer than 64 KiB are split. A level of [FatalLevel] or
+// [PanicLevel] exits or panics after logging the line.
 func (entry *Entry) WriterLevel(level Level) *io.PipeWriter {
+	p := &lineParser{
+		entry:     entry,
+		level:     level,
+		opts:      LineWriterOptions{MaxLineSize: bufio.MaxScanTokenSize},
+		exitOnLog: true,
+	}
 	reader, writer := io.Pipe()
-
-	printFunc := entry.Print
-
-	// Determine which log function to use based on the specified log level
-	switch level {
-	case TraceLevel:
-		printFunc = entry.Trace
-	case DebugLevel:
-		printFunc = entry.Debug
-	case InfoLevel:
-		printFunc = entry.Info
-	case WarnLevel:
-		printFunc = entry.Warn
-	case ErrorLevel:
-		printFunc = entry.Error
-	case FatalLevel:
-		printFunc = entry.Fatal
-	case PanicLevel:
-		printFunc = entry.Panic
-	}
-
-	// Start a new goroutine to scan the input and write it to the logger using the specified print function.
-	// It splits the input into chunks of up to 64KB to avoid buffer overflows.
-	go entry.writerScanner(reader, printFunc)
-
-	// Set a finalizer function to close the writer when it is garbage collected
+	go p.run(reader)
 	runtime.SetFinalizer(writer, writerFinalizer)
-
 	return writer
 }
 
-// writerScanner scans the input from the reader and writes it to the logger
-func (entry *Entry) writerScanner(reader *io.PipeReader, printFunc func(args ...any)) {
-	scanner := bufio.NewScanner(reader)
+// WriterFinalizer is a finalizer function that closes then given writer when it is garbage collected
+func writerFinalizer(writer *io.PipeWriter) {
+	writer.Close()
+}
 
-	// Set the buffer size to the maximum token size to avoid buffer overflows
-	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), bufio.MaxScanTokenSize)
+// DefaultContinuationPattern matches lines that continue the record of the
+// previous line, such as the indented frames and "Caused by:" lines of Java
+// stack traces and the indented lines of Python tracebacks. The final line
+// of a Python traceback is not indented and starts a new record.
+var DefaultContinuationPattern = regexp.MustCompile(`^(\s|Caused by:|\.\.\. \d+ more)`)
 
-	// Define a split function to split the input into chunks of up to 64KB
-	chunkSize := bufio.MaxScanTokenSize // 64KB
-	splitFunc := func(data []byte, atEOF bool) (int, []byte, error) {
-		if len(data) >= chunkSize {
-			return chunkSize, data[:chunkSize], nil
-		}
+// defaultMaxLineSize is the line size limit of a LineWriter when
+// LineWriterOptions.MaxLineSize is zero.
+const defaultMaxLineSize = 1 << 20
 
-		return bufio.ScanLines(data, atEOF)
+// defaultFlushTimeout is the flush timeout of a LineWriter when
+// LineWriterOptions.FlushTimeout is zero.
+const defaultFlushTimeout = time.Second
+
+// LineWriterOptions are options for [Entry.LineWriter].
+// A zero LineWriterOptions consists entirely of default values.
+type LineWriterOptions struct {
+	// ParseJSON logs lines holding a JSON object with the object's message,
+	// level, time and remaining fields merged into the entry. The message
+	// is read from the "msg" or "message" key, the level from "level",
+	// "lvl" or "severity" and the time, if it is an RFC 3339 string, from
+	// "time", "ts" or "timestamp". Numeric levels are read as the levels of
+	// pino, from 10 for trace to 60 for fatal.
+	ParseJSON bool
+
+	// ParseLevels selects the level of a line from a logfmt "level=" or
+	// "lvl=" key, or from a level hint such as "[WARN]" or "ERROR:" at the
+	// start of the line, which is removed from the message.
+	ParseLevels bool
+
+	// Continuation matches lines that belong to the record of the previous
+	// line, for example the frames of a stack trace. Matching lines are
+	// joined to that record with newlines and logged as one entry. A record
+	// is logged once the next record starts, no line is written for
+	// FlushTimeout or the writer is closed. If nil, every line is a record
+	// of its own. See [DefaultContinuationPattern].
+	Continuation *regexp.Regexp
+
+	// FlushTimeout is the time after which a record of continued lines is
+	// logged if no further line is written. Defaults to one second.
+	FlushTimeout time.Duration
+
+	// MaxLineSize is the size, in bytes, at which longer lines are split.
+	// Defaults to 1 MiB.
+	MaxLineSize int
+}
+
+// LineWriter is an [io.WriteCloser] that logs every line, or every record
+// of continued lines, written to it. Lines are logged from a separate
+// goroutine; [LineWriter.Close] waits for it to log all remaining output.
+//
+// Unlike the writer returned by [Entry.WriterLevel], a LineWriter logs with
+// [Entry.Log], so neither the default level nor a parsed level of
+// [FatalLevel] or [PanicLevel] exits or panics.
+type LineWriter struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+}
+
+var _ io.WriteCloser = (*LineWriter)(nil)
+
+// LineWriter returns a [LineWriter] that logs lines at the given level
+// through the logger. If opts is nil, the default options are used.
+func (logger *Logger) LineWriter(level Level, opts *LineWriterOptions) *LineWriter {
+	return NewEntry(logger).LineWriter(level, opts)
+}
+
+// LineWriter returns a [LineWriter] that logs lines through the entry. Lines
+// whose level is not parsed are logged at the given level. If opts is nil,
+// the default options are used.
+//
+// It is intended for output of subprocesses:
+//
+//	w := entry.LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{
+//		ParseJSON:    true,
+//		ParseLevels:  true,
+//		Continuation: logrus.DefaultContinuationPattern,
+//	})
+//	defer w.Close()
+//	cmd.Stdout = w
+func (entry *Entry) LineWriter(level Level, opts *LineWriterOptions) *LineWriter {
+	if opts == nil {
+		opts = &LineWriterOptions{}
+	}
+	p := &lineParser{
+		entry: entry,
+		level: level,
+		opts:  *opts,
+	}
+	if p.opts.MaxLineSize <= 0 {
+		p.opts.MaxLineSize = defaultMaxLineSize
 	}
+	if p.opts.FlushTimeout <= 0 {
+		p.opts.FlushTimeout = defaultFlushTimeout
+	}
+
+	reader, writer := io.Pipe()
+	w := &LineWriter{pw: writer, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		p.run(reader)
+	}()
+	return w
+}
+
+// Write implements [io.Writer]. It blocks until the line parser has
+// consumed p.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close closes the writer and waits until all output written to it has been
+// logged. It is safe to call Close more than once.
+func (w *LineWriter) Close() error {
+	err := w.pw.Close()
+	<-w.done
+	return err
+}
 
-	// Use the custom split function to split the input
-	scanner.Split(splitFunc)
+// lineParser reads lines for a LineWriter or the writer returned by
+// Entry.WriterLevel and logs them.
+type lineParser struct {
+	entry *Entry
+	level Level
+	opts  LineWriterOptions
 
-	// Scan the input and write it to the logger using the specified print function
+	// exitOnLog logs entries at FatalLevel and PanicLevel with Entry.Fatal
+	// and Entry.Panic, as the writer returned by Entry.WriterLevel does.
+	exitOnLog bool
+
+	// mu guards record and lastLine, which are accessed by the flush timer.
+	mu sync.Mutex
+	// record holds the lines of the record that is not logged yet.
+	record []string
+	// lastLine is when the last line of record was read.
+	lastLine time.Time
+}
+
+func (p *lineParser) run(reader *io.PipeReader) {
+	scanner := bufio.NewScanner(reader)
+	maxSize := p.opts.MaxLineSize
+	scanner.Buffer(make([]byte, min(maxSize, bufio.MaxScanTokenSize)), maxSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if len(data) >= maxSize {
+			if i := bytes.IndexByte(data[:maxSize], '\n'); i < 0 {
+				return maxSize, data[:maxSize], nil
+			}
+		}
+		return bufio.ScanLines(data, atEOF)
+	})
+
+	// Without a continuation pattern every line is logged as it is read.
+	// Otherwise a record is held until the next record starts, and the
+	// timer logs it if no line follows within the flush timeout.
+	var timer *time.Timer
+	if p.opts.Continuation != nil {
+		timer = time.AfterFunc(p.opts.FlushTimeout, p.flushIdle)
+		timer.Stop()
+	}
 	for scanner.Scan() {
-		printFunc(strings.TrimRight(scanner.Text(), "\r\n"))
+		line := strings.TrimRight(scanner.Text(), "\r\n")
+		if timer == nil {
+			p.record = append(p.record, line)
+			p.flush()
+			continue
+		}
+
+		p.mu.Lock()
+		if len(p.record) == 0 || !p.opts.Continuation.MatchString(line) {
+			p.flush()
+		}
+		p.record = append(p.record, line)
+		p.lastLine = time.Now()
+		p.mu.Unlock()
+		timer.Reset(p.opts.FlushTimeout)
+	}
+	if timer != nil {
+		timer.Stop()
 	}
+	p.mu.Lock()
+	p.flush()
+	p.mu.Unlock()
 
-	// If there was an error while scanning the input, log an error
 	if err := scanner.Err(); err != nil {
-		entry.Errorf("Error while reading from Writer: %s", err)
+		p.entry.Errorf("Error while reading from Writer: %s", err)
 	}
-
-	// Close the reader when we are done
 	reader.Close()
 }
 
-// WriterFinalizer is a finalizer function that closes then given writer when it is garbage collected
-func writerFinalizer(writer *io.PipeWriter) {
-	writer.Close()
+// flushIdle logs the pending record if no line was read for the flush
+// timeout. It is called by the flush timer.
+func (p *lineParser) flushIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A line read after the timer fired reset the timer to a later time.
+	if time.Since(p.lastLine) >= p.opts.FlushTimeout {
+		p.flush()
+	}
+}
+
+// flush logs the pending record, if any. p.mu must be held if the flush
+// timer is used.
+func (p *lineParser) flush() {
+	if len(p.record) == 0 {
+		return
+	}
+	entry, level := p.entry, p.level
+
+	var msg string
+	if len(p.record) == 1 {
+		msg = p.record[0]
+		if fields, ok := p.parseJSON(msg); ok {
+			entry, level, msg = p.mergeJSON(fields)
+		} else if p.opts.ParseLevels {
+			level, msg = p.parseLevel(msg)
+		}
+	} else {
+		if p.opts.ParseLevels {
+			level, p.record[0] = p.parseLevel(p.record[0])
+		}
+		msg = strings.Join(p.record, "\n")
+	}
+	p.record = p.record[:0]
+
+	if p.exitOnLog {
+		switch level {
+		case FatalLevel:
+			entry.Fatal(msg)
+			return
+		case PanicLevel:
+			entry.Panic(msg)
+			return
+		}
+	}
+	entry.Log(level, msg)
+}
+
+// parseJSON decodes line if it holds a JSON object and JSON parsing is
+// enabled.
+func (p *lineParser) parseJSON(line string) (map[string]any, bool) {
+	if !p.opts.ParseJSON {
+		return nil, false
+	}
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") {
+		return nil, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+		return nil, false
+	}
+	return fields, true
+}
+
+// mergeJSON returns an entry holding the fields of a decoded JSON line, and
+// the level and message found in it.
+func (p *lineParser) mergeJSON(fields map[string]any) (*Entry, Level, string) {
+	level := p.level
+	var msg string
+	for _, key := range []string{"msg", "message"} {
+		if v, ok := fields[key].(string); ok {
+			msg = v
+			delete(fields, key)
+			break
+		}
+	}
+	for _, key := range []string{"level", "lvl", "severity"} {
+		if lvl, ok := jsonLevel(fields[key]); ok {
+			level = lvl
+			delete(fields, key)
+			break
+		}
+	}
+
+	entry := p.entry
+	for _, key := range []string{"time", "ts", "timestamp"} {
+		if v, ok := fields[key].(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				entry = entry.WithTime(t)
+				delete(fields, key)
+				break
+			}
+		}
+	}
+	return entry.WithFields(fields), level, msg
+}
+
+// parseLevel returns the level selected by a logfmt level key or a level
+// hint in line, and the message to log.
+func (p *lineParser) parseLevel(line string) (Level, string) {
+	if level, rest, ok := parseLevelHint(line); ok {
+		return level, rest
+	}
+	for _, key := range []string{"level=", "lvl="} {
+		i := strings.Index(line, key)
+		if i < 0 || (i > 0 && line[i-1] != ' ') {
+			continue
+		}
+		value, _, _ := strings.Cut(line[i+len(key):], " ")
+		if level, ok := lookupLevelHint(strings.Trim(value, `"`)); ok {
+			return level, line
+		}
+	}
+	return p.level, line
+}
+
+// jsonLevel returns the level of a JSON level value, which is either a level
+// name or a numeric pino level.
+func jsonLevel(v any) (Level, bool) {
+	switch v := v.(type) {
+	case string:
+		return lookupLevelHint(v)
+	case float64:
+		switch {
+		case v < 20:
+			return TraceLevel, true
+		case v < 30:
+			return DebugLevel, true
+		case v < 40:
+			return InfoLevel, true
+		case v < 50:
+			return WarnLevel, true
+		case v < 60:
+			return ErrorLevel, true
+		default:
+			return FatalLevel, true
+		}
+	}
+	return 0, false
 }
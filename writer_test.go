@@ -3,8 +3,11 @@ package logrus_test
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"io"
 	"log"
 	"net/http"
+	"os/exec"
 	"strings"
 	"sync"
 	"testing"
@@ -14,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 )
 
 func ExampleLogger_Writer_httpServer() {
@@ -126,3 +130,224 @@ func TestWriterSplitsMax64KB(t *testing.T) {
 	// we should have 4 lines because we wrote more than 64 KB each time
 	assert.Len(t, lines, 4, "logger printed incorrect number of lines")
 }
+
+func TestWriterSplitsAtNewlines(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	writer := logger.Writer()
+
+	// Both lines fit into one 64 KiB chunk and must not be logged as one.
+	_, err := io.WriteString(writer, "first\n"+strings.Repeat("A", bufio.MaxScanTokenSize))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	require.Eventually(t, func() bool { return len(hook.AllEntries()) == 2 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "first", hook.AllEntries()[0].Message)
+}
+
+func newLineWriterLogger() (*logrus.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetLevel(logrus.TraceLevel)
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{DisableTimestamp: true})
+	return logger, &buf
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var m map[string]any
+		require.NoError(t, dec.Decode(&m))
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestLineWriterCloseWaits(t *testing.T) {
+	logger, buf := newLineWriterLogger()
+	w := logger.LineWriter(logrus.InfoLevel, nil)
+
+	for range 100 {
+		_, err := io.WriteString(w, "line\n")
+		require.NoError(t, err)
+	}
+	_, err := io.WriteString(w, "partial")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 101)
+	assert.Equal(t, "partial", lines[100]["msg"])
+	assert.Equal(t, "info", lines[100]["level"])
+}
+
+func TestLineWriterParseJSON(t *testing.T) {
+	logger, buf := newLineWriterLogger()
+	w := logger.WithField("child", "worker").LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{ParseJSON: true})
+
+	_, err := io.WriteString(w, `{"level":"warn","msg":"from child","count":3,"time":"2026-01-02T03:04:05Z"}`+"\n")
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "{not json}\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "warning", lines[0]["level"])
+	assert.Equal(t, "from child", lines[0]["msg"])
+	assert.InDelta(t, 3, lines[0]["count"], 0)
+	assert.Equal(t, "worker", lines[0]["child"])
+	assert.Equal(t, "{not json}", lines[1]["msg"])
+	assert.Equal(t, "info", lines[1]["level"])
+}
+
+func TestLineWriterParseJSONLevels(t *testing.T) {
+	logger, buf := newLineWriterLogger()
+	w := logger.LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{ParseJSON: true})
+
+	for _, line := range []string{
+		`{"level":10,"msg":"pino trace"}`,
+		`{"level":30,"msg":"pino info"}`,
+		`{"level":50,"msg":"pino error"}`,
+		`{"level":60,"msg":"pino fatal"}`,
+		`{"severity":"CRITICAL","msg":"critical"}`,
+		`{"level":true,"msg":"not a level"}`,
+	} {
+		_, err := io.WriteString(w, line+"\n")
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 6)
+	for i, level := range []string{"trace", "info", "error", "fatal", "fatal", "info"} {
+		assert.Equal(t, level, lines[i]["level"], lines[i]["msg"])
+	}
+}
+
+func TestLineWriterParseJSONTime(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	w := logger.LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{ParseJSON: true})
+	_, err := io.WriteString(w, `{"msg":"m","ts":"2026-01-02T03:04:05.5Z"}`+"\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	require.NotNil(t, hook.LastEntry())
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 5e8, time.UTC), hook.LastEntry().Time.UTC())
+	assert.NotContains(t, hook.LastEntry().Data, "ts")
+}
+
+func TestLineWriterParseLevels(t *testing.T) {
+	logger, buf := newLineWriterLogger()
+	w := logger.LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{ParseLevels: true})
+
+	for _, line := range []string{
+		`time=2026-01-02 level=error msg="logfmt line"`,
+		`lvl=debug msg=short`,
+		`[WARN] bracketed`,
+		`FATAL: does not exit`,
+		`nolevel=error here`,
+		`plain`,
+	} {
+		_, err := io.WriteString(w, line+"\n")
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 6)
+	want := [][2]string{
+		{"error", `time=2026-01-02 level=error msg="logfmt line"`},
+		{"debug", `lvl=debug msg=short`},
+		{"warning", "bracketed"},
+		{"fatal", "does not exit"},
+		{"info", "nolevel=error here"},
+		{"info", "plain"},
+	}
+	for i, w := range want {
+		assert.Equal(t, w[0], lines[i]["level"])
+		assert.Equal(t, w[1], lines[i]["msg"])
+	}
+}
+
+func TestLineWriterContinuation(t *testing.T) {
+	logger, buf := newLineWriterLogger()
+	w := logger.LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{
+		ParseLevels:  true,
+		Continuation: logrus.DefaultContinuationPattern,
+	})
+
+	_, err := io.WriteString(w, strings.Join([]string{
+		"ERROR: Exception in thread \"main\" java.lang.IllegalStateException: boom",
+		"\tat com.example.Main.run(Main.java:10)",
+		"\tat com.example.Main.main(Main.java:5)",
+		"Caused by: java.io.IOException: disk",
+		"\t... 2 more",
+		"next record",
+	}, "\n")+"\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "error", lines[0]["level"])
+	assert.Equal(t, strings.Join([]string{
+		"Exception in thread \"main\" java.lang.IllegalStateException: boom",
+		"\tat com.example.Main.run(Main.java:10)",
+		"\tat com.example.Main.main(Main.java:5)",
+		"Caused by: java.io.IOException: disk",
+		"\t... 2 more",
+	}, "\n"), lines[0]["msg"])
+	assert.Equal(t, "next record", lines[1]["msg"])
+}
+
+func TestLineWriterFlushTimeout(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	w := logger.LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{
+		Continuation: logrus.DefaultContinuationPattern,
+		FlushTimeout: 10 * time.Millisecond,
+	})
+	defer w.Close()
+
+	_, err := io.WriteString(w, "Traceback (most recent call last):\n  File \"main.py\", line 1\n")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(hook.AllEntries()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "Traceback (most recent call last):\n  File \"main.py\", line 1", hook.LastEntry().Message)
+}
+
+func TestLineWriterMaxLineSize(t *testing.T) {
+	logger, buf := newLineWriterLogger()
+	w := logger.LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{MaxLineSize: 10})
+
+	_, err := io.WriteString(w, strings.Repeat("a", 25)+"\nshort\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 4)
+	assert.Equal(t, strings.Repeat("a", 10), lines[0]["msg"])
+	assert.Equal(t, strings.Repeat("a", 5), lines[2]["msg"])
+	assert.Equal(t, "short", lines[3]["msg"])
+}
+
+func TestLineWriterSubprocess(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	logger, buf := newLineWriterLogger()
+	w := logger.LineWriter(logrus.InfoLevel, &logrus.LineWriterOptions{ParseJSON: true, ParseLevels: true})
+
+	cmd := exec.Command("sh", "-c", `echo '{"level":"error","msg":"child failed"}'; echo 'WARN: careful'`)
+	cmd.Stdout = w
+	require.NoError(t, cmd.Run())
+	require.NoError(t, w.Close())
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "error", lines[0]["level"])
+	assert.Equal(t, "child failed", lines[0]["msg"])
+	assert.Equal(t, "warning", lines[1]["level"])
+}
@@ -0,0 +1,40 @@
+// Package yamlconfig loads a [logrus.Config] from YAML, for example:
+//
+//	level: debug
+//	format: json
+//	format_options:
+//	  TimestampFormat: "2006-01-02T15:04:05Z07:00"
+//	  FieldMap:
+//	    msg: message
+//	outputs: [stderr, /var/log/app.log]
+//	report_caller: true
+//	sampling:
+//	  rate: 0.1
+//	hooks:
+//	  - type: syslog
+//	    options:
+//	      tag: app
+//
+// The keys are the same as in JSON, see [logrus.Config].
+package yamlconfig
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Load sets the fields of cfg present in the YAML document data. Unknown
+// keys are an error.
+func Load(data []byte, cfg *logrus.Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
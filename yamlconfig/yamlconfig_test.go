@@ -0,0 +1,57 @@
+package yamlconfig_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/yamlconfig"
+)
+
+func TestLoad(t *testing.T) {
+	var cfg logrus.Config
+	require.NoError(t, yamlconfig.Load([]byte(`
+level: debug
+format: json
+format_options:
+  PrettyPrint: true
+  FieldMap:
+    msg: message
+outputs: [stderr, discard]
+report_caller: true
+sampling:
+  rate: 0.25
+  level: error
+hooks:
+  - type: syslog
+    options:
+      tag: app
+`), &cfg))
+
+	require.NotNil(t, cfg.Level)
+	assert.Equal(t, logrus.DebugLevel, *cfg.Level)
+	assert.Equal(t, "json", cfg.Format)
+	assert.Equal(t, map[string]any{"PrettyPrint": true, "FieldMap": map[string]any{"msg": "message"}}, cfg.FormatOptions)
+	assert.Equal(t, []string{"stderr", "discard"}, cfg.Outputs)
+	assert.True(t, *cfg.ReportCaller)
+	assert.Equal(t, &logrus.SamplingConfig{Rate: 0.25, Level: logrus.ErrorLevel}, cfg.Sampling)
+	assert.Equal(t, []logrus.HookConfig{{Type: "syslog", Options: map[string]any{"tag": "app"}}}, cfg.Hooks)
+
+	// The options can be applied like options loaded from JSON.
+	logger := logrus.New()
+	cfg.Hooks = nil
+	require.NoError(t, cfg.Apply(logger))
+	f, ok := logger.Formatter.(*logrus.JSONFormatter)
+	require.True(t, ok)
+	assert.True(t, f.PrettyPrint)
+	assert.Equal(t, logrus.FieldMap{logrus.FieldKeyMsg: "message"}, f.FieldMap)
+}
+
+func TestLoadErrors(t *testing.T) {
+	var cfg logrus.Config
+	assert.Error(t, yamlconfig.Load([]byte("levle: debug"), &cfg))
+	assert.Error(t, yamlconfig.Load([]byte("level: loud"), &cfg))
+	assert.NoError(t, yamlconfig.Load(nil, &cfg))
+}